@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/database"
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/model"
+	"github.com/google/uuid"
+)
+
+const (
+	auditActionLogin               = "user.login"
+	auditActionLoginFailed         = "user.login_failed"
+	auditActionEmailChanged        = "user.email_changed"
+	auditActionPasswordChanged     = "user.password_changed"
+	auditActionChirpyRedUpgraded   = "user.chirpy_red_upgraded"
+	auditActionRefreshTokenIssued  = "refresh_token.issued"
+	auditActionRefreshTokenRevoked = "refresh_token.revoked"
+	auditActionRefreshTokenRotated = "refresh_token.rotated"
+	auditActionRefreshTokenReused  = "refresh_token.reuse_detected"
+	auditActionChirpDeleted        = "chirp.deleted"
+	auditActionAdminReset          = "admin.reset"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+type auditEntry struct {
+	ActorID    uuid.UUID
+	Action     string
+	TargetType string
+	TargetID   string
+	Metadata   map[string]any
+}
+
+// recordAudit stores an audit event for the request. Failures are only logged
+// so that auditing never breaks the action being audited.
+func (cfg *apiConfig) recordAudit(r *http.Request, entry auditEntry) {
+	// the client may already be gone, the event should still be written
+	cfg.writeAudit(context.WithoutCancel(r.Context()), entry, clientIP(r), r.UserAgent())
+}
+
+// recordSystemAudit stores an audit event for work that doesn't come from a
+// request, like background jobs.
+func (cfg *apiConfig) recordSystemAudit(ctx context.Context, entry auditEntry) {
+	cfg.writeAudit(ctx, entry, "", "")
+}
+
+func (cfg *apiConfig) writeAudit(ctx context.Context, entry auditEntry, ipAddress, userAgent string) {
+	metadata := []byte("{}")
+	if entry.Metadata != nil {
+		data, err := json.Marshal(entry.Metadata)
+		if err != nil {
+			log.Printf("Error marshaling audit metadata for %s: %s", entry.Action, err)
+		} else {
+			metadata = data
+		}
+	}
+
+	err := cfg.dbQueries.CreateAuditEvent(ctx, database.CreateAuditEventParams{
+		ActorID:    uuid.NullUUID{UUID: entry.ActorID, Valid: entry.ActorID != uuid.Nil},
+		Action:     entry.Action,
+		TargetType: entry.TargetType,
+		TargetID:   entry.TargetID,
+		IpAddress:  ipAddress,
+		UserAgent:  userAgent,
+		Metadata:   metadata,
+	})
+	if err != nil {
+		log.Printf("Error recording audit event %s: %s", entry.Action, err)
+	}
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func (cfg *apiConfig) handlerAuditEventsList(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.requireAdmin(w, r); !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	params := database.ListAuditEventsParams{Limit: defaultAuditLimit}
+
+	if actor := query.Get("actor_id"); actor != "" {
+		actorID, err := uuid.Parse(actor)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid actor_id format")
+			return
+		}
+		params.ActorID = uuid.NullUUID{UUID: actorID, Valid: true}
+	}
+	if action := query.Get("action"); action != "" {
+		params.Action.String, params.Action.Valid = action, true
+	}
+	if target := query.Get("target_id"); target != "" {
+		params.TargetID.String, params.TargetID.Valid = target, true
+	}
+	if since := query.Get("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "since must be an RFC 3339 timestamp")
+			return
+		}
+		params.Since.Time, params.Since.Valid = t, true
+	}
+	if until := query.Get("until"); until != "" {
+		t, err := time.Parse(time.RFC3339, until)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "until must be an RFC 3339 timestamp")
+			return
+		}
+		params.Until.Time, params.Until.Valid = t, true
+	}
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxAuditLimit {
+			respondWithError(w, http.StatusBadRequest, "limit must be between 1 and 1000")
+			return
+		}
+		params.Limit = int32(n)
+	}
+
+	dbEvents, err := cfg.dbQueries.ListAuditEvents(r.Context(), params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch audit events")
+		return
+	}
+
+	events := make([]model.AuditEvent, len(dbEvents))
+	for i, event := range dbEvents {
+		events[i] = model.AuditEvent{
+			ID:         event.ID,
+			CreatedAt:  event.CreatedAt,
+			Action:     event.Action,
+			TargetType: event.TargetType,
+			TargetID:   event.TargetID,
+			IPAddress:  event.IpAddress,
+			UserAgent:  event.UserAgent,
+			Metadata:   event.Metadata,
+		}
+		if event.ActorID.Valid {
+			actorID := event.ActorID.UUID
+			events[i].ActorID = &actorID
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, events)
+}
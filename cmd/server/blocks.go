@@ -0,0 +1,120 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/database"
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/model"
+	"github.com/google/uuid"
+)
+
+const (
+	auditActionUserBlocked   = "user.blocked"
+	auditActionUserUnblocked = "user.unblocked"
+)
+
+func (cfg *apiConfig) handlerBlockCreate(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateUser(w, r)
+	if !ok {
+		return
+	}
+
+	var params struct {
+		UserID uuid.UUID `json:"user_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil || params.UserID == uuid.Nil {
+		respondWithError(w, http.StatusBadRequest, "user_id is required")
+		return
+	}
+	if params.UserID == userID {
+		respondWithError(w, http.StatusBadRequest, "You can't block yourself")
+		return
+	}
+
+	if _, err := cfg.dbQueries.GetUserByID(r.Context(), params.UserID); err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Failed to get user")
+		return
+	}
+
+	err := cfg.dbQueries.BlockUser(r.Context(), database.BlockUserParams{
+		BlockerID: userID,
+		BlockedID: params.UserID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to block user")
+		return
+	}
+
+	cfg.recordAudit(r, auditEntry{
+		ActorID:    userID,
+		Action:     auditActionUserBlocked,
+		TargetType: "user",
+		TargetID:   params.UserID.String(),
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerBlocksList(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateUser(w, r)
+	if !ok {
+		return
+	}
+
+	dbBlocks, err := cfg.dbQueries.ListBlockedUsers(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch blocked users")
+		return
+	}
+
+	blocks := make([]model.UserBlock, len(dbBlocks))
+	for i, block := range dbBlocks {
+		blocks[i] = model.UserBlock{
+			UserID:    block.BlockedID,
+			CreatedAt: block.CreatedAt,
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, blocks)
+}
+
+func (cfg *apiConfig) handlerBlockDelete(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateUser(w, r)
+	if !ok {
+		return
+	}
+
+	blockedID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID format")
+		return
+	}
+
+	rows, err := cfg.dbQueries.UnblockUser(r.Context(), database.UnblockUserParams{
+		BlockerID: userID,
+		BlockedID: blockedID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to unblock user")
+		return
+	}
+	if rows == 0 {
+		respondWithError(w, http.StatusNotFound, "User is not blocked")
+		return
+	}
+
+	cfg.recordAudit(r, auditEntry{
+		ActorID:    userID,
+		Action:     auditActionUserUnblocked,
+		TargetType: "user",
+		TargetID:   blockedID.String(),
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
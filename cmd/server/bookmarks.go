@@ -0,0 +1,146 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/auth"
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/database"
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/model"
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/pagination"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultBookmarkLimit = 20
+	maxBookmarkLimit     = 100
+)
+
+func (cfg *apiConfig) handlerBookmarkCreate(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateScoped(w, r, auth.ScopeChirpsWrite)
+	if !ok {
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID format")
+		return
+	}
+
+	if _, err := cfg.dbQueries.GetChirpByID(r.Context(), chirpID); err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusNotFound, "Chirp not found")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Failed to get chirp")
+		return
+	}
+
+	err = cfg.dbQueries.CreateBookmark(r.Context(), database.CreateBookmarkParams{
+		UserID:  userID,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to bookmark chirp")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerBookmarkDelete also removes bookmarks of chirps that were deleted.
+func (cfg *apiConfig) handlerBookmarkDelete(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateScoped(w, r, auth.ScopeChirpsWrite)
+	if !ok {
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID format")
+		return
+	}
+
+	rows, err := cfg.dbQueries.DeleteBookmark(r.Context(), database.DeleteBookmarkParams{
+		UserID:  userID,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to remove bookmark")
+		return
+	}
+	if rows == 0 {
+		respondWithError(w, http.StatusNotFound, "Bookmark not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerBookmarksList returns the caller's bookmarks newest first. The next
+// page is requested with the next_cursor of the previous one.
+func (cfg *apiConfig) handlerBookmarksList(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateScoped(w, r, auth.ScopeChirpsRead)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	limit := defaultBookmarkLimit
+	if l := query.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > maxBookmarkLimit {
+			respondWithError(w, http.StatusBadRequest, "limit must be between 1 and 100")
+			return
+		}
+		limit = n
+	}
+
+	// one more than asked tells whether there is a next page
+	params := database.ListBookmarksParams{UserID: userID, Limit: int32(limit + 1)}
+	if c := query.Get("cursor"); c != "" {
+		cursor, err := pagination.Decode(c)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
+		params.CursorCreatedAt.Time, params.CursorCreatedAt.Valid = cursor.Time, true
+		params.CursorChirpID.UUID, params.CursorChirpID.Valid = cursor.ID, true
+	}
+
+	rows, err := cfg.dbQueries.ListBookmarks(r.Context(), params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch bookmarks")
+		return
+	}
+
+	page := model.BookmarkPage{Bookmarks: []model.Bookmark{}}
+	if len(rows) > limit {
+		rows = rows[:limit]
+		last := rows[limit-1]
+		page.NextCursor = pagination.Cursor{Time: last.CreatedAt, ID: last.ChirpID}.Encode()
+	}
+
+	for _, row := range rows {
+		bookmark := model.Bookmark{
+			ChirpID:   row.ChirpID,
+			CreatedAt: row.CreatedAt,
+		}
+		if row.UserID.Valid {
+			bookmark.Chirp = &model.Chirp{
+				ID:        row.ChirpID,
+				CreatedAt: row.ChirpCreatedAt.Time,
+				UpdatedAt: row.ChirpUpdatedAt.Time,
+				Body:      row.Body.String,
+				UserID:    row.UserID.UUID,
+			}
+		} else {
+			bookmark.Deleted = true
+		}
+		page.Bookmarks = append(page.Bookmarks, bookmark)
+	}
+
+	respondWithJSON(w, http.StatusOK, page)
+}
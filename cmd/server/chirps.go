@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/database"
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/model"
+	"github.com/google/uuid"
+)
+
+const maxChirpLength = 140
+
+// chirpParams is the body of POST /api/chirps and of the draft endpoints.
+// With PublishAt the chirp is scheduled instead of published.
+type chirpParams struct {
+	Body      string      `json:"body"`
+	Poll      *pollParams `json:"poll"`
+	PublishAt *time.Time  `json:"publish_at"`
+}
+
+// validate checks the poll against when the chirp will be published.
+func (p *chirpParams) validate(now time.Time) error {
+	if len(p.Body) > maxChirpLength {
+		return fmt.Errorf("chirps can only be %d characters long", maxChirpLength)
+	}
+	if p.PublishAt != nil {
+		if !p.PublishAt.After(now) {
+			return errors.New("publish_at must be in the future")
+		}
+		now = *p.PublishAt
+	}
+	if p.Poll != nil {
+		return p.Poll.validate(now)
+	}
+	return nil
+}
+
+// createChirp stores a chirp with its poll, with the queries of the caller's
+// transaction.
+func createChirp(ctx context.Context, q *database.Queries, userID uuid.UUID, body string, poll *pollParams) (model.Chirp, error) {
+	dbChirp, err := q.CreateChirp(ctx, database.CreateChirpParams{
+		Body:   cleanProfane(body),
+		UserID: userID,
+	})
+	if err != nil {
+		return model.Chirp{}, err
+	}
+
+	chirp := chirpToModel(dbChirp)
+	if poll != nil {
+		dbPoll, options, err := createPoll(ctx, q, chirp.ID, *poll)
+		if err != nil {
+			return model.Chirp{}, err
+		}
+		p := pollToModel(dbPoll, options, nil, true)
+		chirp.Poll = &p
+	}
+	return chirp, nil
+}
+
+// announceChirp tells webhooks, streams and mentioned users about a chirp
+// that was just published.
+func (cfg *apiConfig) announceChirp(ctx context.Context, chirp model.Chirp) {
+	cfg.emitWebhookEvent(ctx, chirp.UserID, eventChirpCreated, chirp)
+	cfg.publishEvent(eventChirpCreated, chirp.UserID, chirp.ID, chirp)
+	cfg.publishMentions(ctx, chirp)
+}
+
+// chirpToModel is how every endpoint that returns chirps turns them into
+// their JSON form.
+func chirpToModel(chirp database.Chirp) model.Chirp {
+	return model.Chirp{
+		ID:        chirp.ID,
+		CreatedAt: chirp.CreatedAt,
+		UpdatedAt: chirp.UpdatedAt,
+		Body:      chirp.Body,
+		UserID:    chirp.UserID,
+	}
+}
+
+func chirpsToModel(chirps []database.Chirp) []model.Chirp {
+	result := make([]model.Chirp, len(chirps))
+	for i, chirp := range chirps {
+		result[i] = chirpToModel(chirp)
+	}
+	return result
+}
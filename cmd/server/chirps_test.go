@@ -0,0 +1,40 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChirpParamsValidate(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Minute)
+	nextWeek := now.Add(7 * 24 * time.Hour)
+	poll := func(closesAt time.Time) *pollParams {
+		return &pollParams{Options: []string{"yes", "no"}, ClosesAt: closesAt}
+	}
+
+	tests := []struct {
+		name    string
+		params  chirpParams
+		wantErr bool
+	}{
+		{"plain", chirpParams{Body: "hello"}, false},
+		{"too long", chirpParams{Body: strings.Repeat("a", maxChirpLength+1)}, true},
+		{"scheduled", chirpParams{Body: "later", PublishAt: &nextWeek}, false},
+		{"scheduled in the past", chirpParams{Body: "later", PublishAt: &past}, true},
+		{"with poll", chirpParams{Body: "vote", Poll: poll(now.Add(time.Hour))}, false},
+		{"invalid poll", chirpParams{Body: "vote", Poll: &pollParams{Options: []string{"yes"}, ClosesAt: now.Add(time.Hour)}}, true},
+		// the poll's duration counts from when the chirp is published
+		{"scheduled poll closing before publishing", chirpParams{Body: "vote", PublishAt: &nextWeek, Poll: poll(now.Add(time.Hour))}, true},
+		{"scheduled poll", chirpParams{Body: "vote", PublishAt: &nextWeek, Poll: poll(nextWeek.Add(time.Hour))}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.params.validate(now); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,533 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/database"
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/model"
+	"github.com/google/uuid"
+)
+
+const (
+	// members of a conversation, its creator included
+	maxConversationMembers = 10
+	maxDirectMessageLength = 1000
+
+	defaultConversationLimit  = 50
+	defaultDirectMessageLimit = 50
+	maxConversationPageLimit  = 100
+)
+
+func (cfg *apiConfig) handlerConversationCreate(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateUser(w, r)
+	if !ok {
+		return
+	}
+	if !cfg.requireVerifiedEmail(w, r, userID, "Email address must be verified before sending messages") {
+		return
+	}
+
+	var params struct {
+		MemberIDs []uuid.UUID `json:"member_ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	seen := map[uuid.UUID]bool{userID: true}
+	var memberIDs []uuid.UUID
+	for _, memberID := range params.MemberIDs {
+		if !seen[memberID] {
+			seen[memberID] = true
+			memberIDs = append(memberIDs, memberID)
+		}
+	}
+	if len(memberIDs) == 0 {
+		respondWithError(w, http.StatusBadRequest, "member_ids must have at least one other user")
+		return
+	}
+	if len(memberIDs)+1 > maxConversationMembers {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Conversations can have at most %d members", maxConversationMembers))
+		return
+	}
+
+	for _, memberID := range memberIDs {
+		if _, err := cfg.dbQueries.GetUserByID(r.Context(), memberID); err != nil {
+			if err == sql.ErrNoRows {
+				respondWithError(w, http.StatusNotFound, "User not found")
+				return
+			}
+			respondWithError(w, http.StatusInternalServerError, "Failed to get user")
+			return
+		}
+	}
+
+	blocked, err := cfg.dbQueries.HasBlockBetween(r.Context(), database.HasBlockBetweenParams{
+		UserID:  userID,
+		UserIds: memberIDs,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to check blocks")
+		return
+	}
+	if blocked {
+		respondWithError(w, http.StatusForbidden, "You can't message a user you blocked or who blocked you")
+		return
+	}
+
+	conversation, created, err := cfg.createConversation(r.Context(), userID, memberIDs)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to create conversation")
+		return
+	}
+
+	response, err := cfg.conversationToModel(r.Context(), conversation, userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to get conversation")
+		return
+	}
+
+	status := http.StatusCreated
+	if !created {
+		// two users share a single one-to-one conversation
+		status = http.StatusOK
+	}
+	respondWithJSON(w, status, response)
+}
+
+// createConversation starts a conversation between userID and memberIDs, or
+// returns the one-to-one conversation the two users already have.
+func (cfg *apiConfig) createConversation(ctx context.Context, userID uuid.UUID, memberIDs []uuid.UUID) (conversation database.Conversation, created bool, err error) {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return database.Conversation{}, false, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	params := database.CreateConversationParams{
+		CreatorID: uuid.NullUUID{UUID: userID, Valid: true},
+		IsGroup:   len(memberIDs) > 1,
+	}
+	if !params.IsGroup {
+		params.DirectKey = sql.NullString{String: directConversationKey(userID, memberIDs[0]), Valid: true}
+	}
+
+	conversation, err = qtx.CreateConversation(ctx, params)
+	if err == sql.ErrNoRows {
+		conversation, err = qtx.GetConversationByDirectKey(ctx, params.DirectKey)
+		return conversation, false, err
+	}
+	if err != nil {
+		return database.Conversation{}, false, err
+	}
+
+	for _, memberID := range append([]uuid.UUID{userID}, memberIDs...) {
+		err := qtx.AddConversationMember(ctx, database.AddConversationMemberParams{
+			ConversationID: conversation.ID,
+			UserID:         memberID,
+		})
+		if err != nil {
+			return database.Conversation{}, false, err
+		}
+	}
+
+	return conversation, true, tx.Commit()
+}
+
+// directConversationKey is the same whichever of the two users starts the
+// conversation.
+func directConversationKey(a, b uuid.UUID) string {
+	if a.String() > b.String() {
+		a, b = b, a
+	}
+	return a.String() + ":" + b.String()
+}
+
+func (cfg *apiConfig) conversationToModel(ctx context.Context, conversation database.Conversation, userID uuid.UUID) (model.Conversation, error) {
+	members, err := cfg.dbQueries.ListConversationMembers(ctx, []uuid.UUID{conversation.ID})
+	if err != nil {
+		return model.Conversation{}, err
+	}
+	unread, err := cfg.dbQueries.CountUnreadDirectMessages(ctx, database.CountUnreadDirectMessagesParams{
+		ConversationID: conversation.ID,
+		UserID:         userID,
+	})
+	if err != nil {
+		return model.Conversation{}, err
+	}
+
+	return model.Conversation{
+		ID:          conversation.ID,
+		IsGroup:     conversation.IsGroup,
+		Members:     conversationMembersToModel(members),
+		UnreadCount: unread,
+		CreatedAt:   conversation.CreatedAt,
+		UpdatedAt:   conversation.UpdatedAt,
+	}, nil
+}
+
+func conversationMembersToModel(members []database.ConversationMember) []model.ConversationMember {
+	response := make([]model.ConversationMember, len(members))
+	for i, member := range members {
+		response[i] = model.ConversationMember{
+			UserID:   member.UserID,
+			JoinedAt: member.JoinedAt,
+		}
+		if member.LastReadAt.Valid {
+			lastReadAt := member.LastReadAt.Time
+			response[i].LastReadAt = &lastReadAt
+		}
+	}
+	return response
+}
+
+// getMemberConversation loads the conversation in the path. Users who aren't
+// in it get a 404, as if it didn't exist.
+func (cfg *apiConfig) getMemberConversation(w http.ResponseWriter, r *http.Request, userID uuid.UUID) (database.Conversation, bool) {
+	conversationID, err := uuid.Parse(r.PathValue("conversationID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid conversation ID format")
+		return database.Conversation{}, false
+	}
+
+	conversation, err := cfg.dbQueries.GetConversationForMember(r.Context(), database.GetConversationForMemberParams{
+		ID:     conversationID,
+		UserID: userID,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusNotFound, "Conversation not found")
+			return database.Conversation{}, false
+		}
+		respondWithError(w, http.StatusInternalServerError, "Failed to get conversation")
+		return database.Conversation{}, false
+	}
+	return conversation, true
+}
+
+// parseConversationPage reads the before and limit query parameters of the
+// paginated conversation endpoints.
+func parseConversationPage(w http.ResponseWriter, r *http.Request, defaultLimit int32) (before sql.NullTime, limit int32, ok bool) {
+	query := r.URL.Query()
+	limit = defaultLimit
+
+	if b := query.Get("before"); b != "" {
+		t, err := time.Parse(time.RFC3339Nano, b)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "before must be an RFC 3339 timestamp")
+			return sql.NullTime{}, 0, false
+		}
+		before = sql.NullTime{Time: t, Valid: true}
+	}
+	if l := query.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > maxConversationPageLimit {
+			respondWithError(w, http.StatusBadRequest, "limit must be between 1 and 100")
+			return sql.NullTime{}, 0, false
+		}
+		limit = int32(n)
+	}
+	return before, limit, true
+}
+
+func (cfg *apiConfig) handlerConversationsList(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateUser(w, r)
+	if !ok {
+		return
+	}
+
+	before, limit, ok := parseConversationPage(w, r, defaultConversationLimit)
+	if !ok {
+		return
+	}
+
+	dbConversations, err := cfg.dbQueries.ListConversationsForUser(r.Context(), database.ListConversationsForUserParams{
+		UserID: userID,
+		Before: before,
+		Limit:  limit,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch conversations")
+		return
+	}
+
+	ids := make([]uuid.UUID, len(dbConversations))
+	for i, conversation := range dbConversations {
+		ids[i] = conversation.ID
+	}
+	dbMembers, err := cfg.dbQueries.ListConversationMembers(r.Context(), ids)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch conversations")
+		return
+	}
+	members := map[uuid.UUID][]database.ConversationMember{}
+	for _, member := range dbMembers {
+		members[member.ConversationID] = append(members[member.ConversationID], member)
+	}
+
+	conversations := make([]model.Conversation, len(dbConversations))
+	for i, conversation := range dbConversations {
+		conversations[i] = model.Conversation{
+			ID:          conversation.ID,
+			IsGroup:     conversation.IsGroup,
+			Members:     conversationMembersToModel(members[conversation.ID]),
+			UnreadCount: conversation.UnreadCount,
+			CreatedAt:   conversation.CreatedAt,
+			UpdatedAt:   conversation.UpdatedAt,
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, conversations)
+}
+
+func (cfg *apiConfig) handlerConversationGet(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateUser(w, r)
+	if !ok {
+		return
+	}
+
+	conversation, ok := cfg.getMemberConversation(w, r, userID)
+	if !ok {
+		return
+	}
+
+	response, err := cfg.conversationToModel(r.Context(), conversation, userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to get conversation")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// handlerDirectMessageCreate sends a message. In a one-to-one conversation it
+// is refused once either user blocked the other. Group messages are not
+// refused, a block would otherwise silence both users in every group they
+// share; instead they are left out of the blocker's history and unread counts.
+func (cfg *apiConfig) handlerDirectMessageCreate(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateUser(w, r)
+	if !ok {
+		return
+	}
+
+	conversation, ok := cfg.getMemberConversation(w, r, userID)
+	if !ok {
+		return
+	}
+	if !cfg.requireVerifiedEmail(w, r, userID, "Email address must be verified before sending messages") {
+		return
+	}
+
+	var params struct {
+		Body string `json:"body"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if strings.TrimSpace(params.Body) == "" {
+		respondWithError(w, http.StatusBadRequest, "body is required")
+		return
+	}
+	if len(params.Body) > maxDirectMessageLength {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Messages can only be %d characters long", maxDirectMessageLength))
+		return
+	}
+
+	if !conversation.IsGroup {
+		members, err := cfg.dbQueries.ListConversationMembers(r.Context(), []uuid.UUID{conversation.ID})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Failed to get conversation")
+			return
+		}
+		var others []uuid.UUID
+		for _, member := range members {
+			if member.UserID != userID {
+				others = append(others, member.UserID)
+			}
+		}
+		blocked, err := cfg.dbQueries.HasBlockBetween(r.Context(), database.HasBlockBetweenParams{
+			UserID:  userID,
+			UserIds: others,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Failed to check blocks")
+			return
+		}
+		if blocked {
+			respondWithError(w, http.StatusForbidden, "You can't message a user you blocked or who blocked you")
+			return
+		}
+	}
+
+	message, err := cfg.createDirectMessage(r.Context(), conversation.ID, userID, params.Body)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to send message")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, model.DirectMessage{
+		ID:             message.ID,
+		ConversationID: message.ConversationID,
+		SenderID:       message.SenderID,
+		Body:           message.Body,
+		ReadBy:         []uuid.UUID{},
+		CreatedAt:      message.CreatedAt,
+	})
+}
+
+// createDirectMessage stores the message, moves the conversation to the top
+// of everyone's list and marks it read for the sender.
+func (cfg *apiConfig) createDirectMessage(ctx context.Context, conversationID, senderID uuid.UUID, body string) (database.DirectMessage, error) {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return database.DirectMessage{}, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	message, err := qtx.CreateDirectMessage(ctx, database.CreateDirectMessageParams{
+		ConversationID: conversationID,
+		SenderID:       senderID,
+		Body:           body,
+	})
+	if err != nil {
+		return database.DirectMessage{}, err
+	}
+
+	err = qtx.TouchConversation(ctx, database.TouchConversationParams{
+		ID:        conversationID,
+		UpdatedAt: message.CreatedAt,
+	})
+	if err != nil {
+		return database.DirectMessage{}, err
+	}
+
+	err = qtx.MarkConversationRead(ctx, database.MarkConversationReadParams{
+		ReadAt:         sql.NullTime{Time: message.CreatedAt, Valid: true},
+		ConversationID: conversationID,
+		UserID:         senderID,
+	})
+	if err != nil {
+		return database.DirectMessage{}, err
+	}
+
+	return message, tx.Commit()
+}
+
+// handlerDirectMessagesList returns the history newest first, a page at a
+// time: pass the created_at of the oldest message as before for the next one.
+func (cfg *apiConfig) handlerDirectMessagesList(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateUser(w, r)
+	if !ok {
+		return
+	}
+
+	conversation, ok := cfg.getMemberConversation(w, r, userID)
+	if !ok {
+		return
+	}
+
+	before, limit, ok := parseConversationPage(w, r, defaultDirectMessageLimit)
+	if !ok {
+		return
+	}
+
+	dbMessages, err := cfg.dbQueries.ListDirectMessages(r.Context(), database.ListDirectMessagesParams{
+		ConversationID: conversation.ID,
+		ViewerID:       userID,
+		Before:         before,
+		Limit:          limit,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch messages")
+		return
+	}
+	members, err := cfg.dbQueries.ListConversationMembers(r.Context(), []uuid.UUID{conversation.ID})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch messages")
+		return
+	}
+
+	messages := make([]model.DirectMessage, len(dbMessages))
+	for i, message := range dbMessages {
+		readBy := []uuid.UUID{}
+		for _, member := range members {
+			if member.UserID != message.SenderID && member.LastReadAt.Valid && !member.LastReadAt.Time.Before(message.CreatedAt) {
+				readBy = append(readBy, member.UserID)
+			}
+		}
+		messages[i] = model.DirectMessage{
+			ID:             message.ID,
+			ConversationID: message.ConversationID,
+			SenderID:       message.SenderID,
+			Body:           message.Body,
+			ReadBy:         readBy,
+			CreatedAt:      message.CreatedAt,
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, messages)
+}
+
+// handlerConversationRead moves the caller's read receipt to message_id, or
+// to now without a body.
+func (cfg *apiConfig) handlerConversationRead(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateUser(w, r)
+	if !ok {
+		return
+	}
+
+	conversation, ok := cfg.getMemberConversation(w, r, userID)
+	if !ok {
+		return
+	}
+
+	var params struct {
+		MessageID uuid.UUID `json:"message_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil && !errors.Is(err, io.EOF) {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	var readAt sql.NullTime
+	if params.MessageID != uuid.Nil {
+		message, err := cfg.dbQueries.GetDirectMessage(r.Context(), database.GetDirectMessageParams{
+			ID:             params.MessageID,
+			ConversationID: conversation.ID,
+		})
+		if err != nil {
+			if err == sql.ErrNoRows {
+				respondWithError(w, http.StatusNotFound, "Message not found")
+				return
+			}
+			respondWithError(w, http.StatusInternalServerError, "Failed to get message")
+			return
+		}
+		readAt = sql.NullTime{Time: message.CreatedAt, Valid: true}
+	}
+
+	err := cfg.dbQueries.MarkConversationRead(r.Context(), database.MarkConversationReadParams{
+		ReadAt:         readAt,
+		ConversationID: conversation.ID,
+		UserID:         userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to mark conversation as read")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestDirectConversationKey(t *testing.T) {
+	a := uuid.MustParse("11111111-1111-1111-1111-111111111111")
+	b := uuid.MustParse("22222222-2222-2222-2222-222222222222")
+
+	tests := []struct {
+		name string
+		x, y uuid.UUID
+		want string
+	}{
+		{"ordered", a, b, a.String() + ":" + b.String()},
+		{"reversed", b, a, a.String() + ":" + b.String()},
+		{"same user", a, a, a.String() + ":" + a.String()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := directConversationKey(tt.x, tt.y); got != tt.want {
+				t.Errorf("directConversationKey(%s, %s) = %q, want %q", tt.x, tt.y, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,436 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/auth"
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/database"
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/model"
+	"github.com/google/uuid"
+)
+
+const (
+	draftStatusDraft     = "draft"
+	draftStatusScheduled = "scheduled"
+)
+
+const (
+	// scheduled chirps published per run, the rest wait for the next one
+	scheduledChirpBatchSize = 100
+
+	// a scheduled chirp that fails to publish is tried again after the
+	// delay, and goes back to being a draft after the last attempt
+	scheduledChirpRetryDelay  = 5 * time.Minute
+	maxScheduledChirpAttempts = 5
+)
+
+func chirpDraftToModel(draft database.ChirpDraft) (model.ChirpDraft, error) {
+	result := model.ChirpDraft{
+		ID:        draft.ID,
+		Body:      draft.Body,
+		Status:    draftStatusDraft,
+		LastError: draft.LastError,
+		CreatedAt: draft.CreatedAt,
+		UpdatedAt: draft.UpdatedAt,
+	}
+	if draft.PublishAt.Valid {
+		result.Status = draftStatusScheduled
+		result.PublishAt = &draft.PublishAt.Time
+	}
+	if err := json.Unmarshal(draft.Poll, &result.Poll); err != nil {
+		return model.ChirpDraft{}, err
+	}
+	return result, nil
+}
+
+// draftPollParams turns the stored poll back into what createChirp takes.
+func draftPollParams(draft database.ChirpDraft) (*pollParams, error) {
+	var poll *pollParams
+	if err := json.Unmarshal(draft.Poll, &poll); err != nil {
+		return nil, err
+	}
+	return poll, nil
+}
+
+func draftPublishAt(params chirpParams) sql.NullTime {
+	if params.PublishAt == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: *params.PublishAt, Valid: true}
+}
+
+// saveChirpDraft stores already validated params as a draft, or as a
+// scheduled chirp when they have publish_at.
+func (cfg *apiConfig) saveChirpDraft(ctx context.Context, userID uuid.UUID, params chirpParams) (model.ChirpDraft, error) {
+	poll, err := json.Marshal(params.Poll)
+	if err != nil {
+		return model.ChirpDraft{}, err
+	}
+
+	draft, err := cfg.dbQueries.CreateChirpDraft(ctx, database.CreateChirpDraftParams{
+		UserID:    userID,
+		Body:      params.Body,
+		Poll:      poll,
+		PublishAt: draftPublishAt(params),
+	})
+	if err != nil {
+		return model.ChirpDraft{}, err
+	}
+	return chirpDraftToModel(draft)
+}
+
+// publishChirpDraft turns the draft take removes into a chirp, in one
+// transaction so a failure keeps the draft.
+func (cfg *apiConfig) publishChirpDraft(ctx context.Context, take func(q *database.Queries) (database.ChirpDraft, error)) (model.Chirp, error) {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.Chirp{}, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	draft, err := take(qtx)
+	if err != nil {
+		return model.Chirp{}, err
+	}
+	poll, err := draftPollParams(draft)
+	if err != nil {
+		return model.Chirp{}, err
+	}
+
+	chirp, err := createChirp(ctx, qtx, draft.UserID, draft.Body, poll)
+	if err != nil {
+		return model.Chirp{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return model.Chirp{}, err
+	}
+
+	cfg.announceChirp(ctx, chirp)
+	return chirp, nil
+}
+
+// runChirpScheduler publishes the scheduled chirps that are due every
+// interval.
+func (cfg *apiConfig) runChirpScheduler(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		cfg.publishDueChirps(context.Background())
+		<-ticker.C
+	}
+}
+
+func (cfg *apiConfig) publishDueChirps(ctx context.Context) {
+	for range scheduledChirpBatchSize {
+		var taken database.ChirpDraft
+		var invalidPoll error
+		_, err := cfg.publishChirpDraft(ctx, func(q *database.Queries) (database.ChirpDraft, error) {
+			draft, err := q.TakeDueScheduledChirp(ctx)
+			taken = draft
+			if err != nil {
+				return draft, err
+			}
+
+			// the poll was valid when it was scheduled, but closes_at may be
+			// too close by now, like for POST /api/drafts/{draftId}/publish
+			poll, err := draftPollParams(draft)
+			if err != nil {
+				return draft, err
+			}
+			if poll != nil {
+				if err := poll.validate(time.Now()); err != nil {
+					invalidPoll = err
+					return draft, err
+				}
+			}
+			return draft, nil
+		})
+		if err == sql.ErrNoRows {
+			return
+		}
+		if err == nil {
+			continue
+		}
+		if taken.ID == uuid.Nil {
+			// nothing was taken, the database itself is failing
+			log.Printf("Error taking scheduled chirp: %s", err)
+			return
+		}
+
+		// the failed chirp is still the one due the longest, it's pushed back
+		// so it doesn't hold up the others. last_error is shown to the
+		// author, the cause only goes to the log
+		log.Printf("Error publishing scheduled chirp %s: %s", taken.ID, err)
+		params := database.DeferScheduledChirpParams{
+			ID:          taken.ID,
+			LastError:   "Failed to publish, will retry",
+			MaxAttempts: maxScheduledChirpAttempts,
+			RetryAt:     time.Now().Add(scheduledChirpRetryDelay),
+		}
+		switch {
+		case invalidPoll != nil:
+			// retrying won't help, the author has to change the poll
+			params.LastError = "Poll is no longer valid: " + invalidPoll.Error()
+			params.MaxAttempts = 0
+		case taken.PublishAttempts+1 >= maxScheduledChirpAttempts:
+			params.LastError = "Failed to publish, moved back to drafts"
+		}
+		if err := cfg.dbQueries.DeferScheduledChirp(ctx, params); err != nil {
+			log.Printf("Error deferring scheduled chirp %s: %s", taken.ID, err)
+			return
+		}
+	}
+}
+
+// getDraft loads the caller's draft in the path. Other users' drafts get a
+// 404.
+func (cfg *apiConfig) getDraft(w http.ResponseWriter, r *http.Request, userID uuid.UUID) (database.ChirpDraft, bool) {
+	draftID, err := uuid.Parse(r.PathValue("draftID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid draft ID format")
+		return database.ChirpDraft{}, false
+	}
+
+	draft, err := cfg.dbQueries.GetChirpDraft(r.Context(), database.GetChirpDraftParams{
+		ID:     draftID,
+		UserID: userID,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusNotFound, "Draft not found")
+			return database.ChirpDraft{}, false
+		}
+		respondWithError(w, http.StatusInternalServerError, "Failed to get draft")
+		return database.ChirpDraft{}, false
+	}
+	return draft, true
+}
+
+func (cfg *apiConfig) handlerDraftCreate(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateScoped(w, r, auth.ScopeChirpsWrite)
+	if !ok {
+		return
+	}
+
+	var params chirpParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := params.validate(time.Now()); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	// the scheduler publishes without asking again
+	if params.PublishAt != nil && !cfg.requireVerifiedEmail(w, r, userID, "Email address must be verified before posting") {
+		return
+	}
+
+	draft, err := cfg.saveChirpDraft(r.Context(), userID, params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to save draft")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, draft)
+}
+
+// handlerDraftsList returns the caller's drafts and scheduled chirps, or only
+// one of them with status.
+func (cfg *apiConfig) handlerDraftsList(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateScoped(w, r, auth.ScopeChirpsRead)
+	if !ok {
+		return
+	}
+
+	params := database.ListChirpDraftsParams{UserID: userID}
+	switch r.URL.Query().Get("status") {
+	case "":
+	case draftStatusDraft:
+		params.Scheduled = sql.NullBool{Bool: false, Valid: true}
+	case draftStatusScheduled:
+		params.Scheduled = sql.NullBool{Bool: true, Valid: true}
+	default:
+		respondWithError(w, http.StatusBadRequest, "status must be draft or scheduled")
+		return
+	}
+
+	dbDrafts, err := cfg.dbQueries.ListChirpDrafts(r.Context(), params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch drafts")
+		return
+	}
+
+	drafts := make([]model.ChirpDraft, len(dbDrafts))
+	for i, dbDraft := range dbDrafts {
+		drafts[i], err = chirpDraftToModel(dbDraft)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Failed to fetch drafts")
+			return
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, drafts)
+}
+
+func (cfg *apiConfig) handlerDraftGet(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateScoped(w, r, auth.ScopeChirpsRead)
+	if !ok {
+		return
+	}
+
+	dbDraft, ok := cfg.getDraft(w, r, userID)
+	if !ok {
+		return
+	}
+
+	draft, err := chirpDraftToModel(dbDraft)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to get draft")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, draft)
+}
+
+// handlerDraftUpdate replaces the draft. Without publish_at a scheduled chirp
+// goes back to being a draft.
+func (cfg *apiConfig) handlerDraftUpdate(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateScoped(w, r, auth.ScopeChirpsWrite)
+	if !ok {
+		return
+	}
+
+	dbDraft, ok := cfg.getDraft(w, r, userID)
+	if !ok {
+		return
+	}
+
+	var params chirpParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := params.validate(time.Now()); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	// the scheduler publishes without asking again
+	if params.PublishAt != nil && !cfg.requireVerifiedEmail(w, r, userID, "Email address must be verified before posting") {
+		return
+	}
+
+	poll, err := json.Marshal(params.Poll)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to update draft")
+		return
+	}
+
+	updated, err := cfg.dbQueries.UpdateChirpDraft(r.Context(), database.UpdateChirpDraftParams{
+		ID:        dbDraft.ID,
+		UserID:    userID,
+		Body:      params.Body,
+		Poll:      poll,
+		PublishAt: draftPublishAt(params),
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			// published by the scheduler in the meantime
+			respondWithError(w, http.StatusNotFound, "Draft not found")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Failed to update draft")
+		return
+	}
+
+	draft, err := chirpDraftToModel(updated)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to update draft")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, draft)
+}
+
+func (cfg *apiConfig) handlerDraftDelete(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateScoped(w, r, auth.ScopeChirpsWrite)
+	if !ok {
+		return
+	}
+
+	draftID, err := uuid.Parse(r.PathValue("draftID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid draft ID format")
+		return
+	}
+
+	rows, err := cfg.dbQueries.DeleteChirpDraft(r.Context(), database.DeleteChirpDraftParams{
+		ID:     draftID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to delete draft")
+		return
+	}
+	if rows == 0 {
+		respondWithError(w, http.StatusNotFound, "Draft not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerDraftPublish publishes a draft, or a scheduled chirp ahead of time.
+// The poll must still close between 5 minutes and 7 days from now.
+func (cfg *apiConfig) handlerDraftPublish(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateScoped(w, r, auth.ScopeChirpsWrite)
+	if !ok {
+		return
+	}
+
+	if !cfg.requireVerifiedEmail(w, r, userID, "Email address must be verified before posting") {
+		return
+	}
+
+	draft, ok := cfg.getDraft(w, r, userID)
+	if !ok {
+		return
+	}
+
+	poll, err := draftPollParams(draft)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to publish draft")
+		return
+	}
+	if poll != nil {
+		if err := poll.validate(time.Now()); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	chirp, err := cfg.publishChirpDraft(r.Context(), func(q *database.Queries) (database.ChirpDraft, error) {
+		return q.TakeChirpDraft(r.Context(), database.TakeChirpDraftParams{
+			ID:     draft.ID,
+			UserID: userID,
+		})
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			// published by the scheduler in the meantime
+			respondWithError(w, http.StatusNotFound, "Draft not found")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Failed to publish draft")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, chirp)
+}
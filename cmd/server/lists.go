@@ -0,0 +1,474 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/auth"
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/database"
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/model"
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/pagination"
+	"github.com/google/uuid"
+)
+
+const (
+	maxListNameLength        = 50
+	maxListDescriptionLength = 160
+	maxListMembers           = 500
+
+	defaultListTimelineLimit = 20
+	maxListTimelineLimit     = 100
+)
+
+type listParams struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Private     bool   `json:"private"`
+}
+
+func (p *listParams) validate() error {
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(p.Name) > maxListNameLength {
+		return fmt.Errorf("name can only be %d characters long", maxListNameLength)
+	}
+	if len(p.Description) > maxListDescriptionLength {
+		return fmt.Errorf("description can only be %d characters long", maxListDescriptionLength)
+	}
+	return nil
+}
+
+func listToModel(list database.List) model.List {
+	return model.List{
+		ID:              list.ID,
+		OwnerID:         list.OwnerID,
+		Name:            list.Name,
+		Description:     list.Description,
+		Private:         list.IsPrivate,
+		MemberCount:     list.MemberCount,
+		SubscriberCount: list.SubscriberCount,
+		CreatedAt:       list.CreatedAt,
+		UpdatedAt:       list.UpdatedAt,
+	}
+}
+
+func listsToModel(lists []database.List) []model.List {
+	result := make([]model.List, len(lists))
+	for i, list := range lists {
+		result[i] = listToModel(list)
+	}
+	return result
+}
+
+// getVisibleList loads the list in the path. Private lists of other users get
+// a 404, as if they didn't exist.
+func (cfg *apiConfig) getVisibleList(w http.ResponseWriter, r *http.Request, userID uuid.UUID) (database.List, bool) {
+	listID, err := uuid.Parse(r.PathValue("listID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid list ID format")
+		return database.List{}, false
+	}
+
+	list, err := cfg.dbQueries.GetList(r.Context(), listID)
+	if err != nil && err != sql.ErrNoRows {
+		respondWithError(w, http.StatusInternalServerError, "Failed to get list")
+		return database.List{}, false
+	}
+	if err == sql.ErrNoRows || (list.IsPrivate && list.OwnerID != userID) {
+		respondWithError(w, http.StatusNotFound, "List not found")
+		return database.List{}, false
+	}
+	return list, true
+}
+
+// getOwnedList is getVisibleList for the endpoints that change the list.
+func (cfg *apiConfig) getOwnedList(w http.ResponseWriter, r *http.Request, userID uuid.UUID) (database.List, bool) {
+	list, ok := cfg.getVisibleList(w, r, userID)
+	if !ok {
+		return database.List{}, false
+	}
+	if list.OwnerID != userID {
+		respondWithError(w, http.StatusForbidden, "Only the owner of the list may change it")
+		return database.List{}, false
+	}
+	return list, true
+}
+
+func (cfg *apiConfig) handlerListCreate(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateScoped(w, r, auth.ScopeChirpsWrite)
+	if !ok {
+		return
+	}
+
+	var params listParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := params.validate(); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	list, err := cfg.dbQueries.CreateList(r.Context(), database.CreateListParams{
+		OwnerID:     userID,
+		Name:        params.Name,
+		Description: params.Description,
+		IsPrivate:   params.Private,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to create list")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, listToModel(list))
+}
+
+// handlerListsList returns the lists of owner_id, the caller by default. Only
+// the caller sees their own private lists.
+func (cfg *apiConfig) handlerListsList(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateScoped(w, r, auth.ScopeChirpsRead)
+	if !ok {
+		return
+	}
+
+	ownerID := userID
+	if owner := r.URL.Query().Get("owner_id"); owner != "" {
+		id, err := uuid.Parse(owner)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid owner_id format")
+			return
+		}
+		ownerID = id
+	}
+
+	lists, err := cfg.dbQueries.ListListsByOwner(r.Context(), database.ListListsByOwnerParams{
+		OwnerID:        ownerID,
+		IncludePrivate: ownerID == userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch lists")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, listsToModel(lists))
+}
+
+func (cfg *apiConfig) handlerListsSubscribed(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateScoped(w, r, auth.ScopeChirpsRead)
+	if !ok {
+		return
+	}
+
+	lists, err := cfg.dbQueries.ListSubscribedLists(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch lists")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, listsToModel(lists))
+}
+
+func (cfg *apiConfig) handlerListGet(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateScoped(w, r, auth.ScopeChirpsRead)
+	if !ok {
+		return
+	}
+
+	list, ok := cfg.getVisibleList(w, r, userID)
+	if !ok {
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, listToModel(list))
+}
+
+func (cfg *apiConfig) handlerListUpdate(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateScoped(w, r, auth.ScopeChirpsWrite)
+	if !ok {
+		return
+	}
+
+	list, ok := cfg.getOwnedList(w, r, userID)
+	if !ok {
+		return
+	}
+
+	var params listParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := params.validate(); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	updated, err := cfg.dbQueries.UpdateList(r.Context(), database.UpdateListParams{
+		ID:          list.ID,
+		OwnerID:     userID,
+		Name:        params.Name,
+		Description: params.Description,
+		IsPrivate:   params.Private,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to update list")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, listToModel(updated))
+}
+
+func (cfg *apiConfig) handlerListDelete(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateScoped(w, r, auth.ScopeChirpsWrite)
+	if !ok {
+		return
+	}
+
+	list, ok := cfg.getOwnedList(w, r, userID)
+	if !ok {
+		return
+	}
+
+	if _, err := cfg.dbQueries.DeleteList(r.Context(), database.DeleteListParams{ID: list.ID, OwnerID: userID}); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to delete list")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerListMembersGet(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateScoped(w, r, auth.ScopeChirpsRead)
+	if !ok {
+		return
+	}
+
+	list, ok := cfg.getVisibleList(w, r, userID)
+	if !ok {
+		return
+	}
+
+	dbMembers, err := cfg.dbQueries.ListListMembers(r.Context(), list.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch list members")
+		return
+	}
+
+	members := make([]model.ListMember, len(dbMembers))
+	for i, member := range dbMembers {
+		members[i] = model.ListMember{UserID: member.UserID, AddedAt: member.AddedAt}
+	}
+
+	respondWithJSON(w, http.StatusOK, members)
+}
+
+// handlerListMemberAdd adds a user to the list. Users who blocked the owner,
+// or were blocked by them, can't be added.
+func (cfg *apiConfig) handlerListMemberAdd(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateScoped(w, r, auth.ScopeChirpsWrite)
+	if !ok {
+		return
+	}
+
+	list, ok := cfg.getOwnedList(w, r, userID)
+	if !ok {
+		return
+	}
+
+	memberID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID format")
+		return
+	}
+	if list.MemberCount >= maxListMembers {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Lists can have at most %d members", maxListMembers))
+		return
+	}
+
+	if _, err := cfg.dbQueries.GetUserByID(r.Context(), memberID); err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Failed to get user")
+		return
+	}
+
+	blocked, err := cfg.dbQueries.HasBlockBetween(r.Context(), database.HasBlockBetweenParams{
+		UserID:  userID,
+		UserIds: []uuid.UUID{memberID},
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to check blocks")
+		return
+	}
+	if blocked {
+		respondWithError(w, http.StatusForbidden, "You can't add a user you blocked or who blocked you")
+		return
+	}
+
+	err = cfg.dbQueries.AddListMember(r.Context(), database.AddListMemberParams{
+		ListID: list.ID,
+		UserID: memberID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to add list member")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerListMemberRemove(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateScoped(w, r, auth.ScopeChirpsWrite)
+	if !ok {
+		return
+	}
+
+	list, ok := cfg.getOwnedList(w, r, userID)
+	if !ok {
+		return
+	}
+
+	memberID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID format")
+		return
+	}
+
+	rows, err := cfg.dbQueries.RemoveListMember(r.Context(), database.RemoveListMemberParams{
+		ListID: list.ID,
+		UserID: memberID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to remove list member")
+		return
+	}
+	if rows == 0 {
+		respondWithError(w, http.StatusNotFound, "User is not in the list")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerListTimeline returns the chirps of the list's members newest first.
+// The next page is requested with the next_cursor of the previous one.
+func (cfg *apiConfig) handlerListTimeline(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateScoped(w, r, auth.ScopeChirpsRead)
+	if !ok {
+		return
+	}
+
+	list, ok := cfg.getVisibleList(w, r, userID)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	limit := defaultListTimelineLimit
+	if l := query.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > maxListTimelineLimit {
+			respondWithError(w, http.StatusBadRequest, "limit must be between 1 and 100")
+			return
+		}
+		limit = n
+	}
+
+	// one more than asked tells whether there is a next page
+	params := database.ListTimelineParams{ListID: list.ID, ViewerID: userID, Limit: int32(limit + 1)}
+	if c := query.Get("cursor"); c != "" {
+		cursor, err := pagination.Decode(c)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
+		params.CursorCreatedAt.Time, params.CursorCreatedAt.Valid = cursor.Time, true
+		params.CursorID.UUID, params.CursorID.Valid = cursor.ID, true
+	}
+
+	dbChirps, err := cfg.dbQueries.ListTimeline(r.Context(), params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch list timeline")
+		return
+	}
+
+	var page model.ChirpPage
+	if len(dbChirps) > limit {
+		dbChirps = dbChirps[:limit]
+		last := dbChirps[limit-1]
+		page.NextCursor = pagination.Cursor{Time: last.CreatedAt, ID: last.ID}.Encode()
+	}
+	page.Chirps = chirpsToModel(dbChirps)
+	if err := cfg.attachPolls(r.Context(), userID, page.Chirps); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch list timeline")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, page)
+}
+
+func (cfg *apiConfig) handlerListSubscribe(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateScoped(w, r, auth.ScopeChirpsWrite)
+	if !ok {
+		return
+	}
+
+	list, ok := cfg.getVisibleList(w, r, userID)
+	if !ok {
+		return
+	}
+	if list.OwnerID == userID {
+		respondWithError(w, http.StatusBadRequest, "You can't subscribe to your own list")
+		return
+	}
+
+	err := cfg.dbQueries.SubscribeToList(r.Context(), database.SubscribeToListParams{
+		ListID: list.ID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to subscribe to list")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerListUnsubscribe works even after the list became private, so
+// subscribers can still leave it.
+func (cfg *apiConfig) handlerListUnsubscribe(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateScoped(w, r, auth.ScopeChirpsWrite)
+	if !ok {
+		return
+	}
+
+	listID, err := uuid.Parse(r.PathValue("listID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid list ID format")
+		return
+	}
+
+	rows, err := cfg.dbQueries.UnsubscribeFromList(r.Context(), database.UnsubscribeFromListParams{
+		ListID: listID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to unsubscribe from list")
+		return
+	}
+	if rows == 0 {
+		respondWithError(w, http.StatusNotFound, "Not subscribed to list")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
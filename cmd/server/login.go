@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/auth"
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/database"
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/model"
+	"github.com/google/uuid"
+)
+
+// completeLogin issues the access and refresh tokens for a user that has
+// passed every login step. method records how the last step was passed and
+// deviceName is the optional label the client gave to the new session.
+func (cfg *apiConfig) completeLogin(w http.ResponseWriter, r *http.Request, dbUser database.User, method, deviceName string) {
+	token, err := cfg.keyRing.MakeJWT(dbUser.ID, 1*time.Hour)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to create jwt")
+		return
+	}
+
+	refreshToken, storedToken, err := issueRefreshToken(r.Context(), cfg.dbQueries, dbUser.ID, sessionInfo{
+		FamilyID:   uuid.New(),
+		DeviceName: deviceName,
+		UserAgent:  r.UserAgent(),
+		IPAddress:  clientIP(r),
+		StartedAt:  time.Now(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to create refresh token")
+		return
+	}
+
+	user := model.User{
+		ID:            dbUser.ID,
+		CreatedAt:     dbUser.CreatedAt,
+		UpdatedAt:     dbUser.UpdatedAt,
+		Email:         dbUser.Email,
+		Token:         token,
+		RefreshToken:  refreshToken,
+		IsChirpyRed:   dbUser.IsChirpyRed,
+		EmailVerified: dbUser.EmailVerifiedAt.Valid,
+	}
+
+	cfg.clearLoginFailures(r, dbUser.Email)
+
+	cfg.recordAudit(r, auditEntry{
+		ActorID:    dbUser.ID,
+		Action:     auditActionLogin,
+		TargetType: "user",
+		TargetID:   dbUser.ID.String(),
+		Metadata:   map[string]any{"method": method},
+	})
+	cfg.recordAudit(r, auditEntry{
+		ActorID:    dbUser.ID,
+		Action:     auditActionRefreshTokenIssued,
+		TargetType: "user",
+		TargetID:   dbUser.ID.String(),
+		Metadata:   map[string]any{"family_id": storedToken.FamilyID, "expires_at": storedToken.ExpiresAt},
+	})
+
+	respondWithJSON(w, http.StatusOK, user)
+}
+
+// sessionInfo describes the session a refresh token belongs to. A session is
+// a token family: a login starts a new one and every rotation adds to it.
+type sessionInfo struct {
+	FamilyID   uuid.UUID
+	DeviceName string
+	UserAgent  string
+	IPAddress  string
+	StartedAt  time.Time
+	// set for the sessions of OAuth clients, see handlerOAuthToken
+	ClientID uuid.NullUUID
+	Scopes   []string
+}
+
+// issueRefreshToken creates a new refresh token in the given session. Only
+// the hash is stored.
+func issueRefreshToken(ctx context.Context, q *database.Queries, userID uuid.UUID, session sessionInfo) (string, database.RefreshToken, error) {
+	refreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		return "", database.RefreshToken{}, err
+	}
+
+	storedToken, err := q.StoreRefreshToken(ctx, database.StoreRefreshTokenParams{
+		TokenHash:        auth.HashToken(refreshToken),
+		UserID:           userID,
+		FamilyID:         session.FamilyID,
+		DeviceName:       session.DeviceName,
+		UserAgent:        session.UserAgent,
+		IpAddress:        session.IPAddress,
+		SessionStartedAt: session.StartedAt,
+		ClientID:         session.ClientID,
+		Scopes:           session.Scopes,
+	})
+	if err != nil {
+		return "", database.RefreshToken{}, err
+	}
+
+	return refreshToken, storedToken, nil
+}
+
+var errRefreshTokenReused = errors.New("refresh token was already rotated")
+
+// rotateRefreshToken replaces storedToken, which the caller has checked is
+// neither revoked nor expired, with a new token in the same session.
+//
+// A token that was already rotated is being replayed, so either the user or an
+// attacker holds a stolen copy: the whole session is revoked and
+// errRefreshTokenReused returned.
+func (cfg *apiConfig) rotateRefreshToken(r *http.Request, storedToken database.RefreshToken) (string, database.RefreshToken, error) {
+	if storedToken.RotatedAt.Valid {
+		cfg.revokeReusedRefreshTokenFamily(r, storedToken)
+		return "", database.RefreshToken{}, errRefreshTokenReused
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		return "", database.RefreshToken{}, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	rotated, err := qtx.RotateRefreshToken(r.Context(), storedToken.TokenHash)
+	if err != nil {
+		return "", database.RefreshToken{}, err
+	}
+	if rotated != 1 {
+		// another request rotated it between the read and the update
+		tx.Rollback()
+		cfg.revokeReusedRefreshTokenFamily(r, storedToken)
+		return "", database.RefreshToken{}, errRefreshTokenReused
+	}
+
+	newRefreshToken, newStoredToken, err := issueRefreshToken(r.Context(), qtx, storedToken.UserID, sessionInfo{
+		FamilyID:   storedToken.FamilyID,
+		DeviceName: storedToken.DeviceName,
+		UserAgent:  r.UserAgent(),
+		IPAddress:  clientIP(r),
+		StartedAt:  storedToken.SessionStartedAt,
+		ClientID:   storedToken.ClientID,
+		Scopes:     storedToken.Scopes,
+	})
+	if err != nil {
+		return "", database.RefreshToken{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", database.RefreshToken{}, err
+	}
+
+	cfg.recordAudit(r, auditEntry{
+		ActorID:    storedToken.UserID,
+		Action:     auditActionRefreshTokenRotated,
+		TargetType: "user",
+		TargetID:   storedToken.UserID.String(),
+		Metadata:   map[string]any{"family_id": storedToken.FamilyID, "expires_at": newStoredToken.ExpiresAt},
+	})
+
+	return newRefreshToken, newStoredToken, nil
+}
+
+func (cfg *apiConfig) revokeReusedRefreshTokenFamily(r *http.Request, storedToken database.RefreshToken) {
+	revoked, err := cfg.dbQueries.RevokeRefreshTokenFamily(r.Context(), storedToken.FamilyID)
+	if err != nil {
+		log.Printf("Error revoking refresh token family %s: %s", storedToken.FamilyID, err)
+	}
+	cfg.recordAudit(r, auditEntry{
+		ActorID:    storedToken.UserID,
+		Action:     auditActionRefreshTokenReused,
+		TargetType: "user",
+		TargetID:   storedToken.UserID.String(),
+		Metadata:   map[string]any{"family_id": storedToken.FamilyID, "revoked_tokens": revoked},
+	})
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/auth"
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/database"
+)
+
+const auditActionLoginLockedOut = "user.login_locked_out"
+
+// loginThrottleKeys returns the keys failed logins are counted under. The
+// account key exists for unknown emails too, so a lockout doesn't reveal
+// whether an account exists.
+func loginThrottleKeys(email, ip string) (accountKey, ipKey string) {
+	return "account:" + strings.ToLower(email), "ip:" + ip
+}
+
+// loginLockedFor returns how long logins for email from the request's address
+// are still refused, zero when they are allowed.
+func (cfg *apiConfig) loginLockedFor(ctx context.Context, r *http.Request, email string) (time.Duration, error) {
+	accountKey, ipKey := loginThrottleKeys(email, clientIP(r))
+	return cfg.throttleLockedFor(ctx, accountKey, ipKey)
+}
+
+// throttleLockedFor returns the longest lockout still active on any of keys,
+// zero when there is none.
+func (cfg *apiConfig) throttleLockedFor(ctx context.Context, keys ...string) (time.Duration, error) {
+	lockouts, err := cfg.dbQueries.ListActiveLoginLockouts(ctx, keys)
+	if err != nil {
+		return 0, err
+	}
+
+	var wait time.Duration
+	for _, lockout := range lockouts {
+		if remaining := time.Until(lockout.LockedUntil.Time); remaining > wait {
+			wait = remaining
+		}
+	}
+	return wait, nil
+}
+
+// recordLoginFailure counts a failed login for the account and the address
+// and locks them out once their policy says so. Failures are only logged, the
+// login is refused either way.
+func (cfg *apiConfig) recordLoginFailure(r *http.Request, email string) {
+	// the client may already be gone, the failure should still count
+	ctx := context.WithoutCancel(r.Context())
+	accountKey, ipKey := loginThrottleKeys(email, clientIP(r))
+
+	for key, policy := range map[string]auth.LockoutPolicy{
+		accountKey: auth.AccountLockoutPolicy,
+		ipKey:      auth.IPLockoutPolicy,
+	} {
+		failures, lockedUntil, err := cfg.countThrottled(ctx, key, policy)
+		if err != nil {
+			log.Printf("Error recording login failure for %s: %s", key, err)
+			continue
+		}
+		if lockedUntil.IsZero() {
+			continue
+		}
+
+		cfg.recordAudit(r, auditEntry{
+			Action:     auditActionLoginLockedOut,
+			TargetType: "login",
+			TargetID:   key,
+			Metadata:   map[string]any{"failures": failures, "locked_until": lockedUntil},
+		})
+	}
+}
+
+// countThrottled counts one more attempt under key and locks it out once
+// policy says so. lockedUntil is zero when no lockout applies.
+func (cfg *apiConfig) countThrottled(ctx context.Context, key string, policy auth.LockoutPolicy) (failures int32, lockedUntil time.Time, err error) {
+	throttle, err := cfg.dbQueries.RecordLoginFailure(ctx, key)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	delay := policy.Delay(int(throttle.Failures))
+	if delay == 0 {
+		return throttle.Failures, time.Time{}, nil
+	}
+
+	lockedUntil = time.Now().Add(delay)
+	err = cfg.dbQueries.SetLoginLockout(ctx, database.SetLoginLockoutParams{
+		Key:         key,
+		LockedUntil: sql.NullTime{Time: lockedUntil, Valid: true},
+	})
+	if err != nil {
+		return throttle.Failures, time.Time{}, err
+	}
+	return throttle.Failures, lockedUntil, nil
+}
+
+// clearLoginFailures forgets the failures of an account after a successful
+// login. The address keeps its count, it may be trying other accounts.
+func (cfg *apiConfig) clearLoginFailures(r *http.Request, email string) {
+	accountKey, _ := loginThrottleKeys(email, clientIP(r))
+	if err := cfg.dbQueries.ClearLoginThrottle(context.WithoutCancel(r.Context()), accountKey); err != nil {
+		log.Printf("Error clearing login failures for %s: %s", accountKey, err)
+	}
+}
+
+func respondWithLockedOut(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	respondWithError(w, http.StatusTooManyRequests, "Too many failed login attempts, try again later")
+}
@@ -3,9 +3,11 @@ package main
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
 	"sort"
 	"strings"
 	"sync/atomic"
@@ -14,6 +16,8 @@ import (
 	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/auth"
 	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/config"
 	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/database"
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/events"
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/mailer"
 	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/model"
 	"github.com/google/uuid"
 	"github.com/joho/godotenv"
@@ -21,8 +25,18 @@ import (
 )
 
 type apiConfig struct {
-	fileserverHits atomic.Int32
-	dbQueries      *database.Queries
+	fileserverHits  atomic.Int32
+	db              *sql.DB
+	dbQueries       *database.Queries
+	keyRing         *auth.KeyRing
+	mailer          mailer.Mailer
+	passwordHasher  *auth.PasswordHasher
+	passwordPolicy  *auth.PasswordPolicy
+	webhookVerifier *auth.WebhookVerifier
+	webhookClient   *http.Client
+	eventBus        *events.Bus
+	// passwordResetSends holds a slot for every reset email being sent
+	passwordResetSends chan struct{}
 }
 
 type errorResponse struct {
@@ -88,7 +102,7 @@ func main() {
 
 	cfg, err := config.LoadConfig()
 	if err != nil {
-		log.Fatal("Failed to load config: %v", err)
+		log.Fatalf("Failed to load config: %v", err)
 	}
 
 	db, err := sql.Open("postgres", cfg.DBURL)
@@ -99,12 +113,72 @@ func main() {
 
 	dbQueries := database.New(db)
 
+	keyRing := auth.NewHMACKeyRing(cfg.JWTSecret)
+	if cfg.JWTKeysDir != "" {
+		keyRing, err = auth.LoadKeyRing(cfg.JWTKeysDir, cfg.JWTActiveKeyID)
+		if err != nil {
+			log.Fatalf("Error loading jwt signing keys: %v", err)
+		}
+	}
+	keyRing.SetLeeway(cfg.JWTLeeway)
+
+	var appMailer mailer.Mailer
+	switch cfg.Mail.Mailer {
+	case "smtp":
+		appMailer = mailer.NewSMTPMailer(cfg.Mail.SMTPHost, cfg.Mail.SMTPPort, cfg.Mail.SMTPUsername, cfg.Mail.SMTPPassword, cfg.Mail.From)
+	default:
+		if cfg.Mail.LogFile == "" {
+			appMailer = mailer.NewLogMailer(os.Stdout)
+			break
+		}
+		appMailer, err = mailer.NewFileMailer(cfg.Mail.LogFile)
+		if err != nil {
+			log.Fatalf("Error opening mail log file: %v", err)
+		}
+	}
+
+	passwordPolicy := auth.NewPasswordPolicy(cfg.Password.MinLength)
+	if cfg.Password.BreachedPasswordsFile != "" {
+		breached, err := os.Open(cfg.Password.BreachedPasswordsFile)
+		if err != nil {
+			log.Fatalf("Error opening breached passwords file: %v", err)
+		}
+		err = passwordPolicy.LoadBreachedPasswords(breached)
+		breached.Close()
+		if err != nil {
+			log.Fatalf("Error reading breached passwords file: %v", err)
+		}
+	}
+
 	apiCfg := &apiConfig{
 		fileserverHits: atomic.Int32{},
-		dbQueries:      dbQueries}
+		db:             db,
+		dbQueries:      dbQueries,
+		keyRing:        keyRing,
+		mailer:         appMailer,
+		passwordHasher: auth.NewPasswordHasher(auth.Argon2Params{
+			Memory:      cfg.Password.Argon2Memory,
+			Iterations:  cfg.Password.Argon2Iterations,
+			Parallelism: cfg.Password.Argon2Parallelism,
+			SaltLength:  auth.DefaultArgon2Params.SaltLength,
+			KeyLength:   auth.DefaultArgon2Params.KeyLength,
+		}),
+		passwordPolicy: passwordPolicy,
+		webhookVerifier: auth.NewWebhookVerifier(cfg.PolkaKey, cfg.PolkaWebhookTolerance,
+			webhookReplayStore{dbQueries: dbQueries}),
+		webhookClient: newWebhookClient(),
+		eventBus:      events.NewBus(streamHistorySize),
+
+		passwordResetSends: make(chan struct{}, maxPendingPasswordResetEmails),
+	}
 
 	mux := http.NewServeMux()
 
+	mux.HandleFunc("GET /.well-known/jwks.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		respondWithJSON(w, http.StatusOK, keyRing.JWKS())
+	})
+
 	mux.HandleFunc("GET /api/healthz", func(w http.ResponseWriter, r *http.Request) {
 
 		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
@@ -115,51 +189,56 @@ func main() {
 
 	mux.HandleFunc("POST /api/chirps", func(w http.ResponseWriter, r *http.Request) {
 
-		type requestBody struct {
-			Body string `json:"body"`
-		}
-
-		tokenString, err := auth.GetBearerToken(r.Header)
-		if err != nil {
-			respondWithError(w, http.StatusUnauthorized, "Missing or invalid Authorization header")
+		userID, ok := apiCfg.authenticateScoped(w, r, auth.ScopeChirpsWrite)
+		if !ok {
 			return
 		}
 
-		userID, err := auth.ValidateJWT(tokenString, cfg.JWTSecret)
-		if err != nil {
-			respondWithError(w, http.StatusUnauthorized, "Expired or invalid jwt token")
+		if !apiCfg.requireVerifiedEmail(w, r, userID, "Email address must be verified before posting") {
 			return
 		}
 
 		decoder := json.NewDecoder(r.Body)
-		decodeData := requestBody{}
+		decodeData := chirpParams{}
 		err = decoder.Decode(&decodeData)
 		if err != nil {
 			respondWithError(w, http.StatusBadRequest, "invalid request body")
 			return
 		}
-		if len(decodeData.Body) > 140 {
-			respondWithError(w, http.StatusBadRequest, "chirps can only be 140 characters long")
+		if err := decodeData.validate(time.Now()); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
 			return
 		}
 
-		censoredBody := cleanProfane(decodeData.Body)
-		dbChirp, err := apiCfg.dbQueries.CreateChirp(r.Context(), database.CreateChirpParams{
-			Body:   censoredBody,
-			UserID: userID,
-		})
+		// scheduled chirps wait as drafts until the scheduler publishes them
+		if decodeData.PublishAt != nil {
+			draft, err := apiCfg.saveChirpDraft(r.Context(), userID, decodeData)
+			if err != nil {
+				respondWithError(w, http.StatusInternalServerError, "failed to schedule chirp")
+				return
+			}
+			respondWithJSON(w, http.StatusAccepted, draft)
+			return
+		}
+
+		tx, err := apiCfg.db.BeginTx(r.Context(), nil)
 		if err != nil {
 			respondWithError(w, http.StatusInternalServerError, "failed to create chirp")
 			return
 		}
+		defer tx.Rollback()
 
-		chirp := model.Chirp{
-			ID:        dbChirp.ID,
-			CreatedAt: dbChirp.CreatedAt,
-			UpdatedAt: dbChirp.UpdatedAt,
-			Body:      dbChirp.Body,
-			UserID:    dbChirp.UserID,
+		chirp, err := createChirp(r.Context(), apiCfg.dbQueries.WithTx(tx), userID, decodeData.Body, decodeData.Poll)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "failed to create chirp")
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "failed to create chirp")
+			return
 		}
+
+		apiCfg.announceChirp(r.Context(), chirp)
 		respondWithJSON(w, http.StatusCreated, chirp)
 
 	})
@@ -181,17 +260,10 @@ func main() {
 				return
 			}
 
-			resultChirps := make([]model.Chirp, len(dbChirpsByAuthor))
-
-			for i, chirp := range dbChirpsByAuthor {
-				resultChirps[i] = model.Chirp{
-					ID:        chirp.ID,
-					CreatedAt: chirp.CreatedAt,
-					UpdatedAt: chirp.UpdatedAt,
-					Body:      chirp.Body,
-					UserID:    chirp.UserID,
-				}
-
+			resultChirps := chirpsToModel(dbChirpsByAuthor)
+			if err := apiCfg.attachPolls(r.Context(), uuid.Nil, resultChirps); err != nil {
+				respondWithError(w, http.StatusInternalServerError, "Failed to fetch author chirps")
+				return
 			}
 
 			if sortQuery == "desc" {
@@ -203,6 +275,10 @@ func main() {
 					return resultChirps[i].CreatedAt.Before(resultChirps[j].CreatedAt)
 				})
 			}
+			if err := apiCfg.pinnedChirpFirst(r.Context(), authorID, resultChirps); err != nil {
+				respondWithError(w, http.StatusInternalServerError, "Failed to fetch author chirps")
+				return
+			}
 
 			respondWithJSON(w, http.StatusOK, resultChirps)
 			return
@@ -214,17 +290,12 @@ func main() {
 			return
 		}
 		log.Printf("dbChirps: %+v", dbChirps)
-		chirps := make([]model.Chirp, len(dbChirps))
-		for i, chirp := range dbChirps {
-			chirps[i] = model.Chirp{
-				ID:        chirp.ID,
-				CreatedAt: chirp.CreatedAt,
-				UpdatedAt: chirp.UpdatedAt,
-				Body:      chirp.Body,
-				UserID:    chirp.UserID,
-			}
-		}
+		chirps := chirpsToModel(dbChirps)
 		log.Printf("chirps: %+v", chirps)
+		if err := apiCfg.attachPolls(r.Context(), uuid.Nil, chirps); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Failed to fetch chirps")
+			return
+		}
 
 		if sortQuery == "desc" {
 			sort.Slice(chirps, func(i, j int) bool {
@@ -238,6 +309,90 @@ func main() {
 		respondWithJSON(w, http.StatusOK, chirps)
 	})
 
+	mux.HandleFunc("GET /api/stream", apiCfg.handlerStream)
+
+	mux.HandleFunc("GET /api/ws", apiCfg.handlerWebSocket)
+
+	mux.HandleFunc("GET /api/notifications", apiCfg.handlerNotificationsList)
+
+	mux.HandleFunc("GET /api/notifications/unread_count", apiCfg.handlerNotificationsUnreadCount)
+
+	mux.HandleFunc("POST /api/notifications/read", apiCfg.handlerNotificationsReadAll)
+
+	mux.HandleFunc("POST /api/notifications/{notificationID}/read", apiCfg.handlerNotificationRead)
+
+	mux.HandleFunc("GET /api/notifications/preferences", apiCfg.handlerNotificationPreferencesGet)
+
+	mux.HandleFunc("PUT /api/notifications/preferences", apiCfg.handlerNotificationPreferencesUpdate)
+
+	mux.HandleFunc("POST /api/chirps/{chirpID}/bookmark", apiCfg.handlerBookmarkCreate)
+
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/bookmark", apiCfg.handlerBookmarkDelete)
+
+	mux.HandleFunc("GET /api/chirps/{chirpID}/poll", apiCfg.handlerPollGet)
+
+	mux.HandleFunc("POST /api/chirps/{chirpID}/poll/vote", apiCfg.handlerPollVote)
+
+	mux.HandleFunc("PUT /api/chirps/{chirpID}/pin", apiCfg.handlerChirpPin)
+
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/pin", apiCfg.handlerChirpUnpin)
+
+	mux.HandleFunc("POST /api/drafts", apiCfg.handlerDraftCreate)
+
+	mux.HandleFunc("GET /api/drafts", apiCfg.handlerDraftsList)
+
+	mux.HandleFunc("GET /api/drafts/{draftID}", apiCfg.handlerDraftGet)
+
+	mux.HandleFunc("PUT /api/drafts/{draftID}", apiCfg.handlerDraftUpdate)
+
+	mux.HandleFunc("DELETE /api/drafts/{draftID}", apiCfg.handlerDraftDelete)
+
+	mux.HandleFunc("POST /api/drafts/{draftID}/publish", apiCfg.handlerDraftPublish)
+
+	mux.HandleFunc("GET /api/bookmarks", apiCfg.handlerBookmarksList)
+
+	mux.HandleFunc("POST /api/me/blocks", apiCfg.handlerBlockCreate)
+
+	mux.HandleFunc("GET /api/me/blocks", apiCfg.handlerBlocksList)
+
+	mux.HandleFunc("DELETE /api/me/blocks/{userID}", apiCfg.handlerBlockDelete)
+
+	mux.HandleFunc("POST /api/lists", apiCfg.handlerListCreate)
+
+	mux.HandleFunc("GET /api/lists", apiCfg.handlerListsList)
+
+	mux.HandleFunc("GET /api/lists/subscriptions", apiCfg.handlerListsSubscribed)
+
+	mux.HandleFunc("GET /api/lists/{listID}", apiCfg.handlerListGet)
+
+	mux.HandleFunc("PUT /api/lists/{listID}", apiCfg.handlerListUpdate)
+
+	mux.HandleFunc("DELETE /api/lists/{listID}", apiCfg.handlerListDelete)
+
+	mux.HandleFunc("GET /api/lists/{listID}/members", apiCfg.handlerListMembersGet)
+
+	mux.HandleFunc("PUT /api/lists/{listID}/members/{userID}", apiCfg.handlerListMemberAdd)
+
+	mux.HandleFunc("DELETE /api/lists/{listID}/members/{userID}", apiCfg.handlerListMemberRemove)
+
+	mux.HandleFunc("GET /api/lists/{listID}/timeline", apiCfg.handlerListTimeline)
+
+	mux.HandleFunc("POST /api/lists/{listID}/subscription", apiCfg.handlerListSubscribe)
+
+	mux.HandleFunc("DELETE /api/lists/{listID}/subscription", apiCfg.handlerListUnsubscribe)
+
+	mux.HandleFunc("POST /api/conversations", apiCfg.handlerConversationCreate)
+
+	mux.HandleFunc("GET /api/conversations", apiCfg.handlerConversationsList)
+
+	mux.HandleFunc("GET /api/conversations/{conversationID}", apiCfg.handlerConversationGet)
+
+	mux.HandleFunc("POST /api/conversations/{conversationID}/messages", apiCfg.handlerDirectMessageCreate)
+
+	mux.HandleFunc("GET /api/conversations/{conversationID}/messages", apiCfg.handlerDirectMessagesList)
+
+	mux.HandleFunc("POST /api/conversations/{conversationID}/read", apiCfg.handlerConversationRead)
+
 	mux.HandleFunc("GET /api/chirps/{chirpId}", func(w http.ResponseWriter, r *http.Request) {
 
 		chirpIdNotValidated := r.PathValue("chirpId")
@@ -253,28 +408,20 @@ func main() {
 			return
 		}
 
-		chirp := model.Chirp{
-			ID:        chirpInDB.ID,
-			CreatedAt: chirpInDB.CreatedAt,
-			UpdatedAt: chirpInDB.UpdatedAt,
-			Body:      chirpInDB.Body,
-			UserID:    chirpInDB.UserID,
+		chirps := []model.Chirp{chirpToModel(chirpInDB)}
+		if err := apiCfg.attachPolls(r.Context(), uuid.Nil, chirps); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Failed to get chirp poll")
+			return
 		}
-		respondWithJSON(w, http.StatusOK, chirp)
+
+		respondWithJSON(w, http.StatusOK, chirps[0])
 
 	})
 
 	mux.HandleFunc("DELETE /api/chirps/{chirpID}", func(w http.ResponseWriter, r *http.Request) {
 
-		tokenString, err := auth.GetBearerToken(r.Header)
-		if err != nil {
-			respondWithError(w, http.StatusUnauthorized, "Missing or invalid Authorization token")
-			return
-		}
-
-		userID, err := auth.ValidateJWT(tokenString, cfg.JWTSecret)
-		if err != nil {
-			respondWithError(w, http.StatusUnauthorized, "Expired or invalid jwt token")
+		userID, ok := apiCfg.authenticateScoped(w, r, auth.ScopeChirpsWrite)
+		if !ok {
 			return
 		}
 
@@ -307,7 +454,21 @@ func main() {
 		})
 		if err != nil {
 			respondWithError(w, http.StatusInternalServerError, "Failed to delete chirp")
+			return
+		}
+
+		apiCfg.recordAudit(r, auditEntry{
+			ActorID:    userID,
+			Action:     auditActionChirpDeleted,
+			TargetType: "chirp",
+			TargetID:   chirpValidated.ID.String(),
+		})
+		deleted := map[string]any{
+			"id":      chirpValidated.ID,
+			"user_id": chirpValidated.UserID,
 		}
+		apiCfg.emitWebhookEvent(r.Context(), userID, eventChirpDeleted, deleted)
+		apiCfg.publishEvent(eventChirpDeleted, userID, chirpValidated.ID, deleted)
 
 		w.WriteHeader(http.StatusNoContent)
 
@@ -327,18 +488,33 @@ func main() {
 			return
 		}
 
-		hashPassword, err := auth.HashPassword(req.Password)
+		email, err := mailer.NormalizeAddress(req.Email)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid email address")
+			return
+		}
+
+		if !apiCfg.checkPasswordPolicy(w, req.Password) {
+			return
+		}
+
+		hashPassword, err := apiCfg.passwordHasher.Hash(req.Password)
 		if err != nil {
 			respondWithError(w, http.StatusInternalServerError, "Failed to hash password")
 			return
 		}
 
-		dbUser, err := apiCfg.dbQueries.CreateUser(r.Context(), database.CreateUserParams{Email: req.Email, HashedPassword: hashPassword})
+		dbUser, err := apiCfg.dbQueries.CreateUser(r.Context(), database.CreateUserParams{Email: email, HashedPassword: hashPassword})
 		if err != nil {
 			respondWithError(w, http.StatusInternalServerError, "Failed to create user")
 			return
 		}
 
+		// the account is usable without it, the user can ask for a new email later
+		if err := apiCfg.sendVerificationEmail(r.Context(), dbUser.ID, dbUser.Email); err != nil {
+			log.Printf("Error sending verification email to user %s: %s", dbUser.ID, err)
+		}
+
 		user := model.User{
 			ID:          dbUser.ID,
 			CreatedAt:   dbUser.CreatedAt,
@@ -354,37 +530,82 @@ func main() {
 
 	mux.HandleFunc("PUT /api/users", func(w http.ResponseWriter, r *http.Request) {
 		type requestBody struct {
-			NewEmail    string `json:"email"`
-			NewPassword string `json:"password"`
+			CurrentPassword string `json:"current_password"`
+			NewEmail        string `json:"email"`
+			NewPassword     string `json:"password"`
 		}
-		tokenString, err := auth.GetBearerToken(r.Header)
-		if err != nil {
-			respondWithError(w, http.StatusUnauthorized, "Missing or invalid Authorization header")
+		// credentials are only changed from a login, never with a personal
+		// access token or an OAuth client's token
+		userID, ok := apiCfg.authenticateUser(w, r)
+		if !ok {
 			return
 		}
 
-		userID, err := auth.ValidateJWT(tokenString, cfg.JWTSecret)
+		var req requestBody
+		err := json.NewDecoder(r.Body).Decode(&req)
 		if err != nil {
-			respondWithError(w, http.StatusUnauthorized, "Expired or invalid jwt token")
+			respondWithError(w, http.StatusBadRequest, "invalid request body")
 			return
 		}
 
-		var req requestBody
-		err = json.NewDecoder(r.Body).Decode(&req)
+		currentUser, err := apiCfg.dbQueries.GetUserByID(r.Context(), userID)
 		if err != nil {
-			respondWithError(w, http.StatusBadRequest, "invalid request body")
+			respondWithError(w, http.StatusInternalServerError, "Failed to get the user")
 			return
 		}
 
-		hashedPassword, err := auth.HashPassword(req.NewPassword)
+		// a missing field keeps its current value
+		newEmail := currentUser.Email
+		if req.NewEmail != "" {
+			newEmail, err = mailer.NormalizeAddress(req.NewEmail)
+			if err != nil {
+				respondWithError(w, http.StatusBadRequest, "Invalid email address")
+				return
+			}
+		}
+		hashedPassword := currentUser.HashedPassword
+		passwordChanged := req.NewPassword != ""
+		if passwordChanged {
+			if !apiCfg.checkPasswordPolicy(w, req.NewPassword) {
+				return
+			}
+			hashedPassword, err = apiCfg.passwordHasher.Hash(req.NewPassword)
+			if err != nil {
+				respondWithError(w, http.StatusInternalServerError, "failed to hash the password")
+				return
+			}
+		}
+
+		// whoever holds a stolen access token must not be able to take the
+		// account over, so changing a credential needs the current password
+		credentialsChanged := passwordChanged || newEmail != currentUser.Email
+		if credentialsChanged {
+			if req.CurrentPassword == "" {
+				respondWithError(w, http.StatusBadRequest, "current_password is required to change the email or password")
+				return
+			}
+			validPassword, err := apiCfg.checkPassword(r.Context(), currentUser, req.CurrentPassword)
+			if err != nil {
+				respondWithError(w, http.StatusInternalServerError, "Failed to verify the password")
+				return
+			}
+			if !validPassword {
+				respondWithError(w, http.StatusUnauthorized, "Current password is incorrect")
+				return
+			}
+		}
+
+		tx, err := apiCfg.db.BeginTx(r.Context(), nil)
 		if err != nil {
-			respondWithError(w, http.StatusInternalServerError, "failed to hash the password")
+			respondWithError(w, http.StatusInternalServerError, "Failed to update the user")
 			return
 		}
+		defer tx.Rollback()
+		qtx := apiCfg.dbQueries.WithTx(tx)
 
-		updatedUser, err := apiCfg.dbQueries.UpdateUser(r.Context(), database.UpdateUserParams{
+		updatedUser, err := qtx.UpdateUser(r.Context(), database.UpdateUserParams{
 			ID:             userID,
-			Email:          req.NewEmail,
+			Email:          newEmail,
 			HashedPassword: hashedPassword,
 		})
 		if err != nil {
@@ -392,14 +613,74 @@ func main() {
 			return
 		}
 
+		// every other session and token was issued for the old credentials
+		var revoked, revokedAccessTokens int64
+		if credentialsChanged {
+			revoked, err = qtx.RevokeAllRefreshTokensForUser(r.Context(), userID)
+			if err != nil {
+				respondWithError(w, http.StatusInternalServerError, "Failed to revoke refresh tokens")
+				return
+			}
+
+			revokedAccessTokens, err = qtx.RevokeAllPersonalAccessTokensForUser(r.Context(), userID)
+			if err != nil {
+				respondWithError(w, http.StatusInternalServerError, "Failed to revoke access tokens")
+				return
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Failed to update the user")
+			return
+		}
+
+		if currentUser.Email != updatedUser.Email {
+			apiCfg.recordAudit(r, auditEntry{
+				ActorID:    userID,
+				Action:     auditActionEmailChanged,
+				TargetType: "user",
+				TargetID:   userID.String(),
+				Metadata:   map[string]any{"old_email": currentUser.Email, "new_email": updatedUser.Email},
+			})
+			if err := apiCfg.sendVerificationEmail(r.Context(), userID, updatedUser.Email); err != nil {
+				log.Printf("Error sending verification email to user %s: %s", userID, err)
+			}
+		}
+		if passwordChanged {
+			apiCfg.recordAudit(r, auditEntry{
+				ActorID:    userID,
+				Action:     auditActionPasswordChanged,
+				TargetType: "user",
+				TargetID:   userID.String(),
+				Metadata:   map[string]any{"revoked_refresh_tokens": revoked, "revoked_access_tokens": revokedAccessTokens},
+			})
+		}
+		// OAuth clients get this event too, the email stays out of it
+		apiCfg.emitWebhookEvent(r.Context(), userID, eventUserUpdated, map[string]any{
+			"id":            updatedUser.ID,
+			"is_chirpy_red": updatedUser.IsChirpyRed,
+			"updated_at":    updatedUser.UpdatedAt,
+		})
+
 		respondWithJSON(w, http.StatusOK, updatedUser)
 	})
 
+	mux.HandleFunc("GET /api/users/{userID}", apiCfg.handlerProfileGet)
+
+	mux.HandleFunc("POST /api/users/verify", apiCfg.handlerUsersVerify)
+
+	mux.HandleFunc("POST /api/users/verify/resend", apiCfg.handlerUsersVerifyResend)
+
+	mux.HandleFunc("POST /api/password/forgot", apiCfg.handlerPasswordForgot)
+
+	mux.HandleFunc("POST /api/password/reset", apiCfg.handlerPasswordReset)
+
 	mux.HandleFunc("POST /api/login", func(w http.ResponseWriter, r *http.Request) {
 
 		type requestBody struct {
-			Email    string `json:"email"`
-			Password string `json:"password"`
+			Email      string `json:"email"`
+			Password   string `json:"password"`
+			DeviceName string `json:"device_name"`
 		}
 
 		decoder := json.NewDecoder(r.Body)
@@ -410,53 +691,83 @@ func main() {
 			return
 		}
 
-		dbUser, err := apiCfg.dbQueries.GetUserByEmail(r.Context(), req.Email)
+		// accounts created before validation may not have a normalized email
+		email, err := mailer.NormalizeAddress(req.Email)
 		if err != nil {
-			respondWithError(w, http.StatusNotFound, "User not found")
-			return
+			email = req.Email
 		}
 
-		err = auth.CheckPasswordHash(dbUser.HashedPassword, req.Password)
+		wait, err := apiCfg.loginLockedFor(r.Context(), r, email)
 		if err != nil {
-			respondWithError(w, http.StatusUnauthorized, "Invalid password")
+			respondWithError(w, http.StatusInternalServerError, "Failed to check login attempts")
+			return
+		}
+		if wait > 0 {
+			apiCfg.recordAudit(r, auditEntry{
+				Action:   auditActionLoginFailed,
+				Metadata: map[string]any{"email": req.Email, "reason": "locked_out"},
+			})
+			respondWithLockedOut(w, wait)
 			return
 		}
 
-		token, err := auth.MakeJWT(dbUser.ID, cfg.JWTSecret, 1*time.Hour)
+		// unknown emails and wrong passwords get the same answer, in the same
+		// time, so accounts can't be enumerated
+		dbUser, err := apiCfg.dbQueries.GetUserByEmail(r.Context(), email)
 		if err != nil {
-			respondWithError(w, http.StatusInternalServerError, "Failed to create jwt")
+			if err != sql.ErrNoRows {
+				respondWithError(w, http.StatusInternalServerError, "Failed to get user")
+				return
+			}
+			apiCfg.passwordHasher.VerifyUnknownUser(req.Password)
+			apiCfg.recordAudit(r, auditEntry{
+				Action:   auditActionLoginFailed,
+				Metadata: map[string]any{"email": req.Email, "reason": "user_not_found"},
+			})
+			apiCfg.recordLoginFailure(r, email)
+			respondWithError(w, http.StatusUnauthorized, "Invalid email or password")
 			return
 		}
 
-		refreshToken, err := auth.MakeRefreshToken()
+		validPassword, err := apiCfg.checkPassword(r.Context(), dbUser, req.Password)
 		if err != nil {
-			respondWithError(w, http.StatusInternalServerError, "Failed to create refreshToken")
+			respondWithError(w, http.StatusInternalServerError, "Failed to check password")
+			return
+		}
+		if !validPassword {
+			apiCfg.recordAudit(r, auditEntry{
+				Action:     auditActionLoginFailed,
+				TargetType: "user",
+				TargetID:   dbUser.ID.String(),
+				Metadata:   map[string]any{"email": req.Email, "reason": "invalid_password"},
+			})
+			apiCfg.recordLoginFailure(r, email)
+			respondWithError(w, http.StatusUnauthorized, "Invalid email or password")
 			return
 		}
 
-		user := model.User{
-			ID:           dbUser.ID,
-			CreatedAt:    dbUser.CreatedAt,
-			UpdatedAt:    dbUser.UpdatedAt,
-			Email:        dbUser.Email,
-			Token:        token,
-			RefreshToken: refreshToken,
-			IsChirpyRed:  dbUser.IsChirpyRed,
-		}
-
-		_, err = apiCfg.dbQueries.StoreRefreshToken(r.Context(), database.StoreRefreshTokenParams{
-			Token:  refreshToken,
-			UserID: dbUser.ID,
-		})
-		if err != nil {
-			respondWithError(w, http.StatusInternalServerError, "Failed to store refresh token")
+		totp, err := apiCfg.dbQueries.GetUserTOTP(r.Context(), dbUser.ID)
+		if err != nil && err != sql.ErrNoRows {
+			respondWithError(w, http.StatusInternalServerError, "Failed to check two-factor authentication")
+			return
+		}
+		if err == nil && totp.EnabledAt.Valid {
+			apiCfg.startMFAChallenge(w, r, dbUser.ID)
 			return
 		}
 
-		respondWithJSON(w, http.StatusOK, user)
+		apiCfg.completeLogin(w, r, dbUser, "password", req.DeviceName)
 
 	})
 
+	mux.HandleFunc("POST /api/login/mfa", apiCfg.handlerLoginMFA)
+
+	mux.HandleFunc("POST /api/2fa/totp/enroll", apiCfg.handlerTOTPEnroll)
+
+	mux.HandleFunc("POST /api/2fa/totp/confirm", apiCfg.handlerTOTPConfirm)
+
+	mux.HandleFunc("POST /api/2fa/totp/disable", apiCfg.handlerTOTPDisable)
+
 	mux.HandleFunc("POST /api/refresh", func(w http.ResponseWriter, r *http.Request) {
 		refreshToken, err := auth.GetBearerToken(r.Header)
 		if err != nil {
@@ -464,21 +775,50 @@ func main() {
 			return
 		}
 
-		userID, err := apiCfg.dbQueries.GetUserFromRefreshToken(r.Context(), refreshToken)
+		tokenHash := auth.HashToken(refreshToken)
+		storedToken, err := apiCfg.dbQueries.GetRefreshToken(r.Context(), tokenHash)
 		if err != nil {
+			if err == sql.ErrNoRows {
+				respondWithError(w, http.StatusUnauthorized, "Invalid or expired refresh token")
+				return
+			}
+			respondWithError(w, http.StatusInternalServerError, "Failed to get refresh token")
+			return
+		}
+
+		if storedToken.RevokedAt.Valid || !storedToken.ExpiresAt.After(time.Now()) {
+			respondWithError(w, http.StatusUnauthorized, "Invalid or expired refresh token")
+			return
+		}
+
+		// tokens issued to OAuth clients are only accepted at /oauth/token
+		if storedToken.ClientID.Valid {
 			respondWithError(w, http.StatusUnauthorized, "Invalid or expired refresh token")
 			return
 		}
 
-		acessToken, err := auth.MakeJWT(userID, cfg.JWTSecret, 1*time.Hour)
+		newRefreshToken, _, err := apiCfg.rotateRefreshToken(r, storedToken)
+		if err != nil {
+			if errors.Is(err, errRefreshTokenReused) {
+				respondWithError(w, http.StatusUnauthorized, "Invalid or expired refresh token")
+				return
+			}
+			respondWithError(w, http.StatusInternalServerError, "Failed to rotate refresh token")
+			return
+		}
+
+		acessToken, err := apiCfg.keyRing.MakeJWT(storedToken.UserID, 1*time.Hour)
 		if err != nil {
 			respondWithError(w, http.StatusInternalServerError, "Failed to create acess token")
 			return
 		}
+
 		reponse := struct {
-			Token string `json:"token"`
+			Token        string `json:"token"`
+			RefreshToken string `json:"refresh_token"`
 		}{
-			Token: acessToken,
+			Token:        acessToken,
+			RefreshToken: newRefreshToken,
 		}
 
 		respondWithJSON(w, http.StatusOK, reponse)
@@ -491,7 +831,7 @@ func main() {
 			return
 		}
 
-		_, err = apiCfg.dbQueries.RevokeRefreshToken(r.Context(), refreshToken)
+		revokedToken, err := apiCfg.dbQueries.RevokeRefreshToken(r.Context(), auth.HashToken(refreshToken))
 		if err != nil {
 			if err == sql.ErrNoRows {
 				w.WriteHeader(http.StatusNoContent)
@@ -500,9 +840,64 @@ func main() {
 			respondWithError(w, http.StatusInternalServerError, "Failed to revoke refresh token")
 			return
 		}
+
+		// logging out ends the session, not just the latest token of it
+		_, err = apiCfg.dbQueries.RevokeRefreshTokenFamily(r.Context(), revokedToken.FamilyID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Failed to revoke refresh token")
+			return
+		}
+
+		apiCfg.recordAudit(r, auditEntry{
+			ActorID:    revokedToken.UserID,
+			Action:     auditActionRefreshTokenRevoked,
+			TargetType: "user",
+			TargetID:   revokedToken.UserID.String(),
+			Metadata:   map[string]any{"family_id": revokedToken.FamilyID},
+		})
 		w.WriteHeader(http.StatusNoContent)
 	})
 
+	mux.HandleFunc("GET /api/me/subscription", apiCfg.handlerSubscriptionGet)
+
+	mux.HandleFunc("GET /api/sessions", apiCfg.handlerSessionsList)
+
+	mux.HandleFunc("DELETE /api/sessions/{sessionID}", apiCfg.handlerSessionsRevoke)
+
+	mux.HandleFunc("POST /api/sessions/revoke-all", apiCfg.handlerSessionsRevokeAll)
+
+	mux.HandleFunc("POST /api/tokens", apiCfg.handlerPersonalAccessTokensCreate)
+
+	mux.HandleFunc("GET /api/tokens", apiCfg.handlerPersonalAccessTokensList)
+
+	mux.HandleFunc("DELETE /api/tokens/{tokenID}", apiCfg.handlerPersonalAccessTokensRevoke)
+
+	mux.HandleFunc("POST /api/oauth/clients", apiCfg.handlerOAuthClientsCreate)
+
+	mux.HandleFunc("GET /api/oauth/clients", apiCfg.handlerOAuthClientsList)
+
+	mux.HandleFunc("DELETE /api/oauth/clients/{clientID}", apiCfg.handlerOAuthClientsDelete)
+
+	mux.HandleFunc("POST /api/webhooks", apiCfg.handlerWebhookEndpointsCreate)
+
+	mux.HandleFunc("GET /api/webhooks", apiCfg.handlerWebhookEndpointsList)
+
+	mux.HandleFunc("DELETE /api/webhooks/{endpointID}", apiCfg.handlerWebhookEndpointsDelete)
+
+	mux.HandleFunc("GET /api/webhooks/{endpointID}/deliveries", apiCfg.handlerWebhookDeliveriesList)
+
+	mux.HandleFunc("POST /api/webhooks/{endpointID}/deliveries/{deliveryID}/retry", apiCfg.handlerWebhookDeliveryRetry)
+
+	mux.HandleFunc("GET /oauth/authorize", apiCfg.handlerOAuthAuthorize)
+
+	mux.HandleFunc("POST /oauth/authorize", apiCfg.handlerOAuthAuthorizeConsent)
+
+	mux.HandleFunc("POST /oauth/token", apiCfg.handlerOAuthToken)
+
+	mux.HandleFunc("POST /oauth/revoke", apiCfg.handlerOAuthRevoke)
+
+	mux.HandleFunc("POST /oauth/introspect", apiCfg.handlerOAuthIntrospect)
+
 	mux.Handle("/app/", apiCfg.middlewareMetricsInc(http.StripPrefix("/app/", http.FileServer(http.Dir(".")))))
 
 	mux.Handle("/assets/", http.StripPrefix("/assets/", http.FileServer(http.Dir("./assets"))))
@@ -524,59 +919,23 @@ func main() {
 
 	})
 
-	mux.HandleFunc("POST /api/polka/webhooks", func(w http.ResponseWriter, r *http.Request) {
-
-		apiKey, err := auth.GetAPIKey(r.Header)
-		if err != nil {
-			respondWithError(w, http.StatusUnauthorized, "Failed to get header apiKey")
-			return
-		}
-
-		if apiKey != cfg.PolkaKey {
-			respondWithError(w, http.StatusUnauthorized, "ApiKey doesn't match the server's")
-			return
-		}
-
-		type webhookRequest struct {
-			Event string `json:"event"`
-			Data  struct {
-				UserID string `json:"user_id"`
-			} `json:"data"`
-		}
-
-		var req webhookRequest
+	mux.HandleFunc("GET /admin/audit", apiCfg.handlerAuditEventsList)
 
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			respondWithError(w, http.StatusBadRequest, "invalid request body")
-			return
-		}
+	mux.HandleFunc("POST /api/polka/webhooks", apiCfg.handlerPolkaWebhook)
 
-		if req.Event != "user.upgraded" {
-			w.WriteHeader(http.StatusNoContent)
-			return
-		}
+	mux.HandleFunc("GET /admin/webhooks/events", apiCfg.handlerWebhookEventsList)
 
-		userID, err := uuid.Parse(req.Data.UserID)
-		if err != nil {
-			respondWithError(w, http.StatusBadRequest, "Invalid  json:user_id")
-			return
-		}
+	mux.HandleFunc("GET /admin/webhooks/events/{eventID}", apiCfg.handlerWebhookEventGet)
 
-		_, err = apiCfg.dbQueries.UpgradeUserToChirpyRed(r.Context(), userID)
-		if err != nil {
-			if err == sql.ErrNoRows {
-				w.WriteHeader(http.StatusNotFound)
-				return
-			}
-			respondWithError(w, http.StatusInternalServerError, "Failed to upgrade user")
-			return
-		}
-		w.WriteHeader(http.StatusNoContent)
-	})
+	mux.HandleFunc("POST /admin/webhooks/events/{eventID}/replay", apiCfg.handlerWebhookEventReplay)
 
 	mux.HandleFunc("POST /admin/reset", func(w http.ResponseWriter, r *http.Request) {
 
 		if cfg.Platform != "dev" {
+			apiCfg.recordAudit(r, auditEntry{
+				Action:   auditActionAdminReset,
+				Metadata: map[string]any{"allowed": false, "platform": cfg.Platform},
+			})
 			w.WriteHeader(http.StatusForbidden)
 			return
 		}
@@ -586,6 +945,11 @@ func main() {
 			respondWithError(w, http.StatusInternalServerError, "Failed to delete all users")
 			return
 		}
+
+		apiCfg.recordAudit(r, auditEntry{
+			Action:   auditActionAdminReset,
+			Metadata: map[string]any{"allowed": true, "platform": cfg.Platform},
+		})
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("Users deleted"))
 	})
@@ -594,6 +958,10 @@ func main() {
 		Handler: mux,
 		Addr:    ":8080",
 	}
+	go apiCfg.runSubscriptionExpiry(cfg.SubscriptionExpiryInterval)
+	go apiCfg.runWebhookDeliveries()
+	go apiCfg.runChirpScheduler(cfg.ScheduledChirpInterval)
+
 	log.Printf("Server starting on %s", server.Addr)
 
 	err = server.ListenAndServe()
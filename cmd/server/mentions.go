@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/mailer"
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/model"
+	"github.com/google/uuid"
+)
+
+const eventChirpMentioned = "chirp.mentioned"
+
+// only the first mentions of a chirp are looked up
+const maxChirpMentions = 10
+
+// parseMentions returns the addresses mentioned in body. Users have no handle
+// yet, so a mention is "@" followed by their email address.
+func parseMentions(body string) []string {
+	seen := map[string]bool{}
+	var mentions []string
+	for _, word := range strings.Fields(body) {
+		if !strings.HasPrefix(word, "@") {
+			continue
+		}
+		address, err := mailer.NormalizeAddress(strings.TrimRight(word[1:], ".,;:!?)"))
+		if err != nil || seen[address] {
+			continue
+		}
+		seen[address] = true
+		mentions = append(mentions, address)
+		if len(mentions) == maxChirpMentions {
+			break
+		}
+	}
+	return mentions
+}
+
+// mentionedUsers returns the users chirp mentions, apart from its author.
+func (cfg *apiConfig) mentionedUsers(ctx context.Context, chirp model.Chirp) []uuid.UUID {
+	var userIDs []uuid.UUID
+	for _, address := range parseMentions(chirp.Body) {
+		user, err := cfg.dbQueries.GetUserByEmail(ctx, address)
+		if err != nil {
+			// mentioning someone who isn't a user is just text
+			continue
+		}
+		if user.ID != chirp.UserID {
+			userIDs = append(userIDs, user.ID)
+		}
+	}
+	return userIDs
+}
+
+// publishMentions tells the users chirp mentions about it.
+func (cfg *apiConfig) publishMentions(ctx context.Context, chirp model.Chirp) {
+	for _, userID := range cfg.mentionedUsers(ctx, chirp) {
+		cfg.publishEvent(eventChirpMentioned, userID, chirp.ID, chirp)
+		cfg.notify(ctx, notification{
+			UserID:  userID,
+			Type:    notificationTypeMention,
+			ActorID: chirp.UserID,
+			ChirpID: chirp.ID,
+			Grouped: true,
+			Data:    map[string]string{"body": chirp.Body},
+		})
+	}
+}
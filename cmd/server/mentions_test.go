@@ -0,0 +1,41 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseMentions(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{"none", "just chirping", nil},
+		{"one", "hello @alice@example.com", []string{"alice@example.com"}},
+		{"trailing punctuation", "thanks @bob@example.com!", []string{"bob@example.com"}},
+		{"normalized and deduplicated", "@carol@Example.com and @carol@example.com", []string{"carol@example.com"}},
+		{"not an address", "@alice and email@example.com", nil},
+		{"in order", "@b@example.com @a@example.com", []string{"b@example.com", "a@example.com"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseMentions(tt.body); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseMentions(%q) = %v, want %v", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMentionsLimit(t *testing.T) {
+	var words []string
+	for i := 0; i < maxChirpMentions+5; i++ {
+		words = append(words, "@user"+string(rune('a'+i))+"@example.com")
+	}
+
+	if got := parseMentions(strings.Join(words, " ")); len(got) != maxChirpMentions {
+		t.Errorf("parseMentions() returned %d mentions, want %d", len(got), maxChirpMentions)
+	}
+}
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/auth"
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	auditActionTOTPEnabled  = "user.totp_enabled"
+	auditActionTOTPDisabled = "user.totp_disabled"
+)
+
+const (
+	totpIssuer        = "Chirpy"
+	recoveryCodeCount = 10
+)
+
+// startMFAChallenge answers a correct password for a user with 2FA enabled.
+// The returned token is exchanged for the real tokens at POST /api/login/mfa.
+func (cfg *apiConfig) startMFAChallenge(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+	token, err := auth.MakeSecureToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to create mfa token")
+		return
+	}
+
+	challenge, err := cfg.dbQueries.CreateMFAChallenge(r.Context(), database.CreateMFAChallengeParams{
+		TokenHash: auth.HashToken(token),
+		UserID:    userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to store mfa token")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, struct {
+		MFARequired bool      `json:"mfa_required"`
+		MFAToken    string    `json:"mfa_token"`
+		ExpiresAt   time.Time `json:"expires_at"`
+	}{
+		MFARequired: true,
+		MFAToken:    token,
+		ExpiresAt:   challenge.ExpiresAt,
+	})
+}
+
+// verifySecondFactor checks either a TOTP code or a recovery code for the
+// user and burns it so it can't be used again. It returns the method used.
+func (cfg *apiConfig) verifySecondFactor(ctx context.Context, totp database.UserTotp, code, recoveryCode string) (method string, ok bool, err error) {
+	if code != "" {
+		step, valid := auth.ValidateTOTPCode(totp.Secret, code, time.Now())
+		if !valid {
+			return "", false, nil
+		}
+		// only a step newer than the last accepted one is updated, which
+		// rejects replaying a code that was already used
+		updated, err := cfg.dbQueries.UseUserTOTPStep(ctx, database.UseUserTOTPStepParams{
+			UserID:   totp.UserID,
+			LastStep: step,
+		})
+		if err != nil {
+			return "", false, err
+		}
+		return "totp", updated == 1, nil
+	}
+
+	if recoveryCode != "" {
+		used, err := cfg.dbQueries.UseTOTPRecoveryCode(ctx, database.UseTOTPRecoveryCodeParams{
+			UserID:   totp.UserID,
+			CodeHash: auth.HashToken(auth.NormalizeRecoveryCode(recoveryCode)),
+		})
+		if err != nil {
+			return "", false, err
+		}
+		return "recovery_code", used == 1, nil
+	}
+
+	return "", false, nil
+}
+
+func (cfg *apiConfig) handlerLoginMFA(w http.ResponseWriter, r *http.Request) {
+	type requestBody struct {
+		MFAToken     string `json:"mfa_token"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+		DeviceName   string `json:"device_name"`
+	}
+
+	var req requestBody
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.MFAToken == "" {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	tokenHash := auth.HashToken(req.MFAToken)
+	challenge, err := cfg.dbQueries.GetActiveMFAChallenge(r.Context(), tokenHash)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusUnauthorized, "Invalid or expired mfa token")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Failed to get mfa token")
+		return
+	}
+
+	dbUser, err := cfg.dbQueries.GetUserByID(r.Context(), challenge.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to get user")
+		return
+	}
+
+	// codes are guessed under the same limits as passwords
+	wait, err := cfg.loginLockedFor(r.Context(), r, dbUser.Email)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to check login attempts")
+		return
+	}
+	if wait > 0 {
+		respondWithLockedOut(w, wait)
+		return
+	}
+
+	totp, err := cfg.dbQueries.GetUserTOTP(r.Context(), challenge.UserID)
+	if err != nil || !totp.EnabledAt.Valid {
+		respondWithError(w, http.StatusUnauthorized, "Invalid or expired mfa token")
+		return
+	}
+
+	method, ok, err := cfg.verifySecondFactor(r.Context(), totp, req.Code, req.RecoveryCode)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to verify code")
+		return
+	}
+	if !ok {
+		if err := cfg.dbQueries.IncrementMFAChallengeAttempts(r.Context(), tokenHash); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Failed to verify code")
+			return
+		}
+		cfg.recordAudit(r, auditEntry{
+			Action:     auditActionLoginFailed,
+			TargetType: "user",
+			TargetID:   challenge.UserID.String(),
+			Metadata:   map[string]any{"reason": "invalid_mfa_code"},
+		})
+		cfg.recordLoginFailure(r, dbUser.Email)
+		respondWithError(w, http.StatusUnauthorized, "Invalid code")
+		return
+	}
+
+	consumed, err := cfg.dbQueries.ConsumeMFAChallenge(r.Context(), tokenHash)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to verify code")
+		return
+	}
+	if consumed != 1 {
+		respondWithError(w, http.StatusUnauthorized, "Invalid or expired mfa token")
+		return
+	}
+
+	cfg.completeLogin(w, r, dbUser, method, req.DeviceName)
+}
+
+func (cfg *apiConfig) handlerTOTPEnroll(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateUser(w, r)
+	if !ok {
+		return
+	}
+
+	dbUser, err := cfg.dbQueries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to get user")
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to create totp secret")
+		return
+	}
+
+	_, err = cfg.dbQueries.StartUserTOTPEnrollment(r.Context(), database.StartUserTOTPEnrollmentParams{
+		UserID: userID,
+		Secret: secret,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Failed to store totp secret")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, struct {
+		Secret          string `json:"secret"`
+		ProvisioningURI string `json:"provisioning_uri"`
+	}{
+		Secret:          secret,
+		ProvisioningURI: auth.TOTPProvisioningURI(secret, dbUser.Email, totpIssuer),
+	})
+}
+
+func (cfg *apiConfig) handlerTOTPConfirm(w http.ResponseWriter, r *http.Request) {
+	type requestBody struct {
+		Code string `json:"code"`
+	}
+
+	userID, ok := cfg.authenticateUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req requestBody
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	totp, err := cfg.dbQueries.GetUserTOTP(r.Context(), userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusNotFound, "Two-factor enrollment not started")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Failed to get totp secret")
+		return
+	}
+	if totp.EnabledAt.Valid {
+		respondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	}
+
+	step, valid := auth.ValidateTOTPCode(totp.Secret, req.Code, time.Now())
+	if !valid {
+		respondWithError(w, http.StatusUnauthorized, "Invalid code")
+		return
+	}
+
+	recoveryCodes, err := auth.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to create recovery codes")
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to enable two-factor authentication")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	enabled, err := qtx.EnableUserTOTP(r.Context(), database.EnableUserTOTPParams{
+		UserID:   userID,
+		LastStep: step,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to enable two-factor authentication")
+		return
+	}
+	if enabled != 1 {
+		respondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	}
+
+	if err := qtx.DeleteTOTPRecoveryCodes(r.Context(), userID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to store recovery codes")
+		return
+	}
+	for _, code := range recoveryCodes {
+		err := qtx.CreateTOTPRecoveryCode(r.Context(), database.CreateTOTPRecoveryCodeParams{
+			UserID:   userID,
+			CodeHash: auth.HashToken(auth.NormalizeRecoveryCode(code)),
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Failed to store recovery codes")
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to enable two-factor authentication")
+		return
+	}
+
+	cfg.recordAudit(r, auditEntry{
+		ActorID:    userID,
+		Action:     auditActionTOTPEnabled,
+		TargetType: "user",
+		TargetID:   userID.String(),
+	})
+
+	// the recovery codes are only ever shown here
+	respondWithJSON(w, http.StatusOK, struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}{
+		RecoveryCodes: recoveryCodes,
+	})
+}
+
+func (cfg *apiConfig) handlerTOTPDisable(w http.ResponseWriter, r *http.Request) {
+	type requestBody struct {
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+
+	userID, ok := cfg.authenticateUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req requestBody
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	totp, err := cfg.dbQueries.GetUserTOTP(r.Context(), userID)
+	if err != nil || !totp.EnabledAt.Valid {
+		if err != nil && err != sql.ErrNoRows {
+			respondWithError(w, http.StatusInternalServerError, "Failed to get totp secret")
+			return
+		}
+		respondWithError(w, http.StatusNotFound, "Two-factor authentication is not enabled")
+		return
+	}
+
+	_, ok, err = cfg.verifySecondFactor(r.Context(), totp, req.Code, req.RecoveryCode)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to verify code")
+		return
+	}
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Invalid code")
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to disable two-factor authentication")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	if err := qtx.DeleteUserTOTP(r.Context(), userID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to disable two-factor authentication")
+		return
+	}
+	if err := qtx.DeleteTOTPRecoveryCodes(r.Context(), userID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to disable two-factor authentication")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to disable two-factor authentication")
+		return
+	}
+
+	cfg.recordAudit(r, auditEntry{
+		ActorID:    userID,
+		Action:     auditActionTOTPDisabled,
+		TargetType: "user",
+		TargetID:   userID.String(),
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/auth"
+	"github.com/google/uuid"
+)
+
+// authenticateUser validates the bearer JWT of the request. When it fails the
+// error response has already been written and ok is false.
+func (cfg *apiConfig) authenticateUser(w http.ResponseWriter, r *http.Request) (userID uuid.UUID, ok bool) {
+	tokenString, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or invalid Authorization header")
+		return uuid.Nil, false
+	}
+
+	userID, err = cfg.keyRing.ValidateJWT(tokenString)
+	if err != nil {
+		respondWithTokenError(w, err)
+		return uuid.Nil, false
+	}
+
+	return userID, true
+}
+
+// authenticateScoped is authenticateUser for the endpoints personal access
+// tokens and OAuth clients may call. A JWT from a login grants every scope,
+// the other tokens only the ones they were issued with.
+func (cfg *apiConfig) authenticateScoped(w http.ResponseWriter, r *http.Request, scope string) (userID uuid.UUID, ok bool) {
+	tokenString, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Missing or invalid Authorization header")
+		return uuid.Nil, false
+	}
+
+	if !auth.IsPersonalAccessToken(tokenString) {
+		userID, err := cfg.keyRing.ValidateJWT(tokenString)
+		if errors.Is(err, auth.ErrTokenInvalidType) {
+			claims, oauthErr := cfg.keyRing.ValidateOAuthToken(tokenString)
+			if oauthErr == nil {
+				if !auth.HasScope(claims.Scopes, scope) {
+					respondWithError(w, http.StatusForbidden, "Access token is missing the "+scope+" scope")
+					return uuid.Nil, false
+				}
+				return claims.UserID, true
+			}
+		}
+		if err != nil {
+			respondWithTokenError(w, err)
+			return uuid.Nil, false
+		}
+		return userID, true
+	}
+
+	token, err := cfg.dbQueries.GetActivePersonalAccessToken(r.Context(), auth.HashToken(tokenString))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusUnauthorized, "Expired or invalid access token")
+			return uuid.Nil, false
+		}
+		respondWithError(w, http.StatusInternalServerError, "Failed to get access token")
+		return uuid.Nil, false
+	}
+
+	if !auth.HasScope(token.Scopes, scope) {
+		respondWithError(w, http.StatusForbidden, "Access token is missing the "+scope+" scope")
+		return uuid.Nil, false
+	}
+
+	if err := cfg.dbQueries.TouchPersonalAccessToken(r.Context(), token.ID); err != nil {
+		log.Printf("Error updating last use of access token %s: %s", token.ID, err)
+	}
+
+	return token.UserID, true
+}
+
+// respondWithTokenError answers a request whose access token was rejected,
+// telling clients whether refreshing the token can help.
+func respondWithTokenError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, auth.ErrTokenExpired):
+		respondWithError(w, http.StatusUnauthorized, "Expired jwt token")
+	case errors.Is(err, auth.ErrTokenMalformed):
+		respondWithError(w, http.StatusUnauthorized, "Malformed jwt token")
+	default:
+		respondWithError(w, http.StatusUnauthorized, "Invalid jwt token")
+	}
+}
+
+// requireAdmin works like authenticateUser but also rejects users that are not admins.
+func (cfg *apiConfig) requireAdmin(w http.ResponseWriter, r *http.Request) (userID uuid.UUID, ok bool) {
+	userID, ok = cfg.authenticateUser(w, r)
+	if !ok {
+		return uuid.Nil, false
+	}
+
+	dbUser, err := cfg.dbQueries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusUnauthorized, "User not found")
+			return uuid.Nil, false
+		}
+		respondWithError(w, http.StatusInternalServerError, "Failed to get user")
+		return uuid.Nil, false
+	}
+
+	if !dbUser.IsAdmin {
+		respondWithError(w, http.StatusForbidden, "Admin access required")
+		return uuid.Nil, false
+	}
+
+	return userID, true
+}
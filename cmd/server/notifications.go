@@ -0,0 +1,369 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/auth"
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/database"
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/model"
+	"github.com/google/uuid"
+)
+
+const (
+	notificationTypeLike         = "like"
+	notificationTypeReply        = "reply"
+	notificationTypeMention      = "mention"
+	notificationTypeFollow       = "follow"
+	notificationTypeRechirp      = "rechirp"
+	notificationTypeSubscription = "subscription"
+)
+
+// notificationTypes are the types users can turn off. There are no likes,
+// replies, follows or rechirps yet, their preferences are kept for when there
+// are.
+var notificationTypes = []string{
+	notificationTypeLike,
+	notificationTypeReply,
+	notificationTypeMention,
+	notificationTypeFollow,
+	notificationTypeRechirp,
+	notificationTypeSubscription,
+}
+
+// eventNotificationCreated tells the user's real-time connections that the
+// unread count changed.
+const eventNotificationCreated = "notification.created"
+
+const (
+	defaultNotificationLimit = 50
+	maxNotificationLimit     = 100
+)
+
+// notification is something to tell UserID. Grouped notifications fold into
+// the unread one of the same type about the same chirp, adding ActorID to it.
+type notification struct {
+	UserID  uuid.UUID
+	Type    string
+	ActorID uuid.UUID
+	ChirpID uuid.UUID
+	Grouped bool
+	Data    any
+}
+
+// notify stores n unless the user turned its type off. Failures are only
+// logged, the action already happened.
+func (cfg *apiConfig) notify(ctx context.Context, n notification) {
+	ctx = context.WithoutCancel(ctx)
+
+	enabled, err := cfg.dbQueries.IsNotificationEnabled(ctx, database.IsNotificationEnabledParams{
+		UserID: n.UserID,
+		Type:   n.Type,
+	})
+	if err != nil {
+		log.Printf("Error checking %s notification preference: %s", n.Type, err)
+		return
+	}
+	if !enabled {
+		return
+	}
+
+	data := json.RawMessage("{}")
+	if n.Data != nil {
+		data, err = json.Marshal(n.Data)
+		if err != nil {
+			log.Printf("Error marshaling %s notification: %s", n.Type, err)
+			return
+		}
+	}
+
+	params := database.UpsertNotificationParams{
+		UserID: n.UserID,
+		Type:   n.Type,
+		Data:   data,
+	}
+	if n.ChirpID != uuid.Nil {
+		params.ChirpID = uuid.NullUUID{UUID: n.ChirpID, Valid: true}
+	}
+	if n.Grouped {
+		params.GroupKey = sql.NullString{String: fmt.Sprintf("%s:%s", n.Type, n.ChirpID), Valid: true}
+	}
+
+	notificationID, err := cfg.storeNotification(ctx, params, n.ActorID)
+	if err != nil {
+		log.Printf("Error storing %s notification: %s", n.Type, err)
+		return
+	}
+
+	unread, err := cfg.dbQueries.CountUnreadNotifications(ctx, n.UserID)
+	if err != nil {
+		log.Printf("Error counting unread notifications: %s", err)
+		return
+	}
+	cfg.publishEvent(eventNotificationCreated, n.UserID, notificationID, map[string]any{
+		"id":           notificationID,
+		"type":         n.Type,
+		"unread_count": unread,
+	})
+}
+
+func (cfg *apiConfig) storeNotification(ctx context.Context, params database.UpsertNotificationParams, actorID uuid.UUID) (uuid.UUID, error) {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	stored, err := qtx.UpsertNotification(ctx, params)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	if actorID != uuid.Nil {
+		err = qtx.AddNotificationActor(ctx, database.AddNotificationActorParams{
+			NotificationID: stored.ID,
+			ActorID:        actorID,
+		})
+		if err != nil {
+			return uuid.Nil, err
+		}
+	}
+
+	return stored.ID, tx.Commit()
+}
+
+func (cfg *apiConfig) handlerNotificationsList(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateUser(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	params := database.ListNotificationsParams{UserID: userID, Limit: defaultNotificationLimit}
+
+	switch query.Get("unread") {
+	case "", "false":
+	case "true":
+		params.UnreadOnly = true
+	default:
+		respondWithError(w, http.StatusBadRequest, "unread must be true or false")
+		return
+	}
+	if before := query.Get("before"); before != "" {
+		t, err := time.Parse(time.RFC3339Nano, before)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "before must be an RFC 3339 timestamp")
+			return
+		}
+		params.Before.Time, params.Before.Valid = t, true
+	}
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxNotificationLimit {
+			respondWithError(w, http.StatusBadRequest, "limit must be between 1 and 100")
+			return
+		}
+		params.Limit = int32(n)
+	}
+
+	dbNotifications, err := cfg.dbQueries.ListNotifications(r.Context(), params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch notifications")
+		return
+	}
+
+	ids := make([]uuid.UUID, len(dbNotifications))
+	for i, notification := range dbNotifications {
+		ids[i] = notification.ID
+	}
+	actorRows, err := cfg.dbQueries.ListNotificationActors(r.Context(), ids)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch notifications")
+		return
+	}
+	actors := map[uuid.UUID][]uuid.UUID{}
+	for _, row := range actorRows {
+		actors[row.NotificationID] = append(actors[row.NotificationID], row.ActorID)
+	}
+
+	notifications := make([]model.Notification, len(dbNotifications))
+	for i, notification := range dbNotifications {
+		notifications[i] = notificationToModel(notification, actors[notification.ID])
+	}
+
+	respondWithJSON(w, http.StatusOK, notifications)
+}
+
+func notificationToModel(notification database.Notification, actors []uuid.UUID) model.Notification {
+	if actors == nil {
+		actors = []uuid.UUID{}
+	}
+	response := model.Notification{
+		ID:         notification.ID,
+		Type:       notification.Type,
+		Actors:     actors,
+		ActorCount: notification.ActorCount,
+		Data:       notification.Data,
+		Read:       notification.ReadAt.Valid,
+		CreatedAt:  notification.CreatedAt,
+		UpdatedAt:  notification.UpdatedAt,
+	}
+	if notification.ChirpID.Valid {
+		chirpID := notification.ChirpID.UUID
+		response.ChirpID = &chirpID
+	}
+	if notification.ReadAt.Valid {
+		readAt := notification.ReadAt.Time
+		response.ReadAt = &readAt
+	}
+	return response
+}
+
+func (cfg *apiConfig) handlerNotificationsUnreadCount(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateUser(w, r)
+	if !ok {
+		return
+	}
+
+	unread, err := cfg.dbQueries.CountUnreadNotifications(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to count notifications")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]int64{"unread_count": unread})
+}
+
+func (cfg *apiConfig) handlerNotificationRead(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateUser(w, r)
+	if !ok {
+		return
+	}
+
+	notificationID, err := uuid.Parse(r.PathValue("notificationID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid notification ID format")
+		return
+	}
+
+	rows, err := cfg.dbQueries.MarkNotificationRead(r.Context(), database.MarkNotificationReadParams{
+		ID:     notificationID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to mark notification as read")
+		return
+	}
+	if rows == 0 {
+		respondWithError(w, http.StatusNotFound, "Notification not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerNotificationsReadAll(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateUser(w, r)
+	if !ok {
+		return
+	}
+
+	if _, err := cfg.dbQueries.MarkAllNotificationsRead(r.Context(), userID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to mark notifications as read")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerNotificationPreferencesGet(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateUser(w, r)
+	if !ok {
+		return
+	}
+
+	cfg.respondWithNotificationPreferences(w, r, userID)
+}
+
+// handlerNotificationPreferencesUpdate turns types on or off, the ones missing
+// from the body are left alone.
+func (cfg *apiConfig) handlerNotificationPreferencesUpdate(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateScoped(w, r, auth.ScopeProfileWrite)
+	if !ok {
+		return
+	}
+
+	var preferences map[string]bool
+	if err := json.NewDecoder(r.Body).Decode(&preferences); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	for notificationType := range preferences {
+		if !isNotificationType(notificationType) {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Unknown notification type %q", notificationType))
+			return
+		}
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to update preferences")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	for notificationType, enabled := range preferences {
+		err := qtx.SetNotificationPreference(r.Context(), database.SetNotificationPreferenceParams{
+			UserID:  userID,
+			Type:    notificationType,
+			Enabled: enabled,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Failed to update preferences")
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to update preferences")
+		return
+	}
+
+	cfg.respondWithNotificationPreferences(w, r, userID)
+}
+
+// respondWithNotificationPreferences answers with every type, the ones the
+// user never changed are enabled.
+func (cfg *apiConfig) respondWithNotificationPreferences(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+	stored, err := cfg.dbQueries.ListNotificationPreferences(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to get preferences")
+		return
+	}
+
+	preferences := make(map[string]bool, len(notificationTypes))
+	for _, notificationType := range notificationTypes {
+		preferences[notificationType] = true
+	}
+	for _, preference := range stored {
+		preferences[preference.Type] = preference.Enabled
+	}
+
+	respondWithJSON(w, http.StatusOK, preferences)
+}
+
+func isNotificationType(notificationType string) bool {
+	for _, t := range notificationTypes {
+		if t == notificationType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,672 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/auth"
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/database"
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/mailer"
+	"github.com/google/uuid"
+)
+
+const (
+	auditActionOAuthAuthorized = "oauth.authorized"
+	auditActionOAuthDenied     = "oauth.denied"
+)
+
+const oauthAccessTokenLifetime = 1 * time.Hour
+
+// error codes of RFC 6749 section 5.2 and 4.1.2.1
+const (
+	oauthErrInvalidRequest          = "invalid_request"
+	oauthErrInvalidClient           = "invalid_client"
+	oauthErrInvalidGrant            = "invalid_grant"
+	oauthErrInvalidScope            = "invalid_scope"
+	oauthErrUnsupportedGrantType    = "unsupported_grant_type"
+	oauthErrUnsupportedResponseType = "unsupported_response_type"
+	oauthErrAccessDenied            = "access_denied"
+	oauthErrServerError             = "server_error"
+)
+
+var scopeDescriptions = map[string]string{
+	auth.ScopeChirpsRead:   "Read your chirps",
+	auth.ScopeChirpsWrite:  "Post and delete chirps as you",
+	auth.ScopeProfileWrite: "Change your notification settings",
+}
+
+var consentPage = template.Must(template.New("consent").Parse(`<html>
+  <body>
+    <h1>Authorize {{.ClientName}}</h1>
+    <p>{{.ClientName}} wants to access your Chirpy account and will be able to:</p>
+    <ul>
+      {{range .Scopes}}<li>{{.}}</li>{{end}}
+    </ul>
+    {{if .Error}}<p><strong>{{.Error}}</strong></p>{{end}}
+    <form method="POST" action="/oauth/authorize">
+      <input type="hidden" name="response_type" value="code">
+      <input type="hidden" name="client_id" value="{{.ClientID}}">
+      <input type="hidden" name="redirect_uri" value="{{.RedirectURI}}">
+      <input type="hidden" name="scope" value="{{.Scope}}">
+      <input type="hidden" name="state" value="{{.State}}">
+      <input type="hidden" name="code_challenge" value="{{.CodeChallenge}}">
+      <input type="hidden" name="code_challenge_method" value="S256">
+      <p><label>Email <input type="email" name="email" autocomplete="username"></label></p>
+      <p><label>Password <input type="password" name="password" autocomplete="current-password"></label></p>
+      <p><label>Two-factor code (if enabled) <input type="text" name="code" autocomplete="one-time-code"></label></p>
+      <button type="submit" name="decision" value="approve">Approve</button>
+      <button type="submit" name="decision" value="deny">Deny</button>
+    </form>
+  </body>
+</html>`))
+
+var errorPage = template.Must(template.New("error").Parse(`<html>
+  <body>
+    <h1>Authorization failed</h1>
+    <p>{{.}}</p>
+  </body>
+</html>`))
+
+type authorizeRequest struct {
+	Client        database.OauthClient
+	RedirectURI   string
+	Scopes        []string
+	State         string
+	CodeChallenge string
+}
+
+type oauthError struct {
+	Code        string
+	Description string
+}
+
+func (e *oauthError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+// parseAuthorizeRequest validates the parameters of GET and POST
+// /oauth/authorize. Errors found before client_id and redirect_uri are known
+// to be good are not redirectable: they must be shown to the user, otherwise
+// the endpoint would be an open redirector (RFC 6749 section 4.1.2.1).
+func (cfg *apiConfig) parseAuthorizeRequest(ctx context.Context, params url.Values) (req authorizeRequest, redirectable bool, err error) {
+	clientID, err := uuid.Parse(params.Get("client_id"))
+	if err != nil {
+		return authorizeRequest{}, false, &oauthError{oauthErrInvalidRequest, "Unknown client_id"}
+	}
+	client, err := cfg.dbQueries.GetOAuthClient(ctx, clientID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return authorizeRequest{}, false, &oauthError{oauthErrInvalidRequest, "Unknown client_id"}
+		}
+		return authorizeRequest{}, false, err
+	}
+
+	// redirect_uri must be one of the registered ones, compared exactly
+	req = authorizeRequest{Client: client, RedirectURI: params.Get("redirect_uri"), State: params.Get("state")}
+	registered := false
+	for _, uri := range client.RedirectUris {
+		if uri == req.RedirectURI {
+			registered = true
+		}
+	}
+	if !registered {
+		return authorizeRequest{}, false, &oauthError{oauthErrInvalidRequest, "redirect_uri is not registered for this client"}
+	}
+
+	if params.Get("response_type") != "code" {
+		return req, true, &oauthError{oauthErrUnsupportedResponseType, "Only the code response type is supported"}
+	}
+
+	req.CodeChallenge = params.Get("code_challenge")
+	if req.CodeChallenge == "" || params.Get("code_challenge_method") != auth.PKCEMethodS256 {
+		return req, true, &oauthError{oauthErrInvalidRequest, "PKCE with code_challenge_method S256 is required"}
+	}
+
+	req.Scopes = client.Scopes
+	if scope := params.Get("scope"); scope != "" {
+		scopes, err := auth.NormalizeScopes(auth.ParseScope(scope))
+		if err != nil {
+			return req, true, &oauthError{oauthErrInvalidScope, "Unknown scope"}
+		}
+		for _, s := range scopes {
+			if !auth.HasScope(client.Scopes, s) {
+				return req, true, &oauthError{oauthErrInvalidScope, "Scope " + s + " is not allowed for this client"}
+			}
+		}
+		req.Scopes = scopes
+	}
+
+	return req, true, nil
+}
+
+// handleAuthorizeError answers a failed authorization request, by redirecting
+// back to the client when that is safe.
+func handleAuthorizeError(w http.ResponseWriter, r *http.Request, req authorizeRequest, redirectable bool, err error) {
+	var oauthErr *oauthError
+	if !errors.As(err, &oauthErr) {
+		log.Printf("Error handling authorization request: %s", err)
+		oauthErr = &oauthError{oauthErrServerError, "Internal error"}
+	}
+
+	if !redirectable {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusBadRequest)
+		errorPage.Execute(w, oauthErr.Description)
+		return
+	}
+
+	redirectToClient(w, r, req, url.Values{
+		"error":             {oauthErr.Code},
+		"error_description": {oauthErr.Description},
+	})
+}
+
+func redirectToClient(w http.ResponseWriter, r *http.Request, req authorizeRequest, params url.Values) {
+	// the redirect_uri was compared with a registered, already parsed one
+	target, _ := url.Parse(req.RedirectURI)
+	query := target.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	if req.State != "" {
+		query.Set("state", req.State)
+	}
+	target.RawQuery = query.Encode()
+
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
+
+func renderConsentPage(w http.ResponseWriter, code int, req authorizeRequest, errMsg string) {
+	scopes := make([]string, len(req.Scopes))
+	for i, scope := range req.Scopes {
+		scopes[i] = scopeDescriptions[scope]
+	}
+
+	// the page asks for the password, it must never be framed by the client
+	w.Header().Set("Content-Type", "text/html")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "frame-ancestors 'none'")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+
+	err := consentPage.Execute(w, struct {
+		ClientName    string
+		ClientID      string
+		RedirectURI   string
+		Scope         string
+		State         string
+		CodeChallenge string
+		Scopes        []string
+		Error         string
+	}{
+		ClientName:    req.Client.Name,
+		ClientID:      req.Client.ID.String(),
+		RedirectURI:   req.RedirectURI,
+		Scope:         strings.Join(req.Scopes, " "),
+		State:         req.State,
+		CodeChallenge: req.CodeChallenge,
+		Scopes:        scopes,
+		Error:         errMsg,
+	})
+	if err != nil {
+		log.Printf("Error rendering consent page: %s", err)
+	}
+}
+
+// handlerOAuthAuthorize shows the consent page of the authorization code flow.
+func (cfg *apiConfig) handlerOAuthAuthorize(w http.ResponseWriter, r *http.Request) {
+	req, redirectable, err := cfg.parseAuthorizeRequest(r.Context(), r.URL.Query())
+	if err != nil {
+		handleAuthorizeError(w, r, req, redirectable, err)
+		return
+	}
+
+	renderConsentPage(w, http.StatusOK, req, "")
+}
+
+// handlerOAuthAuthorizeConsent receives the consent form. The user signs in
+// on the form itself, so approving requires the password and, when enabled,
+// a two-factor code.
+func (cfg *apiConfig) handlerOAuthAuthorizeConsent(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid form")
+		return
+	}
+
+	req, redirectable, err := cfg.parseAuthorizeRequest(r.Context(), r.PostForm)
+	if err != nil {
+		handleAuthorizeError(w, r, req, redirectable, err)
+		return
+	}
+
+	if r.PostForm.Get("decision") != "approve" {
+		cfg.recordAudit(r, auditEntry{
+			Action:     auditActionOAuthDenied,
+			TargetType: "oauth_client",
+			TargetID:   req.Client.ID.String(),
+		})
+		redirectToClient(w, r, req, url.Values{"error": {oauthErrAccessDenied}})
+		return
+	}
+
+	wait, err := cfg.loginLockedFor(r.Context(), r, r.PostForm.Get("email"))
+	if err != nil {
+		handleAuthorizeError(w, r, req, redirectable, err)
+		return
+	}
+	if wait > 0 {
+		renderConsentPage(w, http.StatusTooManyRequests, req, "Too many failed sign in attempts, try again later")
+		return
+	}
+
+	dbUser, ok, err := cfg.checkConsentCredentials(r, r.PostForm.Get("email"), r.PostForm.Get("password"), r.PostForm.Get("code"))
+	if err != nil {
+		handleAuthorizeError(w, r, req, redirectable, err)
+		return
+	}
+	if !ok {
+		renderConsentPage(w, http.StatusUnauthorized, req, "Invalid email, password or two-factor code")
+		return
+	}
+
+	code, err := auth.MakeSecureToken()
+	if err != nil {
+		handleAuthorizeError(w, r, req, redirectable, err)
+		return
+	}
+
+	err = cfg.dbQueries.CreateOAuthAuthorizationCode(r.Context(), database.CreateOAuthAuthorizationCodeParams{
+		CodeHash:      auth.HashToken(code),
+		ClientID:      req.Client.ID,
+		UserID:        dbUser.ID,
+		RedirectUri:   req.RedirectURI,
+		Scopes:        req.Scopes,
+		CodeChallenge: req.CodeChallenge,
+	})
+	if err != nil {
+		handleAuthorizeError(w, r, req, redirectable, err)
+		return
+	}
+
+	cfg.recordAudit(r, auditEntry{
+		ActorID:    dbUser.ID,
+		Action:     auditActionOAuthAuthorized,
+		TargetType: "oauth_client",
+		TargetID:   req.Client.ID.String(),
+		Metadata:   map[string]any{"scopes": req.Scopes},
+	})
+
+	redirectToClient(w, r, req, url.Values{"code": {code}})
+}
+
+// checkConsentCredentials checks the sign in of the consent form like
+// POST /api/login and POST /api/login/mfa do together.
+func (cfg *apiConfig) checkConsentCredentials(r *http.Request, email, password, code string) (database.User, bool, error) {
+	// accounts created before validation may not have a normalized email
+	normalized, err := mailer.NormalizeAddress(email)
+	if err != nil {
+		normalized = email
+	}
+
+	dbUser, err := cfg.dbQueries.GetUserByEmail(r.Context(), normalized)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			cfg.passwordHasher.VerifyUnknownUser(password)
+			cfg.recordAudit(r, auditEntry{
+				Action:   auditActionLoginFailed,
+				Metadata: map[string]any{"email": email, "reason": "user_not_found", "oauth": true},
+			})
+			cfg.recordLoginFailure(r, normalized)
+			return database.User{}, false, nil
+		}
+		return database.User{}, false, err
+	}
+
+	validPassword, err := cfg.checkPassword(r.Context(), dbUser, password)
+	if err != nil {
+		return database.User{}, false, err
+	}
+	if !validPassword {
+		cfg.recordAudit(r, auditEntry{
+			Action:     auditActionLoginFailed,
+			TargetType: "user",
+			TargetID:   dbUser.ID.String(),
+			Metadata:   map[string]any{"email": email, "reason": "invalid_password", "oauth": true},
+		})
+		cfg.recordLoginFailure(r, normalized)
+		return database.User{}, false, nil
+	}
+
+	totp, err := cfg.dbQueries.GetUserTOTP(r.Context(), dbUser.ID)
+	if err != nil && err != sql.ErrNoRows {
+		return database.User{}, false, err
+	}
+	if err == nil && totp.EnabledAt.Valid {
+		_, valid, err := cfg.verifySecondFactor(r.Context(), totp, code, "")
+		if err != nil {
+			return database.User{}, false, err
+		}
+		if !valid {
+			cfg.recordAudit(r, auditEntry{
+				Action:     auditActionLoginFailed,
+				TargetType: "user",
+				TargetID:   dbUser.ID.String(),
+				Metadata:   map[string]any{"reason": "invalid_mfa_code", "oauth": true},
+			})
+			cfg.recordLoginFailure(r, normalized)
+			return database.User{}, false, nil
+		}
+	}
+
+	cfg.clearLoginFailures(r, normalized)
+	return dbUser, true, nil
+}
+
+func respondWithOAuthError(w http.ResponseWriter, code int, oauthErr, description string) {
+	if code == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Basic realm="chirpy"`)
+	}
+	respondWithJSON(w, code, struct {
+		Error       string `json:"error"`
+		Description string `json:"error_description,omitempty"`
+	}{
+		Error:       oauthErr,
+		Description: description,
+	})
+}
+
+var errInvalidOAuthClient = errors.New("invalid oauth client")
+
+// authenticateOAuthClient identifies the client calling the token,
+// revocation or introspection endpoint by HTTP Basic auth or the client_id
+// and client_secret form fields. Public clients only send their client_id.
+func (cfg *apiConfig) authenticateOAuthClient(r *http.Request) (database.OauthClient, error) {
+	clientIDString, secret, ok := r.BasicAuth()
+	if !ok {
+		clientIDString = r.PostForm.Get("client_id")
+		secret = r.PostForm.Get("client_secret")
+	}
+
+	clientID, err := uuid.Parse(clientIDString)
+	if err != nil {
+		return database.OauthClient{}, errInvalidOAuthClient
+	}
+
+	client, err := cfg.dbQueries.GetOAuthClient(r.Context(), clientID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return database.OauthClient{}, errInvalidOAuthClient
+		}
+		return database.OauthClient{}, err
+	}
+
+	if client.ClientSecretHash.Valid {
+		if subtle.ConstantTimeCompare([]byte(auth.HashToken(secret)), []byte(client.ClientSecretHash.String)) != 1 {
+			return database.OauthClient{}, errInvalidOAuthClient
+		}
+	}
+
+	return client, nil
+}
+
+// parseOAuthClientRequest does the common part of the endpoints clients call
+// directly. When it fails the error response has already been written.
+func (cfg *apiConfig) parseOAuthClientRequest(w http.ResponseWriter, r *http.Request) (database.OauthClient, bool) {
+	w.Header().Set("Cache-Control", "no-store")
+
+	if err := r.ParseForm(); err != nil {
+		respondWithOAuthError(w, http.StatusBadRequest, oauthErrInvalidRequest, "Invalid form body")
+		return database.OauthClient{}, false
+	}
+
+	client, err := cfg.authenticateOAuthClient(r)
+	if err != nil {
+		if errors.Is(err, errInvalidOAuthClient) {
+			respondWithOAuthError(w, http.StatusUnauthorized, oauthErrInvalidClient, "Client authentication failed")
+			return database.OauthClient{}, false
+		}
+		respondWithOAuthError(w, http.StatusInternalServerError, oauthErrServerError, "Failed to get client")
+		return database.OauthClient{}, false
+	}
+
+	return client, true
+}
+
+func (cfg *apiConfig) handlerOAuthToken(w http.ResponseWriter, r *http.Request) {
+	client, ok := cfg.parseOAuthClientRequest(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		cfg.oauthAuthorizationCodeGrant(w, r, client)
+	case "refresh_token":
+		cfg.oauthRefreshTokenGrant(w, r, client)
+	default:
+		respondWithOAuthError(w, http.StatusBadRequest, oauthErrUnsupportedGrantType, "grant_type must be authorization_code or refresh_token")
+	}
+}
+
+func (cfg *apiConfig) oauthAuthorizationCodeGrant(w http.ResponseWriter, r *http.Request, client database.OauthClient) {
+	code := r.PostForm.Get("code")
+	if code == "" {
+		respondWithOAuthError(w, http.StatusBadRequest, oauthErrInvalidRequest, "code is required")
+		return
+	}
+
+	storedCode, err := cfg.dbQueries.ConsumeOAuthAuthorizationCode(r.Context(), auth.HashToken(code))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithOAuthError(w, http.StatusBadRequest, oauthErrInvalidGrant, "Invalid or expired authorization code")
+			return
+		}
+		respondWithOAuthError(w, http.StatusInternalServerError, oauthErrServerError, "Failed to get authorization code")
+		return
+	}
+
+	if storedCode.ClientID != client.ID || storedCode.RedirectUri != r.PostForm.Get("redirect_uri") {
+		respondWithOAuthError(w, http.StatusBadRequest, oauthErrInvalidGrant, "Invalid or expired authorization code")
+		return
+	}
+	if !auth.VerifyPKCE(r.PostForm.Get("code_verifier"), storedCode.CodeChallenge) {
+		respondWithOAuthError(w, http.StatusBadRequest, oauthErrInvalidGrant, "Invalid code_verifier")
+		return
+	}
+
+	refreshToken, storedToken, err := issueRefreshToken(r.Context(), cfg.dbQueries, storedCode.UserID, sessionInfo{
+		FamilyID:   uuid.New(),
+		DeviceName: client.Name,
+		UserAgent:  r.UserAgent(),
+		IPAddress:  clientIP(r),
+		StartedAt:  time.Now(),
+		ClientID:   uuid.NullUUID{UUID: client.ID, Valid: true},
+		Scopes:     storedCode.Scopes,
+	})
+	if err != nil {
+		respondWithOAuthError(w, http.StatusInternalServerError, oauthErrServerError, "Failed to create refresh token")
+		return
+	}
+
+	cfg.recordAudit(r, auditEntry{
+		ActorID:    storedCode.UserID,
+		Action:     auditActionRefreshTokenIssued,
+		TargetType: "user",
+		TargetID:   storedCode.UserID.String(),
+		Metadata:   map[string]any{"family_id": storedToken.FamilyID, "expires_at": storedToken.ExpiresAt, "client_id": client.ID},
+	})
+
+	cfg.respondWithOAuthTokens(w, storedCode.UserID, client.ID, storedCode.Scopes, refreshToken)
+}
+
+func (cfg *apiConfig) oauthRefreshTokenGrant(w http.ResponseWriter, r *http.Request, client database.OauthClient) {
+	refreshToken := r.PostForm.Get("refresh_token")
+	if refreshToken == "" {
+		respondWithOAuthError(w, http.StatusBadRequest, oauthErrInvalidRequest, "refresh_token is required")
+		return
+	}
+
+	storedToken, err := cfg.dbQueries.GetRefreshToken(r.Context(), auth.HashToken(refreshToken))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithOAuthError(w, http.StatusBadRequest, oauthErrInvalidGrant, "Invalid or expired refresh token")
+			return
+		}
+		respondWithOAuthError(w, http.StatusInternalServerError, oauthErrServerError, "Failed to get refresh token")
+		return
+	}
+
+	if !storedToken.ClientID.Valid || storedToken.ClientID.UUID != client.ID ||
+		storedToken.RevokedAt.Valid || !storedToken.ExpiresAt.After(time.Now()) {
+		respondWithOAuthError(w, http.StatusBadRequest, oauthErrInvalidGrant, "Invalid or expired refresh token")
+		return
+	}
+
+	// the client may ask for fewer scopes than were granted, never more
+	scopes := storedToken.Scopes
+	if scope := r.PostForm.Get("scope"); scope != "" {
+		scopes = auth.ParseScope(scope)
+		for _, s := range scopes {
+			if !auth.HasScope(storedToken.Scopes, s) {
+				respondWithOAuthError(w, http.StatusBadRequest, oauthErrInvalidScope, "Scope "+s+" was not granted")
+				return
+			}
+		}
+	}
+
+	newRefreshToken, _, err := cfg.rotateRefreshToken(r, storedToken)
+	if err != nil {
+		if errors.Is(err, errRefreshTokenReused) {
+			respondWithOAuthError(w, http.StatusBadRequest, oauthErrInvalidGrant, "Invalid or expired refresh token")
+			return
+		}
+		respondWithOAuthError(w, http.StatusInternalServerError, oauthErrServerError, "Failed to rotate refresh token")
+		return
+	}
+
+	cfg.respondWithOAuthTokens(w, storedToken.UserID, client.ID, scopes, newRefreshToken)
+}
+
+func (cfg *apiConfig) respondWithOAuthTokens(w http.ResponseWriter, userID, clientID uuid.UUID, scopes []string, refreshToken string) {
+	accessToken, err := cfg.keyRing.MakeOAuthToken(userID, clientID, scopes, oauthAccessTokenLifetime)
+	if err != nil {
+		respondWithOAuthError(w, http.StatusInternalServerError, oauthErrServerError, "Failed to create access token")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, struct {
+		AccessToken  string `json:"access_token"`
+		TokenType    string `json:"token_type"`
+		ExpiresIn    int    `json:"expires_in"`
+		RefreshToken string `json:"refresh_token"`
+		Scope        string `json:"scope"`
+	}{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(oauthAccessTokenLifetime.Seconds()),
+		RefreshToken: refreshToken,
+		Scope:        strings.Join(scopes, " "),
+	})
+}
+
+// handlerOAuthRevoke implements RFC 7009. Revoking a refresh token ends the
+// whole grant. Access tokens are short lived JWTs and can't be revoked, and
+// unknown tokens are not an error, so the answer is always 200.
+func (cfg *apiConfig) handlerOAuthRevoke(w http.ResponseWriter, r *http.Request) {
+	client, ok := cfg.parseOAuthClientRequest(w, r)
+	if !ok {
+		return
+	}
+
+	storedToken, err := cfg.dbQueries.GetRefreshToken(r.Context(), auth.HashToken(r.PostForm.Get("token")))
+	if err != nil && err != sql.ErrNoRows {
+		respondWithOAuthError(w, http.StatusInternalServerError, oauthErrServerError, "Failed to get token")
+		return
+	}
+
+	if err == nil && storedToken.ClientID.Valid && storedToken.ClientID.UUID == client.ID {
+		revoked, err := cfg.dbQueries.RevokeRefreshTokenFamily(r.Context(), storedToken.FamilyID)
+		if err != nil {
+			respondWithOAuthError(w, http.StatusInternalServerError, oauthErrServerError, "Failed to revoke token")
+			return
+		}
+		cfg.recordAudit(r, auditEntry{
+			ActorID:    storedToken.UserID,
+			Action:     auditActionRefreshTokenRevoked,
+			TargetType: "user",
+			TargetID:   storedToken.UserID.String(),
+			Metadata:   map[string]any{"family_id": storedToken.FamilyID, "revoked_tokens": revoked, "client_id": client.ID},
+		})
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// handlerOAuthIntrospect implements RFC 7662. A client can only introspect the
+// tokens issued to itself, any other token is reported as inactive.
+func (cfg *apiConfig) handlerOAuthIntrospect(w http.ResponseWriter, r *http.Request) {
+	type introspection struct {
+		Active    bool   `json:"active"`
+		Scope     string `json:"scope,omitempty"`
+		ClientID  string `json:"client_id,omitempty"`
+		Subject   string `json:"sub,omitempty"`
+		ExpiresAt int64  `json:"exp,omitempty"`
+		TokenType string `json:"token_type,omitempty"`
+	}
+
+	client, ok := cfg.parseOAuthClientRequest(w, r)
+	if !ok {
+		return
+	}
+
+	token := r.PostForm.Get("token")
+
+	if claims, err := cfg.keyRing.ValidateOAuthToken(token); err == nil {
+		if claims.ClientID != client.ID {
+			respondWithJSON(w, http.StatusOK, introspection{})
+			return
+		}
+		respondWithJSON(w, http.StatusOK, introspection{
+			Active:    true,
+			Scope:     strings.Join(claims.Scopes, " "),
+			ClientID:  claims.ClientID.String(),
+			Subject:   claims.UserID.String(),
+			ExpiresAt: claims.ExpiresAt.Unix(),
+			TokenType: "access_token",
+		})
+		return
+	}
+
+	storedToken, err := cfg.dbQueries.GetRefreshToken(r.Context(), auth.HashToken(token))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithJSON(w, http.StatusOK, introspection{})
+			return
+		}
+		respondWithOAuthError(w, http.StatusInternalServerError, oauthErrServerError, "Failed to get token")
+		return
+	}
+
+	if !storedToken.ClientID.Valid || storedToken.ClientID.UUID != client.ID ||
+		storedToken.RevokedAt.Valid || storedToken.RotatedAt.Valid || !storedToken.ExpiresAt.After(time.Now()) {
+		respondWithJSON(w, http.StatusOK, introspection{})
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, introspection{
+		Active:    true,
+		Scope:     strings.Join(storedToken.Scopes, " "),
+		ClientID:  client.ID.String(),
+		Subject:   storedToken.UserID.String(),
+		ExpiresAt: storedToken.ExpiresAt.Unix(),
+		TokenType: "refresh_token",
+	})
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/auth"
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/database"
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/model"
+	"github.com/google/uuid"
+)
+
+const (
+	auditActionOAuthClientCreated = "oauth_client.created"
+	auditActionOAuthClientDeleted = "oauth_client.deleted"
+)
+
+const (
+	maxOAuthClientName         = 100
+	maxOAuthClientRedirectURIs = 10
+)
+
+func oauthClientToModel(client database.OauthClient) model.OAuthClient {
+	return model.OAuthClient{
+		ID:           client.ID,
+		Name:         client.Name,
+		RedirectURIs: client.RedirectUris,
+		Scopes:       client.Scopes,
+		Confidential: client.ClientSecretHash.Valid,
+		CreatedAt:    client.CreatedAt,
+	}
+}
+
+// handlerOAuthClientsCreate registers a third-party application owned by the
+// user. Confidential clients get a secret, public ones (mobile and browser
+// apps) rely on PKCE alone.
+func (cfg *apiConfig) handlerOAuthClientsCreate(w http.ResponseWriter, r *http.Request) {
+	type requestBody struct {
+		Name         string   `json:"name"`
+		RedirectURIs []string `json:"redirect_uris"`
+		Scopes       []string `json:"scopes"`
+		Confidential bool     `json:"confidential"`
+	}
+
+	userID, ok := cfg.authenticateUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req requestBody
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Name == "" || len(req.Name) > maxOAuthClientName {
+		respondWithError(w, http.StatusBadRequest, "name must be between 1 and 100 characters")
+		return
+	}
+	if len(req.RedirectURIs) == 0 || len(req.RedirectURIs) > maxOAuthClientRedirectURIs {
+		respondWithError(w, http.StatusBadRequest, "redirect_uris must have between 1 and 10 entries")
+		return
+	}
+	for _, uri := range req.RedirectURIs {
+		if !auth.ValidRedirectURI(uri) {
+			respondWithError(w, http.StatusBadRequest, "redirect_uris must be https URLs without fragment (http only for localhost)")
+			return
+		}
+	}
+
+	scopes, err := auth.NormalizeScopes(req.Scopes)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "scopes must be one or more of chirps:read, chirps:write, profile:write")
+		return
+	}
+
+	var secret string
+	var secretHash sql.NullString
+	if req.Confidential {
+		secret, err = auth.MakeSecureToken()
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Failed to create client secret")
+			return
+		}
+		secretHash = sql.NullString{String: auth.HashToken(secret), Valid: true}
+	}
+
+	dbClient, err := cfg.dbQueries.CreateOAuthClient(r.Context(), database.CreateOAuthClientParams{
+		OwnerID:          userID,
+		Name:             req.Name,
+		ClientSecretHash: secretHash,
+		RedirectUris:     req.RedirectURIs,
+		Scopes:           scopes,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to create client")
+		return
+	}
+
+	cfg.recordAudit(r, auditEntry{
+		ActorID:    userID,
+		Action:     auditActionOAuthClientCreated,
+		TargetType: "oauth_client",
+		TargetID:   dbClient.ID.String(),
+		Metadata:   map[string]any{"name": dbClient.Name, "scopes": dbClient.Scopes},
+	})
+
+	// the secret is only ever shown here
+	client := oauthClientToModel(dbClient)
+	client.ClientSecret = secret
+	respondWithJSON(w, http.StatusCreated, client)
+}
+
+func (cfg *apiConfig) handlerOAuthClientsList(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateUser(w, r)
+	if !ok {
+		return
+	}
+
+	dbClients, err := cfg.dbQueries.ListOAuthClientsByOwner(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch clients")
+		return
+	}
+
+	clients := make([]model.OAuthClient, len(dbClients))
+	for i, client := range dbClients {
+		clients[i] = oauthClientToModel(client)
+	}
+
+	respondWithJSON(w, http.StatusOK, clients)
+}
+
+// handlerOAuthClientsDelete removes a client together with every token that
+// was issued to it.
+func (cfg *apiConfig) handlerOAuthClientsDelete(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateUser(w, r)
+	if !ok {
+		return
+	}
+
+	clientID, err := uuid.Parse(r.PathValue("clientID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid id format")
+		return
+	}
+
+	deleted, err := cfg.dbQueries.DeleteOAuthClient(r.Context(), database.DeleteOAuthClientParams{
+		ID:      clientID,
+		OwnerID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to delete client")
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "client not found")
+		return
+	}
+
+	cfg.recordAudit(r, auditEntry{
+		ActorID:    userID,
+		Action:     auditActionOAuthClientDeleted,
+		TargetType: "oauth_client",
+		TargetID:   clientID.String(),
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
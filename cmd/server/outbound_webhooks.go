@@ -0,0 +1,666 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/auth"
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/database"
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/model"
+	"github.com/google/uuid"
+)
+
+// events integrators can subscribe their endpoints to, the chirp ones are
+// also streamed. There are no follows yet, user.followed is accepted so
+// endpoints can subscribe now and will get it once there are.
+const (
+	eventChirpCreated = "chirp.created"
+	eventChirpDeleted = "chirp.deleted"
+	eventUserUpdated  = "user.updated"
+	eventUserFollowed = "user.followed"
+)
+
+var outboundWebhookEvents = map[string]bool{
+	eventChirpCreated: true,
+	eventChirpDeleted: true,
+	eventUserUpdated:  true,
+	eventUserFollowed: true,
+}
+
+// outboundWebhookEventScopes is the scope a user must have granted an OAuth
+// client for its endpoints to get the event. user.updated carries nothing
+// private, any authorization will do.
+var outboundWebhookEventScopes = map[string]string{
+	eventChirpCreated: auth.ScopeChirpsRead,
+	eventChirpDeleted: auth.ScopeChirpsRead,
+}
+
+const (
+	deliveryPending   = "pending"
+	deliverySucceeded = "succeeded"
+	deliveryDead      = "dead"
+)
+
+// headers sent with every delivery, the signature scheme is the one Polka
+// uses for the webhooks it sends us
+const (
+	outboundSignatureHeader = "X-Chirpy-Signature"
+	outboundTimestampHeader = "X-Chirpy-Timestamp"
+	outboundEventHeader     = "X-Chirpy-Event"
+	outboundDeliveryHeader  = "X-Chirpy-Delivery"
+)
+
+const (
+	auditActionWebhookEndpointCreated = "webhook_endpoint.created"
+	auditActionWebhookEndpointDeleted = "webhook_endpoint.deleted"
+)
+
+const (
+	maxWebhookEndpointsPerUser = 10
+
+	webhookDeliveryPollInterval = 5 * time.Second
+	webhookDeliveryBatchSize    = 20
+	webhookDeliveryTimeout      = 10 * time.Second
+	// claimed deliveries are left alone by other workers for this long
+	webhookDeliveryLease = 5 * time.Minute
+
+	// retries wait 30 seconds, doubling each time but never more than 6
+	// hours, and the delivery is dead after the last attempt. The 9 waits add
+	// up to 30s × 511, about 4 hours and 15 minutes after the event
+	maxWebhookDeliveryAttempts = 10
+	webhookRetryBaseDelay      = 30 * time.Second
+	webhookRetryMaxDelay       = 6 * time.Hour
+
+	defaultWebhookDeliveryLimit = 100
+	maxWebhookDeliveryLimit     = 1000
+)
+
+// outboundWebhookPayload is the body of every delivery. ID is the same for
+// every endpoint the event goes to and across retries, receivers can use it
+// to drop duplicates.
+type outboundWebhookPayload struct {
+	ID        uuid.UUID `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+var errWebhookAddressBlocked = errors.New("address is not publicly routable")
+
+// validWebhookURL reports whether url can be registered as a webhook
+// endpoint: an absolute https URL without credentials or fragment, whose
+// host isn't an address of the server's own network.
+func validWebhookURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "https" || u.Host == "" || u.Fragment != "" || u.User != nil {
+		return false
+	}
+
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return false
+	}
+	if ip, err := netip.ParseAddr(host); err == nil {
+		return publicWebhookAddr(ip)
+	}
+	return true
+}
+
+// nonGlobalPrefixes are the IANA special-purpose ranges that aren't globally
+// reachable: loopback, private, shared (CGNAT), link-local, documentation,
+// benchmarking, reserved and multicast, plus the IPv6 forms that embed or
+// translate to IPv4 addresses (NAT64, 6to4, Teredo) and could reach the ones
+// above.
+var nonGlobalPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("10.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("127.0.0.0/8"),
+	netip.MustParsePrefix("169.254.0.0/16"),
+	netip.MustParsePrefix("172.16.0.0/12"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("192.88.99.0/24"),
+	netip.MustParsePrefix("192.168.0.0/16"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("224.0.0.0/4"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+
+	netip.MustParsePrefix("::/128"),
+	netip.MustParsePrefix("::1/128"),
+	netip.MustParsePrefix("::ffff:0:0/96"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("100::/64"),
+	netip.MustParsePrefix("2001::/23"),
+	netip.MustParsePrefix("2001:db8::/32"),
+	netip.MustParsePrefix("2002::/16"),
+	netip.MustParsePrefix("fc00::/7"),
+	netip.MustParsePrefix("fe80::/10"),
+	netip.MustParsePrefix("fec0::/10"),
+	netip.MustParsePrefix("ff00::/8"),
+}
+
+// publicWebhookAddr reports whether deliveries may be sent to ip, which must
+// be outside every non-global range. IPv4-mapped addresses are checked as
+// the IPv4 address they carry.
+func publicWebhookAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsValid() {
+		return false
+	}
+	for _, prefix := range nonGlobalPrefixes {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// webhookDialControl refuses connections to addresses that aren't public.
+// The URL is checked when the endpoint is registered, but its host can
+// resolve to anything later, so the address is checked again on every dial.
+func webhookDialControl(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil || !publicWebhookAddr(ip) {
+		return fmt.Errorf("dialing %s: %w", address, errWebhookAddressBlocked)
+	}
+	return nil
+}
+
+func newWebhookClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: webhookDeliveryTimeout,
+		Control: webhookDialControl,
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// a proxy would make the dialer check the proxy's address instead
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   webhookDeliveryTimeout,
+		Transport: transport,
+		// a redirect is answered like any other non 2xx response
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// webhookRetryDelay returns how long to wait before the next attempt of a
+// delivery that has failed attempts times.
+func webhookRetryDelay(attempts int32) time.Duration {
+	delay := webhookRetryBaseDelay
+	for i := int32(1); i < attempts; i++ {
+		delay *= 2
+		if delay >= webhookRetryMaxDelay {
+			return webhookRetryMaxDelay
+		}
+	}
+	return delay
+}
+
+// emitWebhookEvent queues eventType for every endpoint that should see what
+// userID did. Failures are only logged, the action already happened.
+func (cfg *apiConfig) emitWebhookEvent(ctx context.Context, userID uuid.UUID, eventType string, data any) {
+	ctx = context.WithoutCancel(ctx)
+
+	params := database.ListWebhookEndpointsForEventParams{
+		EventType: eventType,
+		UserID:    userID,
+	}
+	if scope, ok := outboundWebhookEventScopes[eventType]; ok {
+		params.Scope = sql.NullString{String: scope, Valid: true}
+	}
+	endpoints, err := cfg.dbQueries.ListWebhookEndpointsForEvent(ctx, params)
+	if err != nil {
+		log.Printf("Error listing webhook endpoints for %s: %s", eventType, err)
+		return
+	}
+	if len(endpoints) == 0 {
+		return
+	}
+
+	eventID := uuid.New()
+	payload, err := json.Marshal(outboundWebhookPayload{
+		ID:        eventID,
+		Type:      eventType,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		log.Printf("Error marshaling webhook payload for %s: %s", eventType, err)
+		return
+	}
+
+	for _, endpoint := range endpoints {
+		err := cfg.dbQueries.EnqueueWebhookDelivery(ctx, database.EnqueueWebhookDeliveryParams{
+			EndpointID: endpoint.ID,
+			EventID:    eventID,
+			EventType:  eventType,
+			Payload:    payload,
+		})
+		if err != nil {
+			log.Printf("Error queueing %s for webhook endpoint %s: %s", eventType, endpoint.ID, err)
+		}
+	}
+}
+
+// runWebhookDeliveries sends the queued deliveries that are due, polling
+// every webhookDeliveryPollInterval.
+func (cfg *apiConfig) runWebhookDeliveries() {
+	ticker := time.NewTicker(webhookDeliveryPollInterval)
+	defer ticker.Stop()
+
+	for {
+		cfg.sendDueWebhookDeliveries(context.Background())
+		<-ticker.C
+	}
+}
+
+func (cfg *apiConfig) sendDueWebhookDeliveries(ctx context.Context) {
+	deliveries, err := cfg.dbQueries.ClaimDueWebhookDeliveries(ctx, database.ClaimDueWebhookDeliveriesParams{
+		LeaseUntil: time.Now().Add(webhookDeliveryLease),
+		Limit:      webhookDeliveryBatchSize,
+	})
+	if err != nil {
+		log.Printf("Error claiming webhook deliveries: %s", err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cfg.sendWebhookDelivery(ctx, delivery)
+		}()
+	}
+	wg.Wait()
+}
+
+func (cfg *apiConfig) sendWebhookDelivery(ctx context.Context, delivery database.WebhookDelivery) {
+	endpoint, err := cfg.dbQueries.GetWebhookEndpoint(ctx, delivery.EndpointID)
+	if err != nil {
+		// a deleted endpoint takes its deliveries with it
+		if err != sql.ErrNoRows {
+			log.Printf("Error getting webhook endpoint %s: %s", delivery.EndpointID, err)
+		}
+		return
+	}
+
+	responseStatus, sendErr := cfg.postWebhook(ctx, endpoint, delivery)
+
+	params := database.RecordWebhookDeliveryAttemptParams{
+		ID:            delivery.ID,
+		Status:        deliverySucceeded,
+		NextAttemptAt: delivery.NextAttemptAt,
+	}
+	if responseStatus != 0 {
+		params.ResponseStatus = sql.NullInt32{Int32: int32(responseStatus), Valid: true}
+	}
+	if sendErr != nil {
+		attempts := delivery.Attempts + 1
+		params.LastError = sendErr.Error()
+		params.Status = deliveryPending
+		params.NextAttemptAt = time.Now().Add(webhookRetryDelay(attempts))
+		if attempts >= maxWebhookDeliveryAttempts {
+			params.Status = deliveryDead
+		}
+	}
+
+	if _, err := cfg.dbQueries.RecordWebhookDeliveryAttempt(ctx, params); err != nil {
+		log.Printf("Error recording webhook delivery %s: %s", delivery.ID, err)
+	}
+}
+
+// postWebhook sends delivery to endpoint, signed with the endpoint's secret.
+// It returns the response status, zero when there was no response.
+func (cfg *apiConfig) postWebhook(ctx context.Context, endpoint database.WebhookEndpoint, delivery database.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.Url, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Chirpy-Webhooks/1.0")
+	req.Header.Set(outboundEventHeader, delivery.EventType)
+	req.Header.Set(outboundDeliveryHeader, delivery.ID.String())
+	req.Header.Set(outboundTimestampHeader, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(outboundSignatureHeader, auth.SignWebhook(endpoint.Secret, now, delivery.Payload))
+
+	resp, err := cfg.webhookClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// drained so the connection can be reused, receivers have no say beyond the status
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint answered %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+func webhookEndpointToModel(endpoint database.WebhookEndpoint) model.WebhookEndpoint {
+	webhookEndpoint := model.WebhookEndpoint{
+		ID:        endpoint.ID,
+		URL:       endpoint.Url,
+		Events:    endpoint.Events,
+		CreatedAt: endpoint.CreatedAt,
+	}
+	if endpoint.OauthClientID.Valid {
+		clientID := endpoint.OauthClientID.UUID
+		webhookEndpoint.OAuthClientID = &clientID
+	}
+	return webhookEndpoint
+}
+
+func webhookDeliveryToModel(delivery database.WebhookDelivery) model.WebhookDelivery {
+	webhookDelivery := model.WebhookDelivery{
+		ID:        delivery.ID,
+		EventID:   delivery.EventID,
+		EventType: delivery.EventType,
+		Payload:   delivery.Payload,
+		Status:    delivery.Status,
+		Attempts:  delivery.Attempts,
+		LastError: delivery.LastError,
+		CreatedAt: delivery.CreatedAt,
+	}
+	if delivery.Status == deliveryPending {
+		nextAttemptAt := delivery.NextAttemptAt
+		webhookDelivery.NextAttemptAt = &nextAttemptAt
+	}
+	if delivery.LastAttemptAt.Valid {
+		lastAttemptAt := delivery.LastAttemptAt.Time
+		webhookDelivery.LastAttemptAt = &lastAttemptAt
+	}
+	if delivery.ResponseStatus.Valid {
+		responseStatus := delivery.ResponseStatus.Int32
+		webhookDelivery.ResponseStatus = &responseStatus
+	}
+	if delivery.DeliveredAt.Valid {
+		deliveredAt := delivery.DeliveredAt.Time
+		webhookDelivery.DeliveredAt = &deliveredAt
+	}
+	return webhookDelivery
+}
+
+// handlerWebhookEndpointsCreate registers an endpoint for the user's events,
+// or with oauth_client_id for the events of everyone who authorized one of
+// the user's OAuth clients. The signing secret is only shown here.
+func (cfg *apiConfig) handlerWebhookEndpointsCreate(w http.ResponseWriter, r *http.Request) {
+	type requestBody struct {
+		URL           string     `json:"url"`
+		Events        []string   `json:"events"`
+		OAuthClientID *uuid.UUID `json:"oauth_client_id"`
+	}
+
+	userID, ok := cfg.authenticateUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req requestBody
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if !validWebhookURL(req.URL) {
+		respondWithError(w, http.StatusBadRequest, "url must be a public https URL without credentials or fragment")
+		return
+	}
+
+	seen := map[string]bool{}
+	events := []string{}
+	for _, event := range req.Events {
+		if !outboundWebhookEvents[event] {
+			respondWithError(w, http.StatusBadRequest, "events must be one or more of chirp.created, chirp.deleted, user.updated, user.followed")
+			return
+		}
+		if !seen[event] {
+			seen[event] = true
+			events = append(events, event)
+		}
+	}
+	if len(events) == 0 {
+		respondWithError(w, http.StatusBadRequest, "events must be one or more of chirp.created, chirp.deleted, user.updated, user.followed")
+		return
+	}
+	sort.Strings(events)
+
+	var clientID uuid.NullUUID
+	if req.OAuthClientID != nil {
+		client, err := cfg.dbQueries.GetOAuthClient(r.Context(), *req.OAuthClientID)
+		if err != nil && err != sql.ErrNoRows {
+			respondWithError(w, http.StatusInternalServerError, "Failed to get OAuth client")
+			return
+		}
+		if err == sql.ErrNoRows || client.OwnerID != userID {
+			respondWithError(w, http.StatusNotFound, "OAuth client not found")
+			return
+		}
+		clientID = uuid.NullUUID{UUID: client.ID, Valid: true}
+	}
+
+	existing, err := cfg.dbQueries.ListWebhookEndpointsByOwner(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch webhook endpoints")
+		return
+	}
+	if len(existing) >= maxWebhookEndpointsPerUser {
+		respondWithError(w, http.StatusConflict, "A user can have at most 10 webhook endpoints")
+		return
+	}
+
+	secret, err := auth.MakeSecureToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to create webhook secret")
+		return
+	}
+
+	endpoint, err := cfg.dbQueries.CreateWebhookEndpoint(r.Context(), database.CreateWebhookEndpointParams{
+		OwnerID:       userID,
+		OauthClientID: clientID,
+		Url:           req.URL,
+		Events:        events,
+		Secret:        secret,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to create webhook endpoint")
+		return
+	}
+
+	cfg.recordAudit(r, auditEntry{
+		ActorID:    userID,
+		Action:     auditActionWebhookEndpointCreated,
+		TargetType: "webhook_endpoint",
+		TargetID:   endpoint.ID.String(),
+		Metadata:   map[string]any{"url": endpoint.Url, "events": endpoint.Events},
+	})
+
+	response := webhookEndpointToModel(endpoint)
+	response.Secret = secret
+	respondWithJSON(w, http.StatusCreated, response)
+}
+
+func (cfg *apiConfig) handlerWebhookEndpointsList(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateUser(w, r)
+	if !ok {
+		return
+	}
+
+	dbEndpoints, err := cfg.dbQueries.ListWebhookEndpointsByOwner(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch webhook endpoints")
+		return
+	}
+
+	endpoints := make([]model.WebhookEndpoint, len(dbEndpoints))
+	for i, endpoint := range dbEndpoints {
+		endpoints[i] = webhookEndpointToModel(endpoint)
+	}
+
+	respondWithJSON(w, http.StatusOK, endpoints)
+}
+
+func (cfg *apiConfig) handlerWebhookEndpointsDelete(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateUser(w, r)
+	if !ok {
+		return
+	}
+
+	endpointID, err := uuid.Parse(r.PathValue("endpointID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid endpoint ID format")
+		return
+	}
+
+	rows, err := cfg.dbQueries.DeleteWebhookEndpoint(r.Context(), database.DeleteWebhookEndpointParams{
+		ID:      endpointID,
+		OwnerID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to delete webhook endpoint")
+		return
+	}
+	if rows == 0 {
+		respondWithError(w, http.StatusNotFound, "Webhook endpoint not found")
+		return
+	}
+
+	cfg.recordAudit(r, auditEntry{
+		ActorID:    userID,
+		Action:     auditActionWebhookEndpointDeleted,
+		TargetType: "webhook_endpoint",
+		TargetID:   endpointID.String(),
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getOwnedWebhookEndpoint loads the endpoint named by the endpointID path
+// value if userID owns it, answering the request itself otherwise.
+func (cfg *apiConfig) getOwnedWebhookEndpoint(w http.ResponseWriter, r *http.Request, userID uuid.UUID) (database.WebhookEndpoint, bool) {
+	endpointID, err := uuid.Parse(r.PathValue("endpointID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid endpoint ID format")
+		return database.WebhookEndpoint{}, false
+	}
+
+	endpoint, err := cfg.dbQueries.GetWebhookEndpoint(r.Context(), endpointID)
+	if err != nil && err != sql.ErrNoRows {
+		respondWithError(w, http.StatusInternalServerError, "Failed to get webhook endpoint")
+		return database.WebhookEndpoint{}, false
+	}
+	if err == sql.ErrNoRows || endpoint.OwnerID != userID {
+		respondWithError(w, http.StatusNotFound, "Webhook endpoint not found")
+		return database.WebhookEndpoint{}, false
+	}
+	return endpoint, true
+}
+
+func (cfg *apiConfig) handlerWebhookDeliveriesList(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateUser(w, r)
+	if !ok {
+		return
+	}
+
+	endpoint, ok := cfg.getOwnedWebhookEndpoint(w, r, userID)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	params := database.ListWebhookDeliveriesParams{EndpointID: endpoint.ID, Limit: defaultWebhookDeliveryLimit}
+
+	if status := query.Get("status"); status != "" {
+		switch status {
+		case deliveryPending, deliverySucceeded, deliveryDead:
+		default:
+			respondWithError(w, http.StatusBadRequest, "status must be pending, succeeded or dead")
+			return
+		}
+		params.Status.String, params.Status.Valid = status, true
+	}
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxWebhookDeliveryLimit {
+			respondWithError(w, http.StatusBadRequest, "limit must be between 1 and 1000")
+			return
+		}
+		params.Limit = int32(n)
+	}
+
+	dbDeliveries, err := cfg.dbQueries.ListWebhookDeliveries(r.Context(), params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch webhook deliveries")
+		return
+	}
+
+	deliveries := make([]model.WebhookDelivery, len(dbDeliveries))
+	for i, delivery := range dbDeliveries {
+		deliveries[i] = webhookDeliveryToModel(delivery)
+	}
+
+	respondWithJSON(w, http.StatusOK, deliveries)
+}
+
+// handlerWebhookDeliveryRetry puts a dead delivery back in the queue with a
+// fresh round of attempts.
+func (cfg *apiConfig) handlerWebhookDeliveryRetry(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateUser(w, r)
+	if !ok {
+		return
+	}
+
+	endpoint, ok := cfg.getOwnedWebhookEndpoint(w, r, userID)
+	if !ok {
+		return
+	}
+
+	deliveryID, err := uuid.Parse(r.PathValue("deliveryID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid delivery ID format")
+		return
+	}
+
+	delivery, err := cfg.dbQueries.RetryWebhookDelivery(r.Context(), database.RetryWebhookDeliveryParams{
+		ID:         deliveryID,
+		EndpointID: endpoint.ID,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusNotFound, "Dead delivery not found")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Failed to retry webhook delivery")
+		return
+	}
+
+	respondWithJSON(w, http.StatusAccepted, webhookDeliveryToModel(delivery))
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/auth"
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/database"
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/mailer"
+)
+
+const (
+	auditActionPasswordResetRequested = "user.password_reset_requested"
+	auditActionPasswordReset          = "user.password_reset"
+)
+
+// maxPendingPasswordResetEmails bounds the reset emails being sent at once,
+// requests past it are answered the same way but send nothing.
+const maxPendingPasswordResetEmails = 16
+
+// passwordResetThrottleKeys returns the keys reset requests are counted under,
+// apart from the login ones so asking for resets doesn't lock logins out. The
+// account key exists for unknown emails too.
+func passwordResetThrottleKeys(email, ip string) (accountKey, ipKey string) {
+	return "reset:account:" + email, "reset:ip:" + ip
+}
+
+func (cfg *apiConfig) handlerPasswordForgot(w http.ResponseWriter, r *http.Request) {
+	type requestBody struct {
+		Email string `json:"email"`
+	}
+
+	var req requestBody
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	// the response is the same whether the account exists or not, so this
+	// endpoint can't be used to find out which emails are registered
+	email, err := mailer.NormalizeAddress(req.Email)
+	if err != nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	ipAddress, userAgent := clientIP(r), r.UserAgent()
+	accountKey, ipKey := passwordResetThrottleKeys(email, ipAddress)
+	wait, err := cfg.throttleLockedFor(r.Context(), accountKey, ipKey)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to check password reset requests")
+		return
+	}
+	if wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		respondWithError(w, http.StatusTooManyRequests, "Too many password reset requests, try again later")
+		return
+	}
+
+	// the client may already be gone, the request should still count
+	ctx := context.WithoutCancel(r.Context())
+	for key, policy := range map[string]auth.LockoutPolicy{
+		accountKey: auth.PasswordResetAccountPolicy,
+		ipKey:      auth.PasswordResetIPPolicy,
+	} {
+		if _, _, err := cfg.countThrottled(ctx, key, policy); err != nil {
+			log.Printf("Error recording password reset request for %s: %s", key, err)
+		}
+	}
+
+	// the lookup and the email run in the background, otherwise the response
+	// time would tell whether the account exists
+	select {
+	case cfg.passwordResetSends <- struct{}{}:
+		go func() {
+			defer func() { <-cfg.passwordResetSends }()
+			cfg.sendPasswordResetEmail(ctx, email, ipAddress, userAgent)
+		}()
+	default:
+		log.Printf("Dropping password reset email for %s, %d already being sent", accountKey, maxPendingPasswordResetEmails)
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// sendPasswordResetEmail mails a reset token to the account registered with
+// email, if there is one. Failures are only logged, the client has already
+// been answered.
+func (cfg *apiConfig) sendPasswordResetEmail(ctx context.Context, email, ipAddress, userAgent string) {
+	dbUser, err := cfg.dbQueries.GetUserByEmail(ctx, email)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("Error looking up user for password reset: %s", err)
+		}
+		return
+	}
+
+	token, err := auth.MakeSecureToken()
+	if err != nil {
+		log.Printf("Error creating password reset token for user %s: %s", dbUser.ID, err)
+		return
+	}
+
+	_, err = cfg.dbQueries.CreatePasswordResetToken(ctx, database.CreatePasswordResetTokenParams{
+		TokenHash: auth.HashToken(token),
+		UserID:    dbUser.ID,
+	})
+	if err != nil {
+		log.Printf("Error storing password reset token for user %s: %s", dbUser.ID, err)
+		return
+	}
+
+	err = cfg.mailer.Send(ctx, mailer.Message{
+		To:      dbUser.Email,
+		Subject: "Reset your Chirpy password",
+		Body: fmt.Sprintf("Someone asked to reset the password of your Chirpy account.\n\n"+
+			"Send this token with your new password to POST /api/password/reset:\n\n"+
+			"%s\n\nThe token expires in 1 hour and can only be used once. "+
+			"If you didn't ask for this you can ignore this email.", token),
+	})
+	if err != nil {
+		log.Printf("Error sending password reset email to user %s: %s", dbUser.ID, err)
+	}
+
+	cfg.writeAudit(ctx, auditEntry{
+		Action:     auditActionPasswordResetRequested,
+		TargetType: "user",
+		TargetID:   dbUser.ID.String(),
+	}, ipAddress, userAgent)
+}
+
+func (cfg *apiConfig) handlerPasswordReset(w http.ResponseWriter, r *http.Request) {
+	type requestBody struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+
+	var req requestBody
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Password == "" {
+		respondWithError(w, http.StatusBadRequest, "password is required")
+		return
+	}
+
+	if !cfg.checkPasswordPolicy(w, req.Password) {
+		return
+	}
+
+	hashedPassword, err := cfg.passwordHasher.Hash(req.Password)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to hash password")
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to reset password")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	resetToken, err := qtx.ConsumePasswordResetToken(r.Context(), auth.HashToken(req.Token))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusBadRequest, "Invalid or expired reset token")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Failed to reset password")
+		return
+	}
+
+	err = qtx.UpdateUserPassword(r.Context(), database.UpdateUserPasswordParams{
+		ID:             resetToken.UserID,
+		HashedPassword: hashedPassword,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to reset password")
+		return
+	}
+
+	if err := qtx.InvalidatePasswordResetTokens(r.Context(), resetToken.UserID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to reset password")
+		return
+	}
+
+	revoked, err := qtx.RevokeAllRefreshTokensForUser(r.Context(), resetToken.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to revoke refresh tokens")
+		return
+	}
+
+	revokedAccessTokens, err := qtx.RevokeAllPersonalAccessTokensForUser(r.Context(), resetToken.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to revoke access tokens")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to reset password")
+		return
+	}
+
+	cfg.recordAudit(r, auditEntry{
+		ActorID:    resetToken.UserID,
+		Action:     auditActionPasswordReset,
+		TargetType: "user",
+		TargetID:   resetToken.UserID.String(),
+		Metadata:   map[string]any{"revoked_refresh_tokens": revoked, "revoked_access_tokens": revokedAccessTokens},
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
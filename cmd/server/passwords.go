@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/auth"
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/database"
+)
+
+// checkPassword verifies the password of a user. A hash made by bcrypt or
+// with older Argon2id parameters is replaced while the password is known.
+func (cfg *apiConfig) checkPassword(ctx context.Context, dbUser database.User, password string) (bool, error) {
+	needsRehash, err := cfg.passwordHasher.Verify(dbUser.HashedPassword, password)
+	if err != nil {
+		if errors.Is(err, auth.ErrPasswordMismatch) {
+			return false, nil
+		}
+		if errors.Is(err, auth.ErrUnknownHashFormat) {
+			log.Printf("User %s has a password hash in an unknown format", dbUser.ID)
+			return false, nil
+		}
+		return false, err
+	}
+
+	if needsRehash {
+		// the login goes on with the old hash if this fails
+		hashedPassword, err := cfg.passwordHasher.Hash(password)
+		if err == nil {
+			err = cfg.dbQueries.UpdateUserPassword(ctx, database.UpdateUserPasswordParams{
+				ID:             dbUser.ID,
+				HashedPassword: hashedPassword,
+			})
+		}
+		if err != nil {
+			log.Printf("Error rehashing password of user %s: %s", dbUser.ID, err)
+		}
+	}
+
+	return true, nil
+}
+
+// checkPasswordPolicy rejects a new password the policy doesn't allow. When
+// it fails the error response has already been written.
+func (cfg *apiConfig) checkPasswordPolicy(w http.ResponseWriter, password string) bool {
+	err := cfg.passwordPolicy.Check(password)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, auth.ErrPasswordTooShort):
+		respondWithError(w, http.StatusBadRequest, "Password must be at least "+strconv.Itoa(cfg.passwordPolicy.MinLength)+" characters long")
+	case errors.Is(err, auth.ErrPasswordTooLong):
+		respondWithError(w, http.StatusBadRequest, "Password must be at most "+strconv.Itoa(cfg.passwordPolicy.MaxLength)+" characters long")
+	case errors.Is(err, auth.ErrPasswordBreached):
+		respondWithError(w, http.StatusBadRequest, "Password is too common or known from a data breach, choose another one")
+	default:
+		respondWithError(w, http.StatusBadRequest, "Invalid password")
+	}
+	return false
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/auth"
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/database"
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/model"
+	"github.com/google/uuid"
+)
+
+const (
+	auditActionPersonalAccessTokenCreated = "personal_access_token.created"
+	auditActionPersonalAccessTokenRevoked = "personal_access_token.revoked"
+)
+
+const (
+	defaultPersonalAccessTokenDays = 30
+	maxPersonalAccessTokenDays     = 365
+	maxPersonalAccessTokenName     = 100
+)
+
+func personalAccessTokenToModel(token database.PersonalAccessToken) model.PersonalAccessToken {
+	pat := model.PersonalAccessToken{
+		ID:        token.ID,
+		Name:      token.Name,
+		Scopes:    token.Scopes,
+		CreatedAt: token.CreatedAt,
+		ExpiresAt: token.ExpiresAt,
+	}
+	if token.LastUsedAt.Valid {
+		lastUsedAt := token.LastUsedAt.Time
+		pat.LastUsedAt = &lastUsedAt
+	}
+	return pat
+}
+
+// handlerPersonalAccessTokensCreate only accepts a JWT, a personal access
+// token can't be used to create more of them.
+func (cfg *apiConfig) handlerPersonalAccessTokensCreate(w http.ResponseWriter, r *http.Request) {
+	type requestBody struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		ExpiresInDays int      `json:"expires_in_days"`
+	}
+
+	userID, ok := cfg.authenticateUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req requestBody
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Name == "" || len(req.Name) > maxPersonalAccessTokenName {
+		respondWithError(w, http.StatusBadRequest, "name must be between 1 and 100 characters")
+		return
+	}
+	if req.ExpiresInDays == 0 {
+		req.ExpiresInDays = defaultPersonalAccessTokenDays
+	}
+	if req.ExpiresInDays < 1 || req.ExpiresInDays > maxPersonalAccessTokenDays {
+		respondWithError(w, http.StatusBadRequest, "expires_in_days must be between 1 and 365")
+		return
+	}
+
+	scopes, err := auth.NormalizeScopes(req.Scopes)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "scopes must be one or more of chirps:read, chirps:write, profile:write")
+		return
+	}
+
+	token, err := auth.MakePersonalAccessToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to create access token")
+		return
+	}
+
+	dbToken, err := cfg.dbQueries.CreatePersonalAccessToken(r.Context(), database.CreatePersonalAccessTokenParams{
+		UserID:    userID,
+		Name:      req.Name,
+		TokenHash: auth.HashToken(token),
+		Scopes:    scopes,
+		ExpiresAt: time.Now().AddDate(0, 0, req.ExpiresInDays),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to store access token")
+		return
+	}
+
+	cfg.recordAudit(r, auditEntry{
+		ActorID:    userID,
+		Action:     auditActionPersonalAccessTokenCreated,
+		TargetType: "personal_access_token",
+		TargetID:   dbToken.ID.String(),
+		Metadata:   map[string]any{"name": dbToken.Name, "scopes": dbToken.Scopes},
+	})
+
+	// the token itself is only ever shown here
+	pat := personalAccessTokenToModel(dbToken)
+	pat.Token = token
+	respondWithJSON(w, http.StatusCreated, pat)
+}
+
+func (cfg *apiConfig) handlerPersonalAccessTokensList(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateUser(w, r)
+	if !ok {
+		return
+	}
+
+	dbTokens, err := cfg.dbQueries.ListPersonalAccessTokens(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch access tokens")
+		return
+	}
+
+	tokens := make([]model.PersonalAccessToken, len(dbTokens))
+	for i, token := range dbTokens {
+		tokens[i] = personalAccessTokenToModel(token)
+	}
+
+	respondWithJSON(w, http.StatusOK, tokens)
+}
+
+func (cfg *apiConfig) handlerPersonalAccessTokensRevoke(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateUser(w, r)
+	if !ok {
+		return
+	}
+
+	tokenID, err := uuid.Parse(r.PathValue("tokenID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid id format")
+		return
+	}
+
+	revoked, err := cfg.dbQueries.RevokePersonalAccessToken(r.Context(), database.RevokePersonalAccessTokenParams{
+		ID:     tokenID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to revoke access token")
+		return
+	}
+	if revoked == 0 {
+		respondWithError(w, http.StatusNotFound, "access token not found")
+		return
+	}
+
+	cfg.recordAudit(r, auditEntry{
+		ActorID:    userID,
+		Action:     auditActionPersonalAccessTokenRevoked,
+		TargetType: "personal_access_token",
+		TargetID:   tokenID.String(),
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/auth"
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/database"
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/model"
+	"github.com/google/uuid"
+)
+
+// pinnedChirpFirst moves the author's pinned chirp to the front of chirps,
+// whatever the order, and marks it as pinned.
+func (cfg *apiConfig) pinnedChirpFirst(ctx context.Context, authorID uuid.UUID, chirps []model.Chirp) error {
+	pinned, err := cfg.dbQueries.GetPinnedChirp(ctx, authorID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	movePinnedChirpFirst(chirps, pinned.ID)
+	return nil
+}
+
+// movePinnedChirpFirst moves the chirp with pinnedID, if it's in chirps, to
+// the front and marks it as pinned. The others keep their order.
+func movePinnedChirpFirst(chirps []model.Chirp, pinnedID uuid.UUID) {
+	for i, chirp := range chirps {
+		if chirp.ID == pinnedID {
+			chirp.Pinned = true
+			copy(chirps[1:i+1], chirps[:i])
+			chirps[0] = chirp
+			return
+		}
+	}
+}
+
+// handlerChirpPin pins one of the caller's chirps to their profile, in place
+// of the one pinned before.
+func (cfg *apiConfig) handlerChirpPin(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateScoped(w, r, auth.ScopeChirpsWrite)
+	if !ok {
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID format")
+		return
+	}
+
+	chirp, err := cfg.dbQueries.GetChirpByID(r.Context(), chirpID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusNotFound, "Chirp not found")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Failed to get chirp")
+		return
+	}
+	if chirp.UserID != userID {
+		respondWithError(w, http.StatusForbidden, "Only the owner of the chirp may pin it")
+		return
+	}
+
+	rows, err := cfg.dbQueries.PinChirp(r.Context(), database.PinChirpParams{
+		ChirpID: chirpID,
+		UserID:  userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to pin chirp")
+		return
+	}
+	if rows == 0 {
+		// deleted in the meantime
+		respondWithError(w, http.StatusNotFound, "Chirp not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerChirpUnpin(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateScoped(w, r, auth.ScopeChirpsWrite)
+	if !ok {
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID format")
+		return
+	}
+
+	rows, err := cfg.dbQueries.UnpinChirp(r.Context(), database.UnpinChirpParams{
+		UserID:  userID,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to unpin chirp")
+		return
+	}
+	if rows == 0 {
+		respondWithError(w, http.StatusNotFound, "Chirp is not pinned")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerProfileGet returns a user's public profile with their pinned chirp.
+func (cfg *apiConfig) handlerProfileGet(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID format")
+		return
+	}
+
+	user, err := cfg.dbQueries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Failed to get user")
+		return
+	}
+
+	profile := model.Profile{
+		ID:          user.ID,
+		CreatedAt:   user.CreatedAt,
+		IsChirpyRed: user.IsChirpyRed,
+	}
+
+	pinned, err := cfg.dbQueries.GetPinnedChirp(r.Context(), userID)
+	if err != nil && err != sql.ErrNoRows {
+		respondWithError(w, http.StatusInternalServerError, "Failed to get pinned chirp")
+		return
+	}
+	if err == nil {
+		chirps := []model.Chirp{chirpToModel(pinned)}
+		if err := cfg.attachPolls(r.Context(), uuid.Nil, chirps); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Failed to get pinned chirp")
+			return
+		}
+		chirps[0].Pinned = true
+		profile.PinnedChirp = &chirps[0]
+	}
+
+	respondWithJSON(w, http.StatusOK, profile)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/model"
+	"github.com/google/uuid"
+)
+
+func TestMovePinnedChirpFirst(t *testing.T) {
+	ids := []uuid.UUID{uuid.New(), uuid.New(), uuid.New(), uuid.New()}
+	chirps := func() []model.Chirp {
+		result := make([]model.Chirp, len(ids))
+		for i, id := range ids {
+			result[i] = model.Chirp{ID: id}
+		}
+		return result
+	}
+
+	tests := []struct {
+		name     string
+		pinnedID uuid.UUID
+		want     []uuid.UUID
+		pinned   bool
+	}{
+		{"already first", ids[0], []uuid.UUID{ids[0], ids[1], ids[2], ids[3]}, true},
+		{"from the middle", ids[2], []uuid.UUID{ids[2], ids[0], ids[1], ids[3]}, true},
+		{"from the end", ids[3], []uuid.UUID{ids[3], ids[0], ids[1], ids[2]}, true},
+		{"not on this page", uuid.New(), []uuid.UUID{ids[0], ids[1], ids[2], ids[3]}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := chirps()
+			movePinnedChirpFirst(got, tt.pinnedID)
+
+			for i, chirp := range got {
+				if chirp.ID != tt.want[i] {
+					t.Fatalf("chirp %d = %s, want %s", i, chirp.ID, tt.want[i])
+				}
+				if wantPinned := tt.pinned && i == 0; chirp.Pinned != wantPinned {
+					t.Errorf("chirp %d Pinned = %v, want %v", i, chirp.Pinned, wantPinned)
+				}
+			}
+		})
+	}
+
+	t.Run("empty", func(t *testing.T) {
+		movePinnedChirpFirst(nil, ids[0])
+	})
+}
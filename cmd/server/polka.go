@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/auth"
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/database"
+	"github.com/google/uuid"
+)
+
+const auditActionWebhookRejected = "webhook.rejected"
+
+const webhookProviderPolka = "polka"
+
+const (
+	polkaEventUserUpgraded         = "user.upgraded"
+	polkaEventUserDowngraded       = "user.downgraded"
+	polkaEventSubscriptionRenewed  = "subscription.renewed"
+	polkaEventSubscriptionCanceled = "subscription.canceled"
+	polkaEventPaymentFailed        = "subscription.payment_failed"
+)
+
+var polkaEventAuditActions = map[string]string{
+	polkaEventUserUpgraded:         auditActionChirpyRedUpgraded,
+	polkaEventUserDowngraded:       auditActionSubscriptionDowngraded,
+	polkaEventSubscriptionRenewed:  auditActionSubscriptionRenewed,
+	polkaEventSubscriptionCanceled: auditActionSubscriptionCanceled,
+	polkaEventPaymentFailed:        auditActionSubscriptionPaymentFailed,
+}
+
+// polkaEventSubscriptionStatus is the subscription status after each event,
+// for the user's notification
+var polkaEventSubscriptionStatus = map[string]string{
+	polkaEventUserUpgraded:         "active",
+	polkaEventUserDowngraded:       "expired",
+	polkaEventSubscriptionRenewed:  "active",
+	polkaEventSubscriptionCanceled: "canceled",
+	polkaEventPaymentFailed:        "past_due",
+}
+
+// polkaEvent is the body of a Polka delivery. ID is unique per event and
+// stays the same when Polka redelivers it. Plan and CurrentPeriodEnd are
+// optional, without an end Chirpy Red lasts until Polka takes it away.
+type polkaEvent struct {
+	ID    string `json:"id"`
+	Event string `json:"event"`
+	Data  struct {
+		UserID           string     `json:"user_id"`
+		Plan             string     `json:"plan"`
+		CurrentPeriodEnd *time.Time `json:"current_period_end"`
+	} `json:"data"`
+}
+
+// an event left pending for longer than this is presumed abandoned, for
+// example by a crash, and the next redelivery processes it again
+const webhookEventLease = 5 * time.Minute
+
+// deliveries are small JSON documents, anything bigger isn't from Polka
+const maxWebhookBodyBytes = 64 << 10
+
+// webhookReplayStore keeps the signatures of accepted deliveries in the
+// database so replays are caught across restarts and instances.
+type webhookReplayStore struct {
+	dbQueries *database.Queries
+}
+
+func (s webhookReplayStore) Remember(ctx context.Context, signature string, expiresAt time.Time) (bool, error) {
+	if err := s.dbQueries.DeleteExpiredWebhookSignatures(ctx); err != nil {
+		log.Printf("Error deleting expired webhook signatures: %s", err)
+	}
+
+	rows, err := s.dbQueries.RememberWebhookSignature(ctx, database.RememberWebhookSignatureParams{
+		Signature: signature,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return false, err
+	}
+	return rows == 1, nil
+}
+
+func (cfg *apiConfig) handlerPolkaWebhook(w http.ResponseWriter, r *http.Request) {
+
+	// the signature covers the raw bytes, so the body is read before decoding
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBodyBytes))
+	if err != nil {
+		respondWithError(w, http.StatusRequestEntityTooLarge, "Request body too large")
+		return
+	}
+
+	if err := cfg.webhookVerifier.Verify(r.Context(), r.Header, body); err != nil {
+		switch {
+		case errors.Is(err, auth.ErrWebhookSignatureMissing),
+			errors.Is(err, auth.ErrWebhookSignatureInvalid),
+			errors.Is(err, auth.ErrWebhookTimestampInvalid),
+			errors.Is(err, auth.ErrWebhookReplayed):
+			cfg.recordAudit(r, auditEntry{
+				Action:   auditActionWebhookRejected,
+				Metadata: map[string]any{"source": webhookProviderPolka, "reason": err.Error()},
+			})
+			respondWithError(w, http.StatusUnauthorized, "Invalid webhook signature")
+		default:
+			log.Printf("Error verifying webhook: %s", err)
+			respondWithError(w, http.StatusInternalServerError, "Failed to verify webhook")
+		}
+		return
+	}
+
+	var envelope polkaEvent
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if envelope.ID == "" {
+		respondWithError(w, http.StatusBadRequest, "Missing json:id")
+		return
+	}
+
+	event, err := cfg.dbQueries.CreateWebhookEvent(r.Context(), database.CreateWebhookEventParams{
+		Provider:   webhookProviderPolka,
+		EventID:    envelope.ID,
+		EventType:  envelope.Event,
+		Payload:    body,
+		LeaseUntil: time.Now().Add(webhookEventLease),
+	})
+	if err == sql.ErrNoRows {
+		// a redelivery, only events that failed or whose attempt died are
+		// processed again
+		event, err = cfg.dbQueries.ClaimWebhookEventRetry(r.Context(), database.ClaimWebhookEventRetryParams{
+			LeaseUntil: time.Now().Add(webhookEventLease),
+			Provider:   webhookProviderPolka,
+			EventID:    envelope.ID,
+		})
+	}
+	if err == sql.ErrNoRows {
+		event, err = cfg.dbQueries.GetWebhookEventByProviderID(r.Context(), database.GetWebhookEventByProviderIDParams{
+			Provider: webhookProviderPolka,
+			EventID:  envelope.ID,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Failed to get webhook event")
+			return
+		}
+		if event.Status == webhookEventPending {
+			// another attempt is still running, Polka retries later
+			respondWithError(w, http.StatusConflict, "Event is being processed")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to store webhook event")
+		return
+	}
+
+	applyErr := cfg.applyWebhookEvent(r, event)
+	if _, err := cfg.finishWebhookEvent(r, event, applyErr); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to update webhook event")
+		return
+	}
+	if applyErr != nil && !errors.Is(applyErr, errWebhookEventIgnored) {
+		respondWithWebhookError(w, applyErr)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// applyPolkaEvent acts on a stored Polka event. It's also used for replays,
+// so it must be safe to run more than once: periods start when the event was
+// received, not when it is applied.
+func (cfg *apiConfig) applyPolkaEvent(r *http.Request, event database.WebhookEvent) error {
+	var payload polkaEvent
+	if err := json.Unmarshal(event.Payload, &payload); err != nil {
+		return fmt.Errorf("%w: %w", errWebhookInvalidPayload, err)
+	}
+
+	auditAction, handled := polkaEventAuditActions[payload.Event]
+	if !handled {
+		return errWebhookEventIgnored
+	}
+
+	userID, err := uuid.Parse(payload.Data.UserID)
+	if err != nil {
+		return fmt.Errorf("%w: invalid data.user_id", errWebhookInvalidPayload)
+	}
+
+	ctx := r.Context()
+	switch payload.Event {
+	case polkaEventUserUpgraded, polkaEventSubscriptionRenewed:
+		plan := payload.Data.Plan
+		if plan == "" {
+			plan = subscriptionPlanChirpyRed
+		}
+		start := event.ReceivedAt
+		var end sql.NullTime
+		if payload.Data.CurrentPeriodEnd != nil {
+			if !payload.Data.CurrentPeriodEnd.After(start) {
+				return fmt.Errorf("%w: data.current_period_end is in the past", errWebhookInvalidPayload)
+			}
+			end = sql.NullTime{Time: *payload.Data.CurrentPeriodEnd, Valid: true}
+		}
+		err = cfg.activateSubscription(ctx, userID, plan, start, end)
+	case polkaEventPaymentFailed:
+		_, err = cfg.dbQueries.MarkSubscriptionPastDue(ctx, userID)
+	case polkaEventSubscriptionCanceled:
+		_, err = cfg.dbQueries.CancelSubscription(ctx, userID)
+	case polkaEventUserDowngraded:
+		err = cfg.endSubscription(ctx, userID)
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errWebhookSubscriptionNotFound
+		}
+		if errors.Is(err, errWebhookUserNotFound) || errors.Is(err, errWebhookSubscriptionNotFound) {
+			return err
+		}
+		return fmt.Errorf("applying %s: %w", payload.Event, err)
+	}
+
+	cfg.recordAudit(r, auditEntry{
+		Action:     auditAction,
+		TargetType: "user",
+		TargetID:   userID.String(),
+		Metadata:   map[string]any{"source": webhookProviderPolka, "event": payload.Event, "event_id": payload.ID},
+	})
+	cfg.notify(ctx, notification{
+		UserID: userID,
+		Type:   notificationTypeSubscription,
+		Data:   map[string]string{"event": payload.Event, "status": polkaEventSubscriptionStatus[payload.Event]},
+	})
+	return nil
+}
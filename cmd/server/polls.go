@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/auth"
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/database"
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/model"
+	"github.com/google/uuid"
+)
+
+// eventChirpPollVoted only carries the total, the tallies are hidden from
+// who hasn't voted yet
+const eventChirpPollVoted = "chirp.poll_voted"
+
+const (
+	minPollOptions      = 2
+	maxPollOptions      = 4
+	maxPollOptionLength = 25
+	minPollDuration     = 5 * time.Minute
+	maxPollDuration     = 7 * 24 * time.Hour
+)
+
+// pollParams is the poll of POST /api/chirps.
+type pollParams struct {
+	Options  []string  `json:"options"`
+	ClosesAt time.Time `json:"closes_at"`
+}
+
+func (p *pollParams) validate(now time.Time) error {
+	if len(p.Options) < minPollOptions || len(p.Options) > maxPollOptions {
+		return fmt.Errorf("polls must have between %d and %d options", minPollOptions, maxPollOptions)
+	}
+	seen := map[string]bool{}
+	for i, option := range p.Options {
+		option = strings.TrimSpace(option)
+		if option == "" {
+			return fmt.Errorf("poll options can't be empty")
+		}
+		if len(option) > maxPollOptionLength {
+			return fmt.Errorf("poll options can only be %d characters long", maxPollOptionLength)
+		}
+		if seen[strings.ToLower(option)] {
+			return fmt.Errorf("poll options must be different")
+		}
+		seen[strings.ToLower(option)] = true
+		p.Options[i] = cleanProfane(option)
+	}
+	if p.ClosesAt.Before(now.Add(minPollDuration)) || p.ClosesAt.After(now.Add(maxPollDuration)) {
+		return fmt.Errorf("closes_at must be between 5 minutes and 7 days from now")
+	}
+	return nil
+}
+
+// createPoll stores the poll of a chirp being created, with the queries of
+// its transaction.
+func createPoll(ctx context.Context, q *database.Queries, chirpID uuid.UUID, params pollParams) (database.Poll, []database.PollOption, error) {
+	poll, err := q.CreatePoll(ctx, database.CreatePollParams{
+		ChirpID:  chirpID,
+		ClosesAt: params.ClosesAt,
+	})
+	if err != nil {
+		return database.Poll{}, nil, err
+	}
+
+	options := make([]database.PollOption, len(params.Options))
+	for i, text := range params.Options {
+		err := q.CreatePollOption(ctx, database.CreatePollOptionParams{
+			PollID:      poll.ID,
+			OptionIndex: int32(i),
+			Text:        text,
+		})
+		if err != nil {
+			return database.Poll{}, nil, err
+		}
+		options[i] = database.PollOption{PollID: poll.ID, OptionIndex: int32(i), Text: text}
+	}
+	return poll, options, nil
+}
+
+// pollToModel hides the tallies unless showResults is set. votedOption is
+// nil when the viewer hasn't voted.
+func pollToModel(poll database.Poll, options []database.PollOption, votedOption *int32, showResults bool) model.Poll {
+	result := model.Poll{
+		ID:          poll.ID,
+		ClosesAt:    poll.ClosesAt,
+		Closed:      !time.Now().Before(poll.ClosesAt),
+		VotedOption: votedOption,
+		Options:     make([]model.PollOption, len(options)),
+	}
+	showResults = showResults || votedOption != nil || result.Closed
+	for i, option := range options {
+		result.TotalVotes += option.VoteCount
+		result.Options[i] = model.PollOption{Index: option.OptionIndex, Text: option.Text}
+		if showResults {
+			votes := option.VoteCount
+			result.Options[i].Votes = &votes
+		}
+	}
+	return result
+}
+
+// attachPolls sets the poll of the chirps that have one, as seen by viewerID.
+// Anonymous viewers pass uuid.Nil and only see the results of closed polls.
+func (cfg *apiConfig) attachPolls(ctx context.Context, viewerID uuid.UUID, chirps []model.Chirp) error {
+	if len(chirps) == 0 {
+		return nil
+	}
+
+	chirpIDs := make([]uuid.UUID, len(chirps))
+	for i, chirp := range chirps {
+		chirpIDs[i] = chirp.ID
+	}
+	polls, err := cfg.dbQueries.ListPollsForChirps(ctx, chirpIDs)
+	if err != nil || len(polls) == 0 {
+		return err
+	}
+
+	pollIDs := make([]uuid.UUID, len(polls))
+	for i, poll := range polls {
+		pollIDs[i] = poll.ID
+	}
+	options, err := cfg.dbQueries.ListPollOptions(ctx, pollIDs)
+	if err != nil {
+		return err
+	}
+	optionsByPoll := map[uuid.UUID][]database.PollOption{}
+	for _, option := range options {
+		optionsByPoll[option.PollID] = append(optionsByPoll[option.PollID], option)
+	}
+
+	votedOptions := map[uuid.UUID]*int32{}
+	if viewerID != uuid.Nil {
+		votes, err := cfg.dbQueries.ListPollVotesByUser(ctx, database.ListPollVotesByUserParams{
+			UserID:  viewerID,
+			PollIds: pollIDs,
+		})
+		if err != nil {
+			return err
+		}
+		for _, vote := range votes {
+			votedOptions[vote.PollID] = &vote.OptionIndex
+		}
+	}
+
+	pollsByChirp := map[uuid.UUID]database.Poll{}
+	for _, poll := range polls {
+		pollsByChirp[poll.ChirpID] = poll
+	}
+	for i, chirp := range chirps {
+		poll, ok := pollsByChirp[chirp.ID]
+		if !ok {
+			continue
+		}
+		p := pollToModel(poll, optionsByPoll[poll.ID], votedOptions[poll.ID], viewerID == chirp.UserID)
+		chirps[i].Poll = &p
+	}
+	return nil
+}
+
+// getChirpPoll loads the chirp in the path and its poll, as seen by userID.
+func (cfg *apiConfig) getChirpPoll(w http.ResponseWriter, r *http.Request, userID uuid.UUID) (model.Chirp, bool) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID format")
+		return model.Chirp{}, false
+	}
+
+	dbChirp, err := cfg.dbQueries.GetChirpByID(r.Context(), chirpID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusNotFound, "Chirp not found")
+			return model.Chirp{}, false
+		}
+		respondWithError(w, http.StatusInternalServerError, "Failed to get chirp")
+		return model.Chirp{}, false
+	}
+
+	chirps := []model.Chirp{chirpToModel(dbChirp)}
+	if err := cfg.attachPolls(r.Context(), userID, chirps); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to get poll")
+		return model.Chirp{}, false
+	}
+	if chirps[0].Poll == nil {
+		respondWithError(w, http.StatusNotFound, "Chirp has no poll")
+		return model.Chirp{}, false
+	}
+	return chirps[0], true
+}
+
+func (cfg *apiConfig) handlerPollGet(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateScoped(w, r, auth.ScopeChirpsRead)
+	if !ok {
+		return
+	}
+
+	chirp, ok := cfg.getChirpPoll(w, r, userID)
+	if !ok {
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, chirp.Poll)
+}
+
+// handlerPollVote records the caller's single vote and returns the poll with
+// its results.
+func (cfg *apiConfig) handlerPollVote(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateScoped(w, r, auth.ScopeChirpsWrite)
+	if !ok {
+		return
+	}
+
+	var params struct {
+		Option *int32 `json:"option"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil || params.Option == nil {
+		respondWithError(w, http.StatusBadRequest, "option is required")
+		return
+	}
+
+	chirp, ok := cfg.getChirpPoll(w, r, userID)
+	if !ok {
+		return
+	}
+	poll := chirp.Poll
+	if *params.Option < 0 || int(*params.Option) >= len(poll.Options) {
+		respondWithError(w, http.StatusBadRequest, "Invalid option")
+		return
+	}
+	if poll.VotedOption != nil {
+		respondWithError(w, http.StatusConflict, "You already voted in this poll")
+		return
+	}
+	if poll.Closed {
+		respondWithError(w, http.StatusConflict, "The poll is closed")
+		return
+	}
+
+	rows, err := cfg.dbQueries.CastPollVote(r.Context(), database.CastPollVoteParams{
+		UserID:      userID,
+		OptionIndex: *params.Option,
+		PollID:      poll.ID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to vote")
+		return
+	}
+
+	// reload either way, nothing was counted when the poll closed or another
+	// request of the same user voted first
+	chirp, ok = cfg.getChirpPoll(w, r, userID)
+	if !ok {
+		return
+	}
+	if rows == 0 {
+		if chirp.Poll.VotedOption != nil {
+			respondWithError(w, http.StatusConflict, "You already voted in this poll")
+			return
+		}
+		respondWithError(w, http.StatusConflict, "The poll is closed")
+		return
+	}
+
+	cfg.publishEvent(eventChirpPollVoted, chirp.UserID, chirp.ID, map[string]any{
+		"chirp_id":    chirp.ID,
+		"poll_id":     chirp.Poll.ID,
+		"total_votes": chirp.Poll.TotalVotes,
+	})
+	respondWithJSON(w, http.StatusOK, chirp.Poll)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPollParamsValidate(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	day := now.Add(24 * time.Hour)
+
+	tests := []struct {
+		name        string
+		params      pollParams
+		wantOptions []string
+		wantErr     bool
+	}{
+		{"valid", pollParams{Options: []string{"yes", "no"}, ClosesAt: day}, []string{"yes", "no"}, false},
+		{"trimmed and cleaned", pollParams{Options: []string{" tea ", "kerfuffle"}, ClosesAt: day}, []string{"tea", "****"}, false},
+		{"four options", pollParams{Options: []string{"a", "b", "c", "d"}, ClosesAt: day}, []string{"a", "b", "c", "d"}, false},
+		{"one option", pollParams{Options: []string{"yes"}, ClosesAt: day}, nil, true},
+		{"five options", pollParams{Options: []string{"a", "b", "c", "d", "e"}, ClosesAt: day}, nil, true},
+		{"empty option", pollParams{Options: []string{"yes", "  "}, ClosesAt: day}, nil, true},
+		{"option too long", pollParams{Options: []string{"yes", "this option is far too long"}, ClosesAt: day}, nil, true},
+		{"duplicate options", pollParams{Options: []string{"Yes", "yes"}, ClosesAt: day}, nil, true},
+		{"closes too soon", pollParams{Options: []string{"yes", "no"}, ClosesAt: now.Add(time.Minute)}, nil, true},
+		{"closes too late", pollParams{Options: []string{"yes", "no"}, ClosesAt: now.Add(8 * 24 * time.Hour)}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.params.validate(now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(tt.params.Options, tt.wantOptions) {
+				t.Errorf("validate() options = %v, want %v", tt.params.Options, tt.wantOptions)
+			}
+		})
+	}
+}
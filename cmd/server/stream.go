@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/auth"
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/events"
+	"github.com/google/uuid"
+)
+
+const (
+	streamEventReset = "reset"
+
+	// events kept in memory for clients resuming with Last-Event-ID
+	streamHistorySize = 1000
+	// events buffered per client before it is dropped and has to resume
+	streamClientBuffer = 64
+	// comments keep proxies from closing idle streams
+	streamHeartbeatInterval = 15 * time.Second
+)
+
+// chirpStreamEvents are the events GET /api/stream sends
+var chirpStreamEvents = map[string]bool{
+	eventChirpCreated:   true,
+	eventChirpDeleted:   true,
+	eventChirpPollVoted: true,
+}
+
+// handlerStream sends chirp events as Server-Sent Events. author_id (comma
+// separated) limits them to some authors and timeline=true, which needs
+// authentication, to the caller's timeline. A client reconnecting with
+// Last-Event-ID first gets what it missed, or a reset event when that is no
+// longer known and it should load GET /api/chirps again.
+func (cfg *apiConfig) handlerStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		respondWithError(w, http.StatusInternalServerError, "Streaming not supported")
+		return
+	}
+
+	query := r.URL.Query()
+	authors := map[uuid.UUID]bool{}
+	if authorQuery := query.Get("author_id"); authorQuery != "" {
+		for _, author := range strings.Split(authorQuery, ",") {
+			authorID, err := uuid.Parse(strings.TrimSpace(author))
+			if err != nil {
+				respondWithError(w, http.StatusBadRequest, "Invalid author_id format")
+				return
+			}
+			authors[authorID] = true
+		}
+	}
+
+	switch query.Get("timeline") {
+	case "", "false":
+	case "true":
+		if len(authors) > 0 {
+			respondWithError(w, http.StatusBadRequest, "Use either author_id or timeline")
+			return
+		}
+		userID, ok := cfg.authenticateScoped(w, r, auth.ScopeChirpsRead)
+		if !ok {
+			return
+		}
+		// there are no follows yet, the timeline is the caller's own chirps
+		authors[userID] = true
+	default:
+		respondWithError(w, http.StatusBadRequest, "timeline must be true or false")
+		return
+	}
+
+	// EventSource sends the header when it reconnects, the query parameter is
+	// for the first connection of a client that kept the id
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = query.Get("last_event_id")
+	}
+	var last *events.ID
+	if lastEventID != "" {
+		id, err := events.ParseID(lastEventID)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid Last-Event-ID")
+			return
+		}
+		last = &id
+	}
+
+	filter := func(event events.Event) bool {
+		if !chirpStreamEvents[event.Type] {
+			return false
+		}
+		return len(authors) == 0 || authors[event.UserID]
+	}
+	sub, missed, complete := cfg.eventBus.Subscribe(filter, streamClientBuffer, last)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if !complete {
+		fmt.Fprintf(w, "event: %s\ndata: {}\n\n", streamEventReset)
+	}
+	for _, event := range missed {
+		writeStreamEvent(w, event)
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		case event, ok := <-sub.C():
+			if !ok {
+				// dropped for falling behind, the client resumes from its last id
+				return
+			}
+			writeStreamEvent(w, event)
+			flusher.Flush()
+		}
+	}
+}
+
+// publishEvent sends an event to the real-time streams. Failures are only
+// logged, the action already happened.
+func (cfg *apiConfig) publishEvent(eventType string, userID, resourceID uuid.UUID, data any) {
+	if _, err := cfg.eventBus.Publish(eventType, userID, resourceID, data); err != nil {
+		log.Printf("Error publishing %s event: %s", eventType, err)
+	}
+}
+
+func writeStreamEvent(w http.ResponseWriter, event events.Event) {
+	fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, event.Data)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/database"
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/model"
+	"github.com/google/uuid"
+)
+
+const subscriptionPlanChirpyRed = "chirpy_red"
+
+const (
+	auditActionSubscriptionRenewed       = "subscription.renewed"
+	auditActionSubscriptionPaymentFailed = "subscription.payment_failed"
+	auditActionSubscriptionCanceled      = "subscription.canceled"
+	auditActionSubscriptionDowngraded    = "subscription.downgraded"
+	auditActionSubscriptionExpired       = "subscription.expired"
+)
+
+var errWebhookSubscriptionNotFound = errors.New("subscription not found")
+
+// activateSubscription starts or renews userID's subscription for the given
+// period and turns Chirpy Red on. Without end the subscription is open-ended
+// and lasts until Polka downgrades the user or sends a period end.
+func (cfg *apiConfig) activateSubscription(ctx context.Context, userID uuid.UUID, plan string, start time.Time, end sql.NullTime) error {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	_, err = qtx.SetUserChirpyRed(ctx, database.SetUserChirpyRedParams{ID: userID, IsChirpyRed: true})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errWebhookUserNotFound
+		}
+		return err
+	}
+
+	_, err = qtx.ActivateSubscription(ctx, database.ActivateSubscriptionParams{
+		UserID:             userID,
+		Plan:               plan,
+		CurrentPeriodStart: start,
+		CurrentPeriodEnd:   end,
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// endSubscription expires userID's subscription right away and turns Chirpy
+// Red off, unlike a cancellation which runs until the end of the period.
+func (cfg *apiConfig) endSubscription(ctx context.Context, userID uuid.UUID) error {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	_, err = qtx.EndSubscription(ctx, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errWebhookSubscriptionNotFound
+		}
+		return err
+	}
+
+	_, err = qtx.SetUserChirpyRed(ctx, database.SetUserChirpyRedParams{ID: userID, IsChirpyRed: false})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// runSubscriptionExpiry expires subscriptions whose period is over every
+// interval. Cancelled and past due subscriptions keep Chirpy Red until then.
+func (cfg *apiConfig) runSubscriptionExpiry(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		cfg.expireSubscriptions(context.Background())
+		<-ticker.C
+	}
+}
+
+func (cfg *apiConfig) expireSubscriptions(ctx context.Context) {
+	userIDs, err := cfg.dbQueries.ExpireSubscriptions(ctx)
+	if err != nil {
+		log.Printf("Error expiring subscriptions: %s", err)
+		return
+	}
+
+	for _, userID := range userIDs {
+		cfg.recordSystemAudit(ctx, auditEntry{
+			Action:     auditActionSubscriptionExpired,
+			TargetType: "user",
+			TargetID:   userID.String(),
+		})
+		cfg.notify(ctx, notification{
+			UserID: userID,
+			Type:   notificationTypeSubscription,
+			Data:   map[string]string{"event": "subscription.expired", "status": "expired"},
+		})
+	}
+}
+
+func (cfg *apiConfig) handlerSubscriptionGet(w http.ResponseWriter, r *http.Request) {
+	userID, ok := cfg.authenticateUser(w, r)
+	if !ok {
+		return
+	}
+
+	subscription, err := cfg.dbQueries.GetSubscriptionByUserID(r.Context(), userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusNotFound, "Subscription not found")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Failed to get subscription")
+		return
+	}
+
+	response := model.Subscription{
+		Plan:               subscription.Plan,
+		Status:             subscription.Status,
+		CurrentPeriodStart: subscription.CurrentPeriodStart,
+		CreatedAt:          subscription.CreatedAt,
+	}
+	if subscription.CurrentPeriodEnd.Valid {
+		currentPeriodEnd := subscription.CurrentPeriodEnd.Time
+		response.CurrentPeriodEnd = &currentPeriodEnd
+	}
+	if subscription.CanceledAt.Valid {
+		canceledAt := subscription.CanceledAt.Time
+		response.CanceledAt = &canceledAt
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
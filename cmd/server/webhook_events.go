@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/database"
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/model"
+	"github.com/google/uuid"
+)
+
+const (
+	webhookEventPending   = "pending"
+	webhookEventProcessed = "processed"
+	webhookEventIgnored   = "ignored"
+	webhookEventFailed    = "failed"
+)
+
+const auditActionWebhookReplayed = "webhook.replayed"
+
+const (
+	defaultWebhookEventLimit = 100
+	maxWebhookEventLimit     = 1000
+)
+
+var (
+	// errWebhookEventIgnored is returned for event types nothing acts on
+	errWebhookEventIgnored   = errors.New("event type not handled")
+	errWebhookInvalidPayload = errors.New("invalid webhook payload")
+	errWebhookUserNotFound   = errors.New("user not found")
+)
+
+// applyWebhookEvent runs the handler of the event's provider.
+func (cfg *apiConfig) applyWebhookEvent(r *http.Request, event database.WebhookEvent) error {
+	switch event.Provider {
+	case webhookProviderPolka:
+		return cfg.applyPolkaEvent(r, event)
+	default:
+		return fmt.Errorf("unknown webhook provider %q", event.Provider)
+	}
+}
+
+// finishWebhookEvent records the outcome of applying event. The returned error
+// is only about storing it, applyErr ends up in the event's last_error.
+func (cfg *apiConfig) finishWebhookEvent(r *http.Request, event database.WebhookEvent, applyErr error) (database.WebhookEvent, error) {
+	params := database.FinishWebhookEventParams{ID: event.ID, Status: webhookEventProcessed}
+	switch {
+	case errors.Is(applyErr, errWebhookEventIgnored):
+		params.Status = webhookEventIgnored
+	case applyErr != nil:
+		params.Status = webhookEventFailed
+		params.LastError = applyErr.Error()
+	}
+
+	// the outcome has to be stored even if the sender stopped waiting
+	return cfg.dbQueries.FinishWebhookEvent(context.WithoutCancel(r.Context()), params)
+}
+
+// respondWithWebhookError answers a failed delivery so the provider retries
+// it, except for payloads that will never succeed.
+func respondWithWebhookError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errWebhookInvalidPayload):
+		respondWithError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, errWebhookUserNotFound):
+		respondWithError(w, http.StatusNotFound, "User not found")
+	case errors.Is(err, errWebhookSubscriptionNotFound):
+		respondWithError(w, http.StatusNotFound, "Subscription not found")
+	default:
+		log.Printf("Error processing webhook: %s", err)
+		respondWithError(w, http.StatusInternalServerError, "Failed to process webhook")
+	}
+}
+
+func webhookEventToModel(event database.WebhookEvent) model.WebhookEvent {
+	webhookEvent := model.WebhookEvent{
+		ID:         event.ID,
+		Provider:   event.Provider,
+		EventID:    event.EventID,
+		EventType:  event.EventType,
+		Payload:    event.Payload,
+		Status:     event.Status,
+		Attempts:   event.Attempts,
+		LastError:  event.LastError,
+		ReceivedAt: event.ReceivedAt,
+	}
+	if event.ProcessedAt.Valid {
+		processedAt := event.ProcessedAt.Time
+		webhookEvent.ProcessedAt = &processedAt
+	}
+	return webhookEvent
+}
+
+func (cfg *apiConfig) handlerWebhookEventsList(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.requireAdmin(w, r); !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	params := database.ListWebhookEventsParams{Limit: defaultWebhookEventLimit}
+
+	if provider := query.Get("provider"); provider != "" {
+		params.Provider.String, params.Provider.Valid = provider, true
+	}
+	if status := query.Get("status"); status != "" {
+		switch status {
+		case webhookEventPending, webhookEventProcessed, webhookEventIgnored, webhookEventFailed:
+		default:
+			respondWithError(w, http.StatusBadRequest, "status must be pending, processed, ignored or failed")
+			return
+		}
+		params.Status.String, params.Status.Valid = status, true
+	}
+	if eventType := query.Get("event_type"); eventType != "" {
+		params.EventType.String, params.EventType.Valid = eventType, true
+	}
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxWebhookEventLimit {
+			respondWithError(w, http.StatusBadRequest, "limit must be between 1 and 1000")
+			return
+		}
+		params.Limit = int32(n)
+	}
+
+	dbEvents, err := cfg.dbQueries.ListWebhookEvents(r.Context(), params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to fetch webhook events")
+		return
+	}
+
+	events := make([]model.WebhookEvent, len(dbEvents))
+	for i, event := range dbEvents {
+		events[i] = webhookEventToModel(event)
+	}
+
+	respondWithJSON(w, http.StatusOK, events)
+}
+
+// getWebhookEventFromPath loads the event named by the eventID path value,
+// answering the request itself when that fails.
+func (cfg *apiConfig) getWebhookEventFromPath(w http.ResponseWriter, r *http.Request) (database.WebhookEvent, bool) {
+	eventID, err := uuid.Parse(r.PathValue("eventID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid event ID format")
+		return database.WebhookEvent{}, false
+	}
+
+	event, err := cfg.dbQueries.GetWebhookEvent(r.Context(), eventID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, http.StatusNotFound, "Webhook event not found")
+			return database.WebhookEvent{}, false
+		}
+		respondWithError(w, http.StatusInternalServerError, "Failed to get webhook event")
+		return database.WebhookEvent{}, false
+	}
+	return event, true
+}
+
+func (cfg *apiConfig) handlerWebhookEventGet(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.requireAdmin(w, r); !ok {
+		return
+	}
+
+	event, ok := cfg.getWebhookEventFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, webhookEventToModel(event))
+}
+
+// handlerWebhookEventReplay applies a stored event again. Processed events
+// are refused: subscription events act on their order, so applying an old
+// one after a later event would undo it.
+func (cfg *apiConfig) handlerWebhookEventReplay(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := cfg.requireAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	event, ok := cfg.getWebhookEventFromPath(w, r)
+	if !ok {
+		return
+	}
+	if event.Status == webhookEventProcessed {
+		respondWithError(w, http.StatusConflict, "Event was already processed")
+		return
+	}
+
+	// the replay takes the event's lease like a redelivery does, so it never
+	// runs alongside another attempt
+	event, err := cfg.dbQueries.ClaimWebhookEventRetry(r.Context(), database.ClaimWebhookEventRetryParams{
+		LeaseUntil:     time.Now().Add(webhookEventLease),
+		Provider:       event.Provider,
+		EventID:        event.EventID,
+		IncludeIgnored: true,
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, http.StatusConflict, "Event is being processed or was already processed")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to claim webhook event")
+		return
+	}
+
+	applyErr := cfg.applyWebhookEvent(r, event)
+	finished, err := cfg.finishWebhookEvent(r, event, applyErr)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to update webhook event")
+		return
+	}
+
+	cfg.recordAudit(r, auditEntry{
+		ActorID:    adminID,
+		Action:     auditActionWebhookReplayed,
+		TargetType: "webhook_event",
+		TargetID:   event.ID.String(),
+		Metadata:   map[string]any{"provider": event.Provider, "event_id": event.EventID, "status": finished.Status},
+	})
+	respondWithJSON(w, http.StatusOK, webhookEventToModel(finished))
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/auth"
+	"github.com/PedroMartini98/Twitter-Clone.go.git/internal/events"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	wsChannelTimeline      = "timeline"
+	wsChannelMentions      = "mentions"
+	wsChannelNotifications = "notifications"
+	wsChannelThreadPrefix  = "thread:"
+
+	wsMaxChannels    = 20
+	wsMaxMessageSize = 4096
+
+	// time allowed to write a message before the client counts as gone
+	wsWriteWait = 10 * time.Second
+	// the client has to answer pings, or send anything, within wsPongWait
+	wsPongWait     = 60 * time.Second
+	wsPingInterval = 30 * time.Second
+	// token_expiring is sent this long before the token expires
+	wsExpiryWarning = time.Minute
+)
+
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// the connection is authenticated with a token, not cookies, so a page on
+	// another origin can't open it with a visitor's session
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+var (
+	errWSUnknownChannel  = errors.New("unknown channel")
+	errWSTooManyChannels = errors.New("too many channels")
+)
+
+// wsClientMessage is what clients send: subscribe and unsubscribe with a
+// channel, ping, and auth with a new token before the current one expires.
+type wsClientMessage struct {
+	Type    string `json:"type"`
+	Channel string `json:"channel,omitempty"`
+	Token   string `json:"token,omitempty"`
+}
+
+type wsServerMessage struct {
+	Type      string          `json:"type"`
+	Channel   string          `json:"channel,omitempty"`
+	Channels  []string        `json:"channels,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Event     string          `json:"event,omitempty"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	Error     string          `json:"error,omitempty"`
+	ExpiresAt *time.Time      `json:"expires_at,omitempty"`
+}
+
+// wsChannels is the set of channels a connection is subscribed to. It is read
+// by the bus filter while the connection changes it.
+type wsChannels struct {
+	mu       sync.Mutex
+	userID   uuid.UUID
+	channels map[string]bool
+	threads  map[uuid.UUID]bool
+}
+
+func (c *wsChannels) subscribe(channel string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var threadID uuid.UUID
+	switch {
+	case channel == wsChannelTimeline, channel == wsChannelMentions, channel == wsChannelNotifications:
+	case strings.HasPrefix(channel, wsChannelThreadPrefix):
+		id, err := uuid.Parse(strings.TrimPrefix(channel, wsChannelThreadPrefix))
+		if err != nil {
+			return errWSUnknownChannel
+		}
+		threadID = id
+	default:
+		return errWSUnknownChannel
+	}
+
+	if c.channels[channel] {
+		return nil
+	}
+	if len(c.channels) >= wsMaxChannels {
+		return errWSTooManyChannels
+	}
+	c.channels[channel] = true
+	if threadID != uuid.Nil {
+		c.threads[threadID] = true
+	}
+	return nil
+}
+
+func (c *wsChannels) unsubscribe(channel string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.channels[channel] {
+		return
+	}
+	delete(c.channels, channel)
+	if strings.HasPrefix(channel, wsChannelThreadPrefix) {
+		delete(c.threads, uuid.MustParse(strings.TrimPrefix(channel, wsChannelThreadPrefix)))
+	}
+}
+
+// match returns the subscribed channels event belongs to. The timeline is the
+// user's own chirps until there are follows, a thread is one chirp.
+func (c *wsChannels) match(event events.Event) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var matched []string
+	if chirpStreamEvents[event.Type] {
+		if c.channels[wsChannelTimeline] && event.UserID == c.userID {
+			matched = append(matched, wsChannelTimeline)
+		}
+		if c.threads[event.ResourceID] {
+			matched = append(matched, wsChannelThreadPrefix+event.ResourceID.String())
+		}
+	}
+	if event.Type == eventChirpMentioned && c.channels[wsChannelMentions] && event.UserID == c.userID {
+		matched = append(matched, wsChannelMentions)
+	}
+	if event.Type == eventNotificationCreated && c.channels[wsChannelNotifications] && event.UserID == c.userID {
+		matched = append(matched, wsChannelNotifications)
+	}
+	return matched
+}
+
+// handlerWebSocket upgrades to a WebSocket that sends the events of the
+// channels the client subscribes to. It is authenticated with an access token,
+// in the Authorization header or, for browsers that can't set it, the
+// access_token query parameter, and closes when the token expires unless the
+// client sends a new one.
+func (cfg *apiConfig) handlerWebSocket(w http.ResponseWriter, r *http.Request) {
+	tokenString, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		tokenString = r.URL.Query().Get("access_token")
+	}
+	if tokenString == "" {
+		respondWithError(w, http.StatusUnauthorized, "Missing or invalid Authorization header")
+		return
+	}
+	userID, expiresAt, err := cfg.keyRing.ValidateJWTExpiry(tokenString)
+	if err != nil {
+		respondWithTokenError(w, err)
+		return
+	}
+
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader already responded
+		return
+	}
+	defer conn.Close()
+
+	channels := &wsChannels{userID: userID, channels: map[string]bool{}, threads: map[uuid.UUID]bool{}}
+	sub, _, _ := cfg.eventBus.Subscribe(func(event events.Event) bool {
+		return len(channels.match(event)) > 0
+	}, streamClientBuffer, nil)
+	defer sub.Close()
+
+	incoming := make(chan wsClientMessage)
+	readDone := make(chan struct{})
+	stop := make(chan struct{})
+	defer close(stop)
+	go readWebSocket(conn, incoming, readDone, stop)
+
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+	warning := time.NewTimer(time.Until(expiresAt.Add(-wsExpiryWarning)))
+	defer warning.Stop()
+	expiry := time.NewTimer(time.Until(expiresAt))
+	defer expiry.Stop()
+
+	for {
+		select {
+		case <-readDone:
+			return
+
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+				return
+			}
+
+		case <-warning.C:
+			if err := writeWebSocket(conn, wsServerMessage{Type: "token_expiring", ExpiresAt: &expiresAt}); err != nil {
+				return
+			}
+
+		case <-expiry.C:
+			writeWebSocket(conn, wsServerMessage{Type: "error", Error: "Token expired"})
+			closeWebSocket(conn, readDone, websocket.ClosePolicyViolation, "token expired")
+			return
+
+		case event, ok := <-sub.C():
+			if !ok {
+				// dropped by the bus for falling behind
+				writeWebSocket(conn, wsServerMessage{Type: "error", Error: "Connection too slow, reconnect and reload"})
+				closeWebSocket(conn, readDone, websocket.CloseTryAgainLater, "slow consumer")
+				return
+			}
+			matched := channels.match(event)
+			if len(matched) == 0 {
+				continue
+			}
+			err := writeWebSocket(conn, wsServerMessage{
+				Type:     "event",
+				Channels: matched,
+				ID:       event.ID.String(),
+				Event:    event.Type,
+				Data:     event.Data,
+			})
+			if err != nil {
+				return
+			}
+
+		case msg := <-incoming:
+			var reply wsServerMessage
+			switch msg.Type {
+			case "subscribe":
+				if err := channels.subscribe(msg.Channel); err != nil {
+					reply = wsServerMessage{Type: "error", Channel: msg.Channel, Error: err.Error()}
+				} else {
+					reply = wsServerMessage{Type: "subscribed", Channel: msg.Channel}
+				}
+			case "unsubscribe":
+				channels.unsubscribe(msg.Channel)
+				reply = wsServerMessage{Type: "unsubscribed", Channel: msg.Channel}
+			case "ping":
+				reply = wsServerMessage{Type: "pong"}
+			case "auth":
+				newUserID, newExpiresAt, err := cfg.keyRing.ValidateJWTExpiry(msg.Token)
+				if err != nil || newUserID != userID {
+					reply = wsServerMessage{Type: "error", Error: "Invalid token"}
+					break
+				}
+				if newExpiresAt.After(expiresAt) {
+					expiresAt = newExpiresAt
+					warning.Reset(time.Until(expiresAt.Add(-wsExpiryWarning)))
+					expiry.Reset(time.Until(expiresAt))
+				}
+				reply = wsServerMessage{Type: "authenticated", ExpiresAt: &expiresAt}
+			default:
+				reply = wsServerMessage{Type: "error", Error: "Unknown message type"}
+			}
+			if err := writeWebSocket(conn, reply); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// readWebSocket passes the client's messages to incoming until the connection
+// fails or closes, or stop is closed, then closes done. Every message, pongs
+// included, extends the read deadline.
+func readWebSocket(conn *websocket.Conn, incoming chan<- wsClientMessage, done, stop chan struct{}) {
+	defer close(done)
+
+	conn.SetReadLimit(wsMaxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		conn.SetReadDeadline(time.Now().Add(wsPongWait))
+
+		var msg wsClientMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			msg = wsClientMessage{}
+		}
+		select {
+		case incoming <- msg:
+		case <-stop:
+			return
+		}
+	}
+}
+
+func writeWebSocket(conn *websocket.Conn, msg wsServerMessage) error {
+	conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	return conn.WriteJSON(msg)
+}
+
+// closeWebSocket sends a close frame and waits for the client to answer it,
+// or for wsWriteWait, before the connection is dropped.
+func closeWebSocket(conn *websocket.Conn, readDone <-chan struct{}, code int, reason string) {
+	err := conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(wsWriteWait))
+	if err != nil {
+		return
+	}
+	select {
+	case <-readDone:
+	case <-time.After(wsWriteWait):
+	}
+}
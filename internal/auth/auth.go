@@ -2,39 +2,47 @@ package auth
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 )
 
-func HashPassword(password string) (string, error) {
-	hashedBytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
-		return "", err
-	}
-	return string(hashedBytes), nil
-}
+const (
+	TokenIssuer   = "chirpy"
+	TokenAudience = "chirpy-api"
 
-func CheckPasswordHash(hashedPassword, password string) error {
-	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
-}
+	// TokenTypeAccess is the token_type claim of the JWTs that authorize API
+	// calls. Any other kind of JWT gets its own type so it can never be used
+	// in place of an access token.
+	TokenTypeAccess = "access"
 
-func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
+	// DefaultLeeway is the clock skew tolerated when checking exp, nbf and iat.
+	DefaultLeeway = 30 * time.Second
+)
 
-	claims := jwt.MapClaims{
-		"sub": userID.String(),
-		"exp": time.Now().Add(expiresIn).Unix(),
-		"iat": time.Now().Unix(),
-		"iss": "chirpy",
-	}
+// Errors returned by ValidateJWT and KeyRing.ValidateJWT. The original
+// error from the jwt package is wrapped along with them.
+var (
+	ErrTokenMalformed        = errors.New("token is malformed")
+	ErrTokenExpired          = errors.New("token is expired")
+	ErrTokenNotValidYet      = errors.New("token is not valid yet")
+	ErrTokenInvalidSignature = errors.New("token signature is invalid")
+	ErrTokenInvalidIssuer    = errors.New("token has invalid issuer")
+	ErrTokenInvalidAudience  = errors.New("token has invalid audience")
+	ErrTokenInvalidType      = errors.New("token has invalid type")
+	ErrTokenInvalidClaims    = errors.New("token has invalid claims")
+)
 
-	newToken := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
+
+	newToken := jwt.NewWithClaims(jwt.SigningMethodHS256, newClaims(userID, TokenTypeAccess, expiresIn))
 
 	tokenString, err := newToken.SignedString([]byte(tokenSecret))
 	if err != nil {
@@ -46,37 +54,78 @@ func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (str
 }
 
 func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &jwt.MapClaims{}, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, jwt.ErrSignatureInvalid
-		}
+	userID, _, err := parseToken(tokenString, TokenTypeAccess, DefaultLeeway, []string{AlgorithmHS256}, func(t *jwt.Token) (interface{}, error) {
 		return []byte(tokenSecret), nil
 	})
-	if err != nil {
-		return uuid.Nil, err
+	return userID, err
+}
+
+func newClaims(userID uuid.UUID, tokenType string, expiresIn time.Duration) jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"sub":        userID.String(),
+		"exp":        now.Add(expiresIn).Unix(),
+		"iat":        now.Unix(),
+		"nbf":        now.Unix(),
+		"iss":        TokenIssuer,
+		"aud":        TokenAudience,
+		"token_type": tokenType,
 	}
+}
 
-	if !token.Valid {
-		return uuid.Nil, jwt.ErrTokenNotValidYet
+// parseToken checks the signature of tokenString with one of algorithms and
+// then every claim, returning the subject and the claims. exp is required.
+func parseToken(tokenString, tokenType string, leeway time.Duration, algorithms []string, keyFunc jwt.Keyfunc) (uuid.UUID, jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, keyFunc,
+		jwt.WithValidMethods(algorithms),
+		jwt.WithIssuer(TokenIssuer),
+		jwt.WithAudience(TokenAudience),
+		jwt.WithLeeway(leeway),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil {
+		return uuid.Nil, nil, classifyTokenError(err)
 	}
 
-	claims, ok := token.Claims.(*jwt.MapClaims)
-	if !ok {
-		return uuid.Nil, jwt.ErrTokenInvalidClaims
+	if typ, _ := claims["token_type"].(string); typ != tokenType {
+		return uuid.Nil, nil, ErrTokenInvalidType
 	}
 
-	sub, ok := (*claims)["sub"].(string)
+	sub, ok := claims["sub"].(string)
 	if !ok {
-		return uuid.Nil, jwt.ErrTokenInvalidClaims
+		return uuid.Nil, nil, ErrTokenInvalidClaims
 	}
 
 	userID, err := uuid.Parse(sub)
 	if err != nil {
-		return uuid.Nil, jwt.ErrTokenInvalidClaims
+		return uuid.Nil, nil, ErrTokenInvalidClaims
 	}
 
-	return userID, nil
+	return userID, claims, nil
+
+}
 
+func classifyTokenError(err error) error {
+	var sentinel error
+	switch {
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		sentinel = ErrTokenMalformed
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid), errors.Is(err, jwt.ErrTokenUnverifiable):
+		sentinel = ErrTokenInvalidSignature
+	case errors.Is(err, jwt.ErrTokenExpired):
+		sentinel = ErrTokenExpired
+	case errors.Is(err, jwt.ErrTokenNotValidYet), errors.Is(err, jwt.ErrTokenUsedBeforeIssued):
+		sentinel = ErrTokenNotValidYet
+	case errors.Is(err, jwt.ErrTokenInvalidIssuer):
+		sentinel = ErrTokenInvalidIssuer
+	case errors.Is(err, jwt.ErrTokenInvalidAudience):
+		sentinel = ErrTokenInvalidAudience
+	default:
+		sentinel = ErrTokenInvalidClaims
+	}
+	return fmt.Errorf("%w: %w", sentinel, err)
 }
 
 func GetBearerToken(headers http.Header) (string, error) {
@@ -99,13 +148,26 @@ func GetBearerToken(headers http.Header) (string, error) {
 
 }
 func MakeRefreshToken() (string, error) {
+	return MakeSecureToken()
+}
 
-	refreshToken := make([]byte, 32)
-	_, err := rand.Read(refreshToken)
+// MakeSecureToken returns 32 random bytes hex encoded, for tokens that are
+// sent to the user once and only stored as a HashToken digest.
+func MakeSecureToken() (string, error) {
+
+	token := make([]byte, 32)
+	_, err := rand.Read(token)
 	if err != nil {
 		return "", errors.New("Error generating code")
 	}
-	return hex.EncodeToString(refreshToken), nil
+	return hex.EncodeToString(token), nil
+}
+
+// HashToken returns the hex SHA-256 digest of a high entropy token. It is not
+// suitable for passwords, use HashPassword for those.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
 
 func GetAPIKey(headers http.Header) (string, error) {
@@ -76,3 +76,26 @@ func TestGetBearerToken(t *testing.T) {
 		})
 	}
 }
+
+func TestHashToken(t *testing.T) {
+	token, err := MakeSecureToken()
+	if err != nil {
+		t.Fatalf("MakeSecureToken() unexpected error: %v", err)
+	}
+	if len(token) != 64 {
+		t.Errorf("MakeSecureToken() length = %d, want 64", len(token))
+	}
+
+	if HashToken(token) != HashToken(token) {
+		t.Errorf("HashToken() is not deterministic")
+	}
+	if HashToken(token) == token {
+		t.Errorf("HashToken() returned the token unchanged")
+	}
+
+	// sha256("abc")
+	want := "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+	if got := HashToken("abc"); got != want {
+		t.Errorf("HashToken(\"abc\") = %q, want %q", got, want)
+	}
+}
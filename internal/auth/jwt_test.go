@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func signClaims(t *testing.T, method jwt.SigningMethod, claims jwt.MapClaims, secret string) string {
+	t.Helper()
+	tokenString, err := jwt.NewWithClaims(method, claims).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatalf("SignedString() unexpected error: %v", err)
+	}
+	return tokenString
+}
+
+func TestValidateJWT(t *testing.T) {
+	const secret = "test-secret"
+	userID := uuid.New()
+
+	valid, err := MakeJWT(userID, secret, time.Hour)
+	if err != nil {
+		t.Fatalf("MakeJWT() unexpected error: %v", err)
+	}
+
+	with := func(key string, value any) jwt.MapClaims {
+		claims := newClaims(userID, TokenTypeAccess, time.Hour)
+		if value == nil {
+			delete(claims, key)
+		} else {
+			claims[key] = value
+		}
+		return claims
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		secret  string
+		wantErr error
+	}{
+		{"valid", valid, secret, nil},
+		{"wrong secret", valid, "other-secret", ErrTokenInvalidSignature},
+		{"malformed", "not-a-jwt", secret, ErrTokenMalformed},
+		{"expired", signClaims(t, jwt.SigningMethodHS256, with("exp", time.Now().Add(-time.Minute).Unix()), secret), secret, ErrTokenExpired},
+		{"expired within leeway", signClaims(t, jwt.SigningMethodHS256, with("exp", time.Now().Add(-DefaultLeeway/2).Unix()), secret), secret, nil},
+		{"missing exp", signClaims(t, jwt.SigningMethodHS256, with("exp", nil), secret), secret, ErrTokenInvalidClaims},
+		{"not valid yet", signClaims(t, jwt.SigningMethodHS256, with("nbf", time.Now().Add(time.Hour).Unix()), secret), secret, ErrTokenNotValidYet},
+		{"wrong issuer", signClaims(t, jwt.SigningMethodHS256, with("iss", "someone-else"), secret), secret, ErrTokenInvalidIssuer},
+		{"missing issuer", signClaims(t, jwt.SigningMethodHS256, with("iss", nil), secret), secret, ErrTokenInvalidClaims},
+		{"wrong audience", signClaims(t, jwt.SigningMethodHS256, with("aud", "other-api"), secret), secret, ErrTokenInvalidAudience},
+		{"wrong type", signClaims(t, jwt.SigningMethodHS256, with("token_type", "refresh"), secret), secret, ErrTokenInvalidType},
+		{"missing type", signClaims(t, jwt.SigningMethodHS256, with("token_type", nil), secret), secret, ErrTokenInvalidType},
+		{"bad subject", signClaims(t, jwt.SigningMethodHS256, with("sub", "nobody"), secret), secret, ErrTokenInvalidClaims},
+		{"hs512 not allowed", signClaims(t, jwt.SigningMethodHS512, with("", nil), secret), secret, ErrTokenInvalidSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ValidateJWT(tt.token, tt.secret)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("ValidateJWT() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ValidateJWT() unexpected error: %v", err)
+			}
+			if got != userID {
+				t.Errorf("ValidateJWT() = %v, want %v", got, userID)
+			}
+		})
+	}
+}
+
+func TestKeyRingTokenTypes(t *testing.T) {
+	ring := NewHMACKeyRing("test-secret")
+	userID := uuid.New()
+
+	other, err := ring.MakeToken(userID, "email_change", time.Hour)
+	if err != nil {
+		t.Fatalf("MakeToken() unexpected error: %v", err)
+	}
+	if _, err := ring.ValidateJWT(other); !errors.Is(err, ErrTokenInvalidType) {
+		t.Errorf("ValidateJWT() error = %v, want %v", err, ErrTokenInvalidType)
+	}
+	if got, err := ring.ValidateToken(other, "email_change"); err != nil || got != userID {
+		t.Errorf("ValidateToken() = %v, %v, want %v", got, err, userID)
+	}
+
+	expired := signClaims(t, jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": userID.String(), "iss": TokenIssuer, "aud": TokenAudience, "token_type": TokenTypeAccess,
+		"exp": time.Now().Add(-2 * time.Minute).Unix(),
+	}, "test-secret")
+	if _, err := ring.ValidateJWT(expired); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("ValidateJWT() error = %v, want %v", err, ErrTokenExpired)
+	}
+	ring.SetLeeway(5 * time.Minute)
+	if _, err := ring.ValidateJWT(expired); err != nil {
+		t.Errorf("ValidateJWT() with leeway unexpected error: %v", err)
+	}
+}
+
+func TestKeyRingValidateJWTExpiry(t *testing.T) {
+	ring := NewHMACKeyRing("test-secret")
+	userID := uuid.New()
+
+	tokenString, err := ring.MakeJWT(userID, time.Hour)
+	if err != nil {
+		t.Fatalf("MakeJWT() unexpected error: %v", err)
+	}
+	got, expiresAt, err := ring.ValidateJWTExpiry(tokenString)
+	if err != nil || got != userID {
+		t.Fatalf("ValidateJWTExpiry() = %v, %v, want %v", got, err, userID)
+	}
+	if until := time.Until(expiresAt); until < 59*time.Minute || until > time.Hour {
+		t.Errorf("ValidateJWTExpiry() expiry in %v, want about an hour", until)
+	}
+}
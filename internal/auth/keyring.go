@@ -0,0 +1,303 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmEdDSA = "EdDSA"
+	AlgorithmRS256 = "RS256"
+)
+
+var (
+	ErrUnknownKeyID   = errors.New("unknown signing key id")
+	ErrNoSigningKey   = errors.New("key ring has no active signing key")
+	ErrUnsupportedKey = errors.New("unsupported key type")
+)
+
+// SigningKey is one key of a KeyRing. Keys without a private part can only
+// verify tokens, which is how old keys are kept around after a rotation.
+type SigningKey struct {
+	ID         string
+	Algorithm  string
+	privateKey any
+	publicKey  any
+}
+
+// NewSigningKey wraps an ed25519.PrivateKey, *rsa.PrivateKey, or their
+// public counterparts for verification only.
+func NewSigningKey(id string, key any) (SigningKey, error) {
+	switch k := key.(type) {
+	case ed25519.PrivateKey:
+		return SigningKey{ID: id, Algorithm: AlgorithmEdDSA, privateKey: k, publicKey: k.Public()}, nil
+	case ed25519.PublicKey:
+		return SigningKey{ID: id, Algorithm: AlgorithmEdDSA, publicKey: k}, nil
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < 2048 {
+			return SigningKey{}, fmt.Errorf("rsa key %s is smaller than 2048 bits", id)
+		}
+		return SigningKey{ID: id, Algorithm: AlgorithmRS256, privateKey: k, publicKey: &k.PublicKey}, nil
+	case *rsa.PublicKey:
+		return SigningKey{ID: id, Algorithm: AlgorithmRS256, publicKey: k}, nil
+	default:
+		return SigningKey{}, ErrUnsupportedKey
+	}
+}
+
+// CanSign reports whether the key has its private part.
+func (k SigningKey) CanSign() bool {
+	return k.privateKey != nil
+}
+
+// KeyRing signs access tokens with its active key and verifies them with any
+// key it holds, picked by the "kid" header. Rotating means adding a new key,
+// making it active and removing the old one once its tokens have expired.
+//
+// A KeyRing made by NewHMACKeyRing instead uses a shared HS256 secret without
+// kid headers, the same tokens MakeJWT and ValidateJWT handle.
+type KeyRing struct {
+	active string
+	keys   map[string]SigningKey
+	secret string
+	leeway time.Duration
+}
+
+func NewKeyRing(activeKeyID string, keys ...SigningKey) (*KeyRing, error) {
+	ring := &KeyRing{
+		active: activeKeyID,
+		keys:   make(map[string]SigningKey, len(keys)),
+		leeway: DefaultLeeway,
+	}
+	for _, key := range keys {
+		if key.ID == "" {
+			return nil, errors.New("signing keys must have an id")
+		}
+		if _, exists := ring.keys[key.ID]; exists {
+			return nil, fmt.Errorf("duplicate signing key id %s", key.ID)
+		}
+		ring.keys[key.ID] = key
+	}
+
+	active, ok := ring.keys[activeKeyID]
+	if !ok {
+		return nil, fmt.Errorf("active key %s: %w", activeKeyID, ErrUnknownKeyID)
+	}
+	if !active.CanSign() {
+		return nil, fmt.Errorf("active key %s has no private key", activeKeyID)
+	}
+
+	return ring, nil
+}
+
+func NewHMACKeyRing(tokenSecret string) *KeyRing {
+	return &KeyRing{secret: tokenSecret, leeway: DefaultLeeway}
+}
+
+// SetLeeway changes the clock skew tolerated when validating tokens.
+func (k *KeyRing) SetLeeway(leeway time.Duration) {
+	k.leeway = leeway
+}
+
+// Algorithms returns the signing algorithms the key ring accepts, only the
+// ones of the keys it holds.
+func (k *KeyRing) Algorithms() []string {
+	if k.keys == nil {
+		return []string{AlgorithmHS256}
+	}
+
+	seen := map[string]bool{}
+	algorithms := []string{}
+	for _, key := range k.keys {
+		if !seen[key.Algorithm] {
+			seen[key.Algorithm] = true
+			algorithms = append(algorithms, key.Algorithm)
+		}
+	}
+	sort.Strings(algorithms)
+	return algorithms
+}
+
+// LoadKeyRing reads every <kid>.pem file in dir. Files holding a PKCS#8 or
+// PKCS#1 private key can sign, files holding a public key only verify.
+func LoadKeyRing(dir, activeKeyID string) (*KeyRing, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]SigningKey, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		id := strings.TrimSuffix(filepath.Base(path), ".pem")
+		key, err := parsePEMKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", id, err)
+		}
+
+		signingKey, err := NewSigningKey(id, key)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", id, err)
+		}
+		keys = append(keys, signingKey)
+	}
+
+	return NewKeyRing(activeKeyID, keys...)
+}
+
+func parsePEMKey(data []byte) (any, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+}
+
+// MakeJWT returns an access token for userID.
+func (k *KeyRing) MakeJWT(userID uuid.UUID, expiresIn time.Duration) (string, error) {
+	return k.MakeToken(userID, TokenTypeAccess, expiresIn)
+}
+
+// ValidateJWT accepts only access tokens, see ValidateToken.
+func (k *KeyRing) ValidateJWT(tokenString string) (uuid.UUID, error) {
+	return k.ValidateToken(tokenString, TokenTypeAccess)
+}
+
+// MakeToken signs a JWT of the given token_type for userID.
+func (k *KeyRing) MakeToken(userID uuid.UUID, tokenType string, expiresIn time.Duration) (string, error) {
+	return k.sign(newClaims(userID, tokenType, expiresIn))
+}
+
+// ValidateToken checks the signature, issuer, audience, expiry and the
+// token_type of tokenString and returns the user it was issued for. Errors
+// wrap the Err* sentinels of this package.
+func (k *KeyRing) ValidateToken(tokenString, tokenType string) (uuid.UUID, error) {
+	userID, _, err := parseToken(tokenString, tokenType, k.leeway, k.Algorithms(), k.keyFunc)
+	return userID, err
+}
+
+// ValidateJWTExpiry is ValidateJWT for long lived connections that have to
+// end when the token does, it also returns the token's expiry.
+func (k *KeyRing) ValidateJWTExpiry(tokenString string) (uuid.UUID, time.Time, error) {
+	userID, claims, err := parseToken(tokenString, TokenTypeAccess, k.leeway, k.Algorithms(), k.keyFunc)
+	if err != nil {
+		return uuid.Nil, time.Time{}, err
+	}
+	expiresAt, err := claims.GetExpirationTime()
+	if err != nil || expiresAt == nil {
+		return uuid.Nil, time.Time{}, ErrTokenInvalidClaims
+	}
+	return userID, expiresAt.Time, nil
+}
+
+func (k *KeyRing) sign(claims jwt.MapClaims) (string, error) {
+	if k.keys == nil {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(k.secret))
+	}
+
+	key, ok := k.keys[k.active]
+	if !ok || !key.CanSign() {
+		return "", ErrNoSigningKey
+	}
+
+	newToken := jwt.NewWithClaims(jwt.GetSigningMethod(key.Algorithm), claims)
+	newToken.Header["kid"] = key.ID
+
+	return newToken.SignedString(key.privateKey)
+}
+
+func (k *KeyRing) keyFunc(t *jwt.Token) (interface{}, error) {
+	if k.keys == nil {
+		return []byte(k.secret), nil
+	}
+
+	kid, _ := t.Header["kid"].(string)
+	key, ok := k.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKeyID
+	}
+	// the algorithm must be the one of the key, otherwise a public key
+	// could be used as an HMAC secret
+	if t.Method.Alg() != key.Algorithm {
+		return nil, jwt.ErrTokenSignatureInvalid
+	}
+	return key.publicKey, nil
+}
+
+// JSONWebKey is the public part of a signing key in RFC 7517 format.
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+}
+
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// JWKS returns the public keys other services need to verify our tokens.
+// An HMAC key ring has nothing it can publish.
+func (k *KeyRing) JWKS() JSONWebKeySet {
+	set := JSONWebKeySet{Keys: []JSONWebKey{}}
+
+	ids := make([]string, 0, len(k.keys))
+	for id := range k.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		key := k.keys[id]
+		jwk := JSONWebKey{KeyID: key.ID, Use: "sig", Algorithm: key.Algorithm}
+
+		switch pub := key.publicKey.(type) {
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set
+}
@@ -0,0 +1,240 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func newEd25519Key(t *testing.T, id string) SigningKey {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("ed25519.GenerateKey() unexpected error: %v", err)
+	}
+	key, err := NewSigningKey(id, priv)
+	if err != nil {
+		t.Fatalf("NewSigningKey() unexpected error: %v", err)
+	}
+	return key
+}
+
+func newRSAKey(t *testing.T, id string) (SigningKey, *rsa.PrivateKey) {
+	t.Helper()
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey() unexpected error: %v", err)
+	}
+	key, err := NewSigningKey(id, priv)
+	if err != nil {
+		t.Fatalf("NewSigningKey() unexpected error: %v", err)
+	}
+	return key, priv
+}
+
+func TestKeyRingSignAndValidate(t *testing.T) {
+	rsaKey, _ := newRSAKey(t, "rsa-1")
+	tests := []struct {
+		name string
+		key  SigningKey
+		alg  string
+	}{
+		{"ed25519", newEd25519Key(t, "ed-1"), AlgorithmEdDSA},
+		{"rsa", rsaKey, AlgorithmRS256},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ring, err := NewKeyRing(tt.key.ID, tt.key)
+			if err != nil {
+				t.Fatalf("NewKeyRing() unexpected error: %v", err)
+			}
+
+			userID := uuid.New()
+			tokenString, err := ring.MakeJWT(userID, time.Hour)
+			if err != nil {
+				t.Fatalf("MakeJWT() unexpected error: %v", err)
+			}
+
+			parsed, _, err := jwt.NewParser().ParseUnverified(tokenString, jwt.MapClaims{})
+			if err != nil {
+				t.Fatalf("ParseUnverified() unexpected error: %v", err)
+			}
+			if parsed.Header["kid"] != tt.key.ID || parsed.Header["alg"] != tt.alg {
+				t.Errorf("token header = %v, want kid %q and alg %q", parsed.Header, tt.key.ID, tt.alg)
+			}
+
+			got, err := ring.ValidateJWT(tokenString)
+			if err != nil {
+				t.Fatalf("ValidateJWT() unexpected error: %v", err)
+			}
+			if got != userID {
+				t.Errorf("ValidateJWT() = %v, want %v", got, userID)
+			}
+		})
+	}
+}
+
+func TestKeyRingRotation(t *testing.T) {
+	oldKey := newEd25519Key(t, "2025-01")
+	newKey := newEd25519Key(t, "2025-02")
+	userID := uuid.New()
+
+	oldRing, err := NewKeyRing(oldKey.ID, oldKey)
+	if err != nil {
+		t.Fatalf("NewKeyRing() unexpected error: %v", err)
+	}
+	oldToken, err := oldRing.MakeJWT(userID, time.Hour)
+	if err != nil {
+		t.Fatalf("MakeJWT() unexpected error: %v", err)
+	}
+
+	// after the rotation the old key only verifies
+	oldPublic, err := NewSigningKey(oldKey.ID, oldKey.publicKey)
+	if err != nil {
+		t.Fatalf("NewSigningKey() unexpected error: %v", err)
+	}
+	rotated, err := NewKeyRing(newKey.ID, newKey, oldPublic)
+	if err != nil {
+		t.Fatalf("NewKeyRing() unexpected error: %v", err)
+	}
+	if _, err := rotated.ValidateJWT(oldToken); err != nil {
+		t.Errorf("ValidateJWT() of a token signed by the previous key: %v", err)
+	}
+
+	newToken, err := rotated.MakeJWT(userID, time.Hour)
+	if err != nil {
+		t.Fatalf("MakeJWT() unexpected error: %v", err)
+	}
+	if _, err := oldRing.ValidateJWT(newToken); err == nil {
+		t.Errorf("ValidateJWT() accepted a token signed by a key it doesn't hold")
+	}
+
+	retired, err := NewKeyRing(newKey.ID, newKey)
+	if err != nil {
+		t.Fatalf("NewKeyRing() unexpected error: %v", err)
+	}
+	if _, err := retired.ValidateJWT(oldToken); err == nil {
+		t.Errorf("ValidateJWT() accepted a token signed by a retired key")
+	}
+}
+
+func TestKeyRingRejectsAlgorithmConfusion(t *testing.T) {
+	key, priv := newRSAKey(t, "rsa-1")
+	ring, err := NewKeyRing(key.ID, key)
+	if err != nil {
+		t.Fatalf("NewKeyRing() unexpected error: %v", err)
+	}
+
+	// an HS256 token "signed" with the published public key
+	publicDER, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey() unexpected error: %v", err)
+	}
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": uuid.New().String(),
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+	forged.Header["kid"] = key.ID
+	forgedString, err := forged.SignedString(publicDER)
+	if err != nil {
+		t.Fatalf("SignedString() unexpected error: %v", err)
+	}
+
+	if _, err := ring.ValidateJWT(forgedString); err == nil {
+		t.Errorf("ValidateJWT() accepted an HS256 token signed with the public key")
+	}
+}
+
+func TestNewKeyRingRequiresPrivateActiveKey(t *testing.T) {
+	key := newEd25519Key(t, "ed-1")
+	public, err := NewSigningKey(key.ID, key.publicKey)
+	if err != nil {
+		t.Fatalf("NewSigningKey() unexpected error: %v", err)
+	}
+
+	if _, err := NewKeyRing(key.ID, public); err == nil {
+		t.Errorf("NewKeyRing() with a public only active key error = nil, want error")
+	}
+	if _, err := NewKeyRing("missing", key); err == nil {
+		t.Errorf("NewKeyRing() with an unknown active key error = nil, want error")
+	}
+}
+
+func TestHMACKeyRing(t *testing.T) {
+	ring := NewHMACKeyRing("secret")
+	userID := uuid.New()
+
+	tokenString, err := ring.MakeJWT(userID, time.Hour)
+	if err != nil {
+		t.Fatalf("MakeJWT() unexpected error: %v", err)
+	}
+	got, err := ValidateJWT(tokenString, "secret")
+	if err != nil || got != userID {
+		t.Errorf("ValidateJWT() = %v, %v, want %v", got, err, userID)
+	}
+	if keys := ring.JWKS().Keys; len(keys) != 0 {
+		t.Errorf("JWKS() of an HMAC key ring = %v, want no keys", keys)
+	}
+}
+
+func TestLoadKeyRingAndJWKS(t *testing.T) {
+	dir := t.TempDir()
+
+	_, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("ed25519.GenerateKey() unexpected error: %v", err)
+	}
+	edDER, err := x509.MarshalPKCS8PrivateKey(edPriv)
+	if err != nil {
+		t.Fatalf("MarshalPKCS8PrivateKey() unexpected error: %v", err)
+	}
+	writePEM(t, filepath.Join(dir, "ed-2.pem"), "PRIVATE KEY", edDER)
+
+	rsaPriv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey() unexpected error: %v", err)
+	}
+	rsaDER, err := x509.MarshalPKIXPublicKey(&rsaPriv.PublicKey)
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey() unexpected error: %v", err)
+	}
+	writePEM(t, filepath.Join(dir, "rsa-1.pem"), "PUBLIC KEY", rsaDER)
+
+	ring, err := LoadKeyRing(dir, "ed-2")
+	if err != nil {
+		t.Fatalf("LoadKeyRing() unexpected error: %v", err)
+	}
+
+	keys := ring.JWKS().Keys
+	if len(keys) != 2 {
+		t.Fatalf("JWKS() returned %d keys, want 2", len(keys))
+	}
+	if keys[0].KeyID != "ed-2" || keys[0].KeyType != "OKP" || keys[0].Curve != "Ed25519" || keys[0].X == "" {
+		t.Errorf("JWKS() ed25519 key = %+v", keys[0])
+	}
+	if keys[1].KeyID != "rsa-1" || keys[1].KeyType != "RSA" || keys[1].Algorithm != AlgorithmRS256 || keys[1].E != "AQAB" {
+		t.Errorf("JWKS() rsa key = %+v", keys[1])
+	}
+
+	if _, err := LoadKeyRing(dir, "rsa-1"); err == nil {
+		t.Errorf("LoadKeyRing() with a public only active key error = nil, want error")
+	}
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("WriteFile() unexpected error: %v", err)
+	}
+}
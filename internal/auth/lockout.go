@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"time"
+)
+
+// LockoutPolicy decides how long logins are refused after repeated failures.
+// The first FreeAttempts failures cost nothing, then every failure doubles the
+// lockout, starting at BaseDelay, up to MaxDelay.
+type LockoutPolicy struct {
+	FreeAttempts int
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+}
+
+var (
+	// AccountLockoutPolicy applies to the failures for one email address.
+	AccountLockoutPolicy = LockoutPolicy{FreeAttempts: 5, BaseDelay: 30 * time.Second, MaxDelay: time.Hour}
+	// IPLockoutPolicy applies to one client address, across every account it
+	// tries, so it is more permissive for shared addresses.
+	IPLockoutPolicy = LockoutPolicy{FreeAttempts: 20, BaseDelay: 30 * time.Second, MaxDelay: time.Hour}
+
+	// PasswordResetAccountPolicy applies to the reset emails asked for one
+	// address, every request counts, not only failed ones.
+	PasswordResetAccountPolicy = LockoutPolicy{FreeAttempts: 3, BaseDelay: 15 * time.Minute, MaxDelay: 24 * time.Hour}
+	// PasswordResetIPPolicy applies to the reset emails one client address
+	// asks for, across every account.
+	PasswordResetIPPolicy = LockoutPolicy{FreeAttempts: 10, BaseDelay: 15 * time.Minute, MaxDelay: 24 * time.Hour}
+)
+
+// Delay returns the lockout after the given number of consecutive failures,
+// zero when none applies.
+func (p LockoutPolicy) Delay(failures int) time.Duration {
+	if failures < p.FreeAttempts {
+		return 0
+	}
+
+	delay := p.BaseDelay
+	for i := p.FreeAttempts; i < failures; i++ {
+		delay *= 2
+		if delay >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	return delay
+}
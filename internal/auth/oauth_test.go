@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestVerifyPKCE(t *testing.T) {
+	// RFC 7636 appendix B
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	if got := PKCEChallenge(verifier); got != challenge {
+		t.Errorf("PKCEChallenge() = %q, want %q", got, challenge)
+	}
+
+	tests := []struct {
+		name     string
+		verifier string
+		want     bool
+	}{
+		{"matching", verifier, true},
+		{"other verifier", "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXj", false},
+		{"too short", "abc", false},
+		{"invalid characters", "dBjftJeZ4CVP+mB92K27uhbUJU1p1r/wW1gFWFOEjXk", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VerifyPKCE(tt.verifier, challenge); got != tt.want {
+				t.Errorf("VerifyPKCE() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidRedirectURI(t *testing.T) {
+	tests := []struct {
+		uri  string
+		want bool
+	}{
+		{"https://app.example.com/callback", true},
+		{"http://localhost:8080/callback", true},
+		{"http://127.0.0.1/callback", true},
+		{"http://app.example.com/callback", false},
+		{"https://app.example.com/callback#token", false},
+		{"https://user@app.example.com/callback", false},
+		{"/callback", false},
+		{"javascript:alert(1)", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			if got := ValidRedirectURI(tt.uri); got != tt.want {
+				t.Errorf("ValidRedirectURI(%q) = %v, want %v", tt.uri, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOAuthToken(t *testing.T) {
+	ring := NewHMACKeyRing("test-secret")
+	userID, clientID := uuid.New(), uuid.New()
+	scopes := []string{ScopeChirpsRead, ScopeChirpsWrite}
+
+	token, err := ring.MakeOAuthToken(userID, clientID, scopes, time.Hour)
+	if err != nil {
+		t.Fatalf("MakeOAuthToken() unexpected error: %v", err)
+	}
+
+	claims, err := ring.ValidateOAuthToken(token)
+	if err != nil {
+		t.Fatalf("ValidateOAuthToken() unexpected error: %v", err)
+	}
+	if claims.UserID != userID || claims.ClientID != clientID || !reflect.DeepEqual(claims.Scopes, scopes) {
+		t.Errorf("ValidateOAuthToken() = %+v, want user %v client %v scopes %v", claims, userID, clientID, scopes)
+	}
+
+	// an OAuth token must never pass as an unrestricted access token
+	if _, err := ring.ValidateJWT(token); !errors.Is(err, ErrTokenInvalidType) {
+		t.Errorf("ValidateJWT() error = %v, want %v", err, ErrTokenInvalidType)
+	}
+
+	access, err := ring.MakeJWT(userID, time.Hour)
+	if err != nil {
+		t.Fatalf("MakeJWT() unexpected error: %v", err)
+	}
+	if _, err := ring.ValidateOAuthToken(access); !errors.Is(err, ErrTokenInvalidType) {
+		t.Errorf("ValidateOAuthToken() error = %v, want %v", err, ErrTokenInvalidType)
+	}
+}
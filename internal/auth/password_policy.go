@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"bufio"
+	_ "embed"
+	"errors"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	ErrPasswordTooShort = errors.New("password is too short")
+	ErrPasswordTooLong  = errors.New("password is too long")
+	ErrPasswordBreached = errors.New("password is too common")
+)
+
+// DefaultMaxPasswordLength keeps hashing cost bounded, it is not a limit
+// anyone should reach with a password manager.
+const DefaultMaxPasswordLength = 256
+
+//go:embed common_passwords.txt
+var commonPasswords string
+
+// PasswordPolicy decides which new passwords are accepted. Lengths count
+// characters, not bytes. Breached passwords are compared case insensitively.
+type PasswordPolicy struct {
+	MinLength int
+	MaxLength int
+	breached  map[string]bool
+}
+
+// NewPasswordPolicy returns a policy that already rejects the most common
+// passwords, more can be added with LoadBreachedPasswords.
+func NewPasswordPolicy(minLength int) *PasswordPolicy {
+	policy := &PasswordPolicy{
+		MinLength: minLength,
+		MaxLength: DefaultMaxPasswordLength,
+		breached:  map[string]bool{},
+	}
+	policy.LoadBreachedPasswords(strings.NewReader(commonPasswords))
+	return policy
+}
+
+// LoadBreachedPasswords reads one password per line.
+func (p *PasswordPolicy) LoadBreachedPasswords(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if password := strings.TrimSpace(scanner.Text()); password != "" {
+			p.breached[strings.ToLower(password)] = true
+		}
+	}
+	return scanner.Err()
+}
+
+func (p *PasswordPolicy) Check(password string) error {
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		return ErrPasswordTooShort
+	}
+	if length > p.MaxLength {
+		return ErrPasswordTooLong
+	}
+	if p.breached[strings.ToLower(password)] {
+		return ErrPasswordBreached
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// cheap parameters so the tests stay fast
+var testArgon2Params = Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestPasswordHasher(t *testing.T) {
+	hasher := NewPasswordHasher(testArgon2Params)
+
+	hash, err := hasher.Hash("correct horse battery staple")
+	if err != nil {
+		t.Fatalf("Hash() unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Errorf("Hash() = %q, want a PHC argon2id string", hash)
+	}
+
+	other, err := hasher.Hash("correct horse battery staple")
+	if err != nil {
+		t.Fatalf("Hash() unexpected error: %v", err)
+	}
+	if hash == other {
+		t.Errorf("Hash() returned the same hash twice, salt is not random")
+	}
+
+	needsRehash, err := hasher.Verify(hash, "correct horse battery staple")
+	if err != nil || needsRehash {
+		t.Errorf("Verify() = %v, %v, want false, nil", needsRehash, err)
+	}
+
+	if _, err := hasher.Verify(hash, "wrong password"); !errors.Is(err, ErrPasswordMismatch) {
+		t.Errorf("Verify() error = %v, want %v", err, ErrPasswordMismatch)
+	}
+
+	stronger := NewPasswordHasher(Argon2Params{Memory: 2048, Iterations: 2, Parallelism: 1, SaltLength: 16, KeyLength: 32})
+	needsRehash, err = stronger.Verify(hash, "correct horse battery staple")
+	if err != nil || !needsRehash {
+		t.Errorf("Verify() with new parameters = %v, %v, want true, nil", needsRehash, err)
+	}
+}
+
+func TestPasswordHasherLongPasswords(t *testing.T) {
+	hasher := NewPasswordHasher(testArgon2Params)
+
+	// bcrypt ignores everything past 72 bytes
+	prefix := strings.Repeat("a", 72)
+	hash, err := hasher.Hash(prefix + "first")
+	if err != nil {
+		t.Fatalf("Hash() unexpected error: %v", err)
+	}
+	if _, err := hasher.Verify(hash, prefix+"second"); !errors.Is(err, ErrPasswordMismatch) {
+		t.Errorf("Verify() error = %v, want %v", err, ErrPasswordMismatch)
+	}
+}
+
+func TestPasswordHasherBcrypt(t *testing.T) {
+	hasher := NewPasswordHasher(testArgon2Params)
+
+	legacy, err := bcrypt.GenerateFromPassword([]byte("old password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("bcrypt.GenerateFromPassword() unexpected error: %v", err)
+	}
+
+	needsRehash, err := hasher.Verify(string(legacy), "old password")
+	if err != nil || !needsRehash {
+		t.Errorf("Verify() = %v, %v, want true, nil", needsRehash, err)
+	}
+	if _, err := hasher.Verify(string(legacy), "wrong password"); !errors.Is(err, ErrPasswordMismatch) {
+		t.Errorf("Verify() error = %v, want %v", err, ErrPasswordMismatch)
+	}
+}
+
+func TestPasswordHasherInvalidHash(t *testing.T) {
+	hasher := NewPasswordHasher(testArgon2Params)
+
+	for _, hash := range []string{"", "plaintext", "$argon2i$v=19$m=1024,t=1,p=1$c2FsdA$a2V5", "$argon2id$v=16$m=1024,t=1,p=1$c2FsdA$a2V5", "$argon2id$v=19$m=x$c2FsdA$a2V5"} {
+		if _, err := hasher.Verify(hash, "password"); !errors.Is(err, ErrUnknownHashFormat) {
+			t.Errorf("Verify(%q) error = %v, want %v", hash, err, ErrUnknownHashFormat)
+		}
+	}
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	policy := NewPasswordPolicy(8)
+	if err := policy.LoadBreachedPasswords(strings.NewReader("Leaked-Password-1\n\n")); err != nil {
+		t.Fatalf("LoadBreachedPasswords() unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		want     error
+	}{
+		{"ok", "tr0ub4dor&3x", nil},
+		{"too short", "short", ErrPasswordTooShort},
+		{"counts characters", "ááááááá", ErrPasswordTooShort},
+		{"too long", strings.Repeat("x", DefaultMaxPasswordLength+1), ErrPasswordTooLong},
+		{"common", "Password123", ErrPasswordBreached},
+		{"loaded list", "leaked-password-1", ErrPasswordBreached},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := policy.Check(tt.password); !errors.Is(err, tt.want) {
+				t.Errorf("Check() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
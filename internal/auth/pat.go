@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// PersonalAccessTokenPrefix starts every personal access token, which is how
+// they are told apart from JWTs in the Authorization header.
+const PersonalAccessTokenPrefix = "chirpy_pat_"
+
+const (
+	ScopeChirpsRead   = "chirps:read"
+	ScopeChirpsWrite  = "chirps:write"
+	ScopeProfileWrite = "profile:write"
+)
+
+var ErrInvalidScope = errors.New("invalid scope")
+
+var knownScopes = map[string]bool{
+	ScopeChirpsRead:   true,
+	ScopeChirpsWrite:  true,
+	ScopeProfileWrite: true,
+}
+
+// MakePersonalAccessToken returns a new token to be shown to the user once and
+// stored as its HashToken digest.
+func MakePersonalAccessToken() (string, error) {
+	token, err := MakeSecureToken()
+	if err != nil {
+		return "", err
+	}
+	return PersonalAccessTokenPrefix + token, nil
+}
+
+func IsPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, PersonalAccessTokenPrefix)
+}
+
+// NormalizeScopes checks that every scope is known and returns them sorted
+// without duplicates. At least one scope is required.
+func NormalizeScopes(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return nil, ErrInvalidScope
+	}
+
+	seen := map[string]bool{}
+	normalized := []string{}
+	for _, scope := range scopes {
+		if !knownScopes[scope] {
+			return nil, ErrInvalidScope
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			normalized = append(normalized, scope)
+		}
+	}
+	sort.Strings(normalized)
+	return normalized, nil
+}
+
+func HasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestMakePersonalAccessToken(t *testing.T) {
+	token, err := MakePersonalAccessToken()
+	if err != nil {
+		t.Fatalf("MakePersonalAccessToken() unexpected error: %v", err)
+	}
+	if !IsPersonalAccessToken(token) {
+		t.Errorf("IsPersonalAccessToken(%q) = false, want true", token)
+	}
+	if len(token) != len(PersonalAccessTokenPrefix)+64 {
+		t.Errorf("MakePersonalAccessToken() length = %d, want %d", len(token), len(PersonalAccessTokenPrefix)+64)
+	}
+
+	jwtString, err := MakeJWT([16]byte{1}, "secret", 0)
+	if err != nil {
+		t.Fatalf("MakeJWT() unexpected error: %v", err)
+	}
+	if IsPersonalAccessToken(jwtString) {
+		t.Errorf("IsPersonalAccessToken() = true for a JWT")
+	}
+}
+
+func TestNormalizeScopes(t *testing.T) {
+	tests := []struct {
+		name    string
+		scopes  []string
+		want    []string
+		wantErr bool
+	}{
+		{"single", []string{ScopeChirpsWrite}, []string{ScopeChirpsWrite}, false},
+		{"sorted and deduplicated", []string{ScopeProfileWrite, ScopeChirpsRead, ScopeProfileWrite}, []string{ScopeChirpsRead, ScopeProfileWrite}, false},
+		{"empty", nil, nil, true},
+		{"unknown", []string{ScopeChirpsRead, "admin"}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeScopes(tt.scopes)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidScope) {
+					t.Errorf("NormalizeScopes() error = %v, want %v", err, ErrInvalidScope)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NormalizeScopes() unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NormalizeScopes() = %v, want %v", got, tt.want)
+			}
+			if !HasScope(got, tt.want[0]) {
+				t.Errorf("HasScope(%v, %q) = false, want true", got, tt.want[0])
+			}
+		})
+	}
+}
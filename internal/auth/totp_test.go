@@ -0,0 +1,173 @@
+package auth
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"hash"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHOTPRFC4226Vectors(t *testing.T) {
+	key := []byte("12345678901234567890")
+	want := []string{
+		"755224", "287082", "359152", "969429", "338314",
+		"254676", "287922", "162583", "399871", "520489",
+	}
+
+	for counter, code := range want {
+		if got := hotp(key, uint64(counter), 6, sha1.New); got != code {
+			t.Errorf("hotp(counter=%d) = %q, want %q", counter, got, code)
+		}
+	}
+}
+
+func TestTOTPRFC6238Vectors(t *testing.T) {
+	keys := map[string]struct {
+		key []byte
+		h   func() hash.Hash
+	}{
+		"SHA1":   {[]byte("12345678901234567890"), sha1.New},
+		"SHA256": {[]byte("12345678901234567890123456789012"), sha256.New},
+		"SHA512": {[]byte("1234567890123456789012345678901234567890123456789012345678901234"), sha512.New},
+	}
+
+	tests := []struct {
+		unix int64
+		mode string
+		want string
+	}{
+		{59, "SHA1", "94287082"},
+		{59, "SHA256", "46119246"},
+		{59, "SHA512", "90693936"},
+		{1111111109, "SHA1", "07081804"},
+		{1111111109, "SHA256", "68084774"},
+		{1111111109, "SHA512", "25091201"},
+		{1111111111, "SHA1", "14050471"},
+		{1111111111, "SHA256", "67062674"},
+		{1111111111, "SHA512", "99943326"},
+		{1234567890, "SHA1", "89005924"},
+		{1234567890, "SHA256", "91819424"},
+		{1234567890, "SHA512", "93441116"},
+		{2000000000, "SHA1", "69279037"},
+		{2000000000, "SHA256", "90698825"},
+		{2000000000, "SHA512", "38618901"},
+		{20000000000, "SHA1", "65353130"},
+		{20000000000, "SHA256", "77737706"},
+		{20000000000, "SHA512", "47863826"},
+	}
+
+	for _, tt := range tests {
+		k := keys[tt.mode]
+		got := hotp(k.key, totpStep(time.Unix(tt.unix, 0)), 8, k.h)
+		if got != tt.want {
+			t.Errorf("TOTP %s at %d = %q, want %q", tt.mode, tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestGenerateTOTPCode(t *testing.T) {
+	// the RFC 6238 SHA-1 key, base32 encoded the way users receive it
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+	got, err := GenerateTOTPCode(secret, time.Unix(1111111109, 0))
+	if err != nil {
+		t.Fatalf("GenerateTOTPCode() unexpected error: %v", err)
+	}
+	// last 6 digits of the 8 digit RFC vector 07081804
+	if got != "081804" {
+		t.Errorf("GenerateTOTPCode() = %q, want %q", got, "081804")
+	}
+
+	if _, err := GenerateTOTPCode("not base32!", time.Now()); err == nil {
+		t.Errorf("GenerateTOTPCode() with invalid secret error = nil, want error")
+	}
+}
+
+func TestValidateTOTPCode(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("GenerateTOTPSecret() unexpected error: %v", err)
+	}
+	now := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name   string
+		codeAt time.Time
+		wantOK bool
+	}{
+		{"current period", now, true},
+		{"previous period", now.Add(-totpPeriod), true},
+		{"next period", now.Add(totpPeriod), true},
+		{"two periods ago", now.Add(-2 * totpPeriod), false},
+		{"two periods ahead", now.Add(2 * totpPeriod), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := GenerateTOTPCode(secret, tt.codeAt)
+			if err != nil {
+				t.Fatalf("GenerateTOTPCode() unexpected error: %v", err)
+			}
+			step, ok := ValidateTOTPCode(secret, code, now)
+			if ok != tt.wantOK {
+				t.Fatalf("ValidateTOTPCode() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && step != int64(totpStep(tt.codeAt)) {
+				t.Errorf("ValidateTOTPCode() step = %d, want %d", step, totpStep(tt.codeAt))
+			}
+		})
+	}
+
+	if _, ok := ValidateTOTPCode(secret, "12345", now); ok {
+		t.Errorf("ValidateTOTPCode() accepted a code with the wrong length")
+	}
+}
+
+func TestTOTPProvisioningURI(t *testing.T) {
+	uri := TOTPProvisioningURI("JBSWY3DPEHPK3PXP", "user@example.com", "Chirpy")
+
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		t.Fatalf("TOTPProvisioningURI() is not a valid URL: %v", err)
+	}
+	if parsed.Scheme != "otpauth" || parsed.Host != "totp" {
+		t.Errorf("TOTPProvisioningURI() = %q, want otpauth://totp/...", uri)
+	}
+	if parsed.Path != "/Chirpy:user@example.com" {
+		t.Errorf("TOTPProvisioningURI() label = %q, want %q", parsed.Path, "/Chirpy:user@example.com")
+	}
+	query := parsed.Query()
+	if query.Get("secret") != "JBSWY3DPEHPK3PXP" || query.Get("issuer") != "Chirpy" {
+		t.Errorf("TOTPProvisioningURI() query = %q", parsed.RawQuery)
+	}
+}
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatalf("GenerateRecoveryCodes() unexpected error: %v", err)
+	}
+	if len(codes) != 10 {
+		t.Fatalf("GenerateRecoveryCodes() returned %d codes, want 10", len(codes))
+	}
+
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' {
+			t.Errorf("recovery code %q is not formatted as xxxxx-xxxxx", code)
+		}
+		if seen[code] {
+			t.Errorf("recovery code %q generated twice", code)
+		}
+		seen[code] = true
+
+		if got := NormalizeRecoveryCode(" " + strings.ToUpper(code) + " "); got != strings.ReplaceAll(code, "-", "") {
+			t.Errorf("NormalizeRecoveryCode(%q) = %q", code, got)
+		}
+	}
+}
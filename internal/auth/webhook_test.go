@@ -0,0 +1,184 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+type memoryReplayStore struct {
+	mu   sync.Mutex
+	seen map[string]bool
+}
+
+func (s *memoryReplayStore) Remember(ctx context.Context, signature string, expiresAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.seen[signature] {
+		return false, nil
+	}
+	s.seen[signature] = true
+	return true, nil
+}
+
+// fakePolka signs deliveries the way Polka does.
+type fakePolka struct {
+	url    string
+	secret string
+}
+
+func (p fakePolka) request(t *testing.T, body []byte, sentAt time.Time) *http.Request {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("NewRequest() unexpected error: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(sentAt.Unix(), 10))
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(p.secret, sentAt, body))
+	return req
+}
+
+func (p fakePolka) send(t *testing.T, req *http.Request) error {
+	t.Helper()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Do() unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	msg, _ := io.ReadAll(resp.Body)
+	return errors.New(string(bytes.TrimSpace(msg)))
+}
+
+func newWebhookServer(t *testing.T, secret string) *httptest.Server {
+	t.Helper()
+	verifier := NewWebhookVerifier(secret, DefaultWebhookTolerance, &memoryReplayStore{seen: map[string]bool{}})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := verifier.Verify(r.Context(), r.Header, body); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestWebhookVerifier(t *testing.T) {
+	const secret = "polka-secret"
+	body := []byte(`{"event":"user.upgraded","data":{"user_id":"3311741c-680c-4546-99f3-fc9efac2036c"}}`)
+
+	tests := []struct {
+		name    string
+		secret  string
+		sentAt  time.Time
+		tamper  func(r *http.Request)
+		wantErr error
+	}{
+		{
+			name:   "valid",
+			secret: secret,
+			sentAt: time.Now(),
+		},
+		{
+			name:   "rotated secret",
+			secret: secret,
+			sentAt: time.Now(),
+			tamper: func(r *http.Request) {
+				r.Header.Set(WebhookSignatureHeader, SignWebhook("old-secret", time.Now(), body)+", "+r.Header.Get(WebhookSignatureHeader))
+			},
+		},
+		{
+			name:    "wrong secret",
+			secret:  "not-the-secret",
+			sentAt:  time.Now(),
+			wantErr: ErrWebhookSignatureInvalid,
+		},
+		{
+			name:   "tampered body",
+			secret: secret,
+			sentAt: time.Now(),
+			tamper: func(r *http.Request) {
+				tampered := bytes.Replace(body, []byte("upgraded"), []byte("downgraded"), 1)
+				r.Body = io.NopCloser(bytes.NewReader(tampered))
+				r.ContentLength = int64(len(tampered))
+			},
+			wantErr: ErrWebhookSignatureInvalid,
+		},
+		{
+			name:   "missing signature",
+			secret: secret,
+			sentAt: time.Now(),
+			tamper: func(r *http.Request) {
+				r.Header.Del(WebhookSignatureHeader)
+			},
+			wantErr: ErrWebhookSignatureMissing,
+		},
+		{
+			name:    "too old",
+			secret:  secret,
+			sentAt:  time.Now().Add(-DefaultWebhookTolerance - time.Minute),
+			wantErr: ErrWebhookTimestampInvalid,
+		},
+		{
+			name:    "from the future",
+			secret:  secret,
+			sentAt:  time.Now().Add(DefaultWebhookTolerance + time.Minute),
+			wantErr: ErrWebhookTimestampInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newWebhookServer(t, secret)
+			polka := fakePolka{url: server.URL, secret: tt.secret}
+			req := polka.request(t, body, tt.sentAt)
+			if tt.tamper != nil {
+				tt.tamper(req)
+			}
+
+			err := polka.send(t, req)
+			if tt.wantErr == nil && err != nil {
+				t.Errorf("delivery refused: %v", err)
+			}
+			if tt.wantErr != nil && (err == nil || err.Error() != tt.wantErr.Error()) {
+				t.Errorf("delivery error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWebhookVerifierRejectsReplays(t *testing.T) {
+	const secret = "polka-secret"
+	server := newWebhookServer(t, secret)
+	polka := fakePolka{url: server.URL, secret: secret}
+	body := []byte(`{"event":"user.upgraded"}`)
+	sentAt := time.Now()
+
+	if err := polka.send(t, polka.request(t, body, sentAt)); err != nil {
+		t.Fatalf("first delivery refused: %v", err)
+	}
+	if err := polka.send(t, polka.request(t, body, sentAt)); err == nil || err.Error() != ErrWebhookReplayed.Error() {
+		t.Errorf("replayed delivery error = %v, want %v", err, ErrWebhookReplayed)
+	}
+
+	// a retry is signed again with a new timestamp
+	if err := polka.send(t, polka.request(t, body, sentAt.Add(time.Second))); err != nil {
+		t.Errorf("retried delivery refused: %v", err)
+	}
+}
@@ -3,13 +3,53 @@ package config
 import (
 	"errors"
 	"os"
+	"strconv"
+	"time"
 )
 
 type Config struct {
 	DBURL     string
 	JWTSecret string
 	Platform  string
-	PolkaKey  string
+	// PolkaKey is the secret Polka signs its webhooks with
+	PolkaKey string
+	Mail     MailConfig
+	Password PasswordConfig
+	// when JWTKeysDir is set tokens are signed with the asymmetric keys in
+	// it instead of JWTSecret
+	JWTKeysDir     string
+	JWTActiveKeyID string
+	// clock skew tolerated on the exp, nbf and iat claims
+	JWTLeeway time.Duration
+	// how far a webhook's timestamp may be from our clock
+	PolkaWebhookTolerance time.Duration
+	// how often subscriptions past their period are expired
+	SubscriptionExpiryInterval time.Duration
+	// how often scheduled chirps that are due are published
+	ScheduledChirpInterval time.Duration
+}
+
+// MailConfig selects how outgoing emails are delivered. Mailer is "log" (the
+// default, writes to stdout or LogFile) or "smtp".
+type MailConfig struct {
+	Mailer       string
+	From         string
+	LogFile      string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+}
+
+// PasswordConfig holds the Argon2id cost parameters (memory in KiB) and the
+// policy for new passwords. BreachedPasswordsFile is an optional list, one
+// password per line, added to the built-in list of common passwords.
+type PasswordConfig struct {
+	Argon2Memory          uint32
+	Argon2Iterations      uint32
+	Argon2Parallelism     uint8
+	MinLength             int
+	BreachedPasswordsFile string
 }
 
 func LoadConfig() (*Config, error) {
@@ -19,11 +59,22 @@ func LoadConfig() (*Config, error) {
 		return nil, errors.New("DB_URL not found in enviroment")
 	}
 
+	JWTKeysDir := os.Getenv("JWT_KEYS_DIR")
+	JWTActiveKeyID := os.Getenv("JWT_ACTIVE_KEY_ID")
+	if JWTKeysDir != "" && JWTActiveKeyID == "" {
+		return nil, errors.New("JWT_ACTIVE_KEY_ID not found in enviroment")
+	}
+
 	JWTSecret := os.Getenv("JWT_SECRET")
-	if JWTSecret == "" {
+	if JWTSecret == "" && JWTKeysDir == "" {
 		return nil, errors.New("JWT_SECRET not found in enviroment")
 	}
 
+	JWTLeeway, err := time.ParseDuration(getEnvDefault("JWT_LEEWAY", "30s"))
+	if err != nil || JWTLeeway < 0 {
+		return nil, errors.New("JWT_LEEWAY must be a non negative duration")
+	}
+
 	Platform := os.Getenv("PLATFORM")
 	if Platform == "" {
 		return nil, errors.New("PLATFORM not found in enviroment")
@@ -34,11 +85,107 @@ func LoadConfig() (*Config, error) {
 		return nil, errors.New("POLKA_KEY not found in enviroment")
 	}
 
+	PolkaWebhookTolerance, err := time.ParseDuration(getEnvDefault("POLKA_WEBHOOK_TOLERANCE", "5m"))
+	if err != nil || PolkaWebhookTolerance <= 0 {
+		return nil, errors.New("POLKA_WEBHOOK_TOLERANCE must be a positive duration")
+	}
+
+	SubscriptionExpiryInterval, err := time.ParseDuration(getEnvDefault("SUBSCRIPTION_EXPIRY_INTERVAL", "1m"))
+	if err != nil || SubscriptionExpiryInterval <= 0 {
+		return nil, errors.New("SUBSCRIPTION_EXPIRY_INTERVAL must be a positive duration")
+	}
+
+	ScheduledChirpInterval, err := time.ParseDuration(getEnvDefault("SCHEDULED_CHIRP_INTERVAL", "30s"))
+	if err != nil || ScheduledChirpInterval <= 0 {
+		return nil, errors.New("SCHEDULED_CHIRP_INTERVAL must be a positive duration")
+	}
+
+	Mail, err := loadMailConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	Password, err := loadPasswordConfig()
+	if err != nil {
+		return nil, err
+	}
+
 	return &Config{
-		Platform:  Platform,
-		PolkaKey:  PolkaKey,
-		JWTSecret: JWTSecret,
-		DBURL:     dbURL,
+		Platform:                   Platform,
+		PolkaKey:                   PolkaKey,
+		PolkaWebhookTolerance:      PolkaWebhookTolerance,
+		SubscriptionExpiryInterval: SubscriptionExpiryInterval,
+		ScheduledChirpInterval:     ScheduledChirpInterval,
+		JWTSecret:                  JWTSecret,
+		JWTKeysDir:                 JWTKeysDir,
+		JWTActiveKeyID:             JWTActiveKeyID,
+		JWTLeeway:                  JWTLeeway,
+		DBURL:                      dbURL,
+		Mail:                       Mail,
+		Password:                   Password,
+	}, nil
+
+}
+
+func loadMailConfig() (MailConfig, error) {
+
+	mail := MailConfig{
+		Mailer:       getEnvDefault("MAILER", "log"),
+		From:         getEnvDefault("MAIL_FROM", "no-reply@chirpy.local"),
+		LogFile:      os.Getenv("MAIL_LOG_FILE"),
+		SMTPHost:     os.Getenv("SMTP_HOST"),
+		SMTPPort:     getEnvDefault("SMTP_PORT", "587"),
+		SMTPUsername: os.Getenv("SMTP_USERNAME"),
+		SMTPPassword: os.Getenv("SMTP_PASSWORD"),
+	}
+
+	switch mail.Mailer {
+	case "log":
+	case "smtp":
+		if mail.SMTPHost == "" {
+			return MailConfig{}, errors.New("SMTP_HOST not found in enviroment")
+		}
+	default:
+		return MailConfig{}, errors.New("MAILER must be either log or smtp")
+	}
+
+	return mail, nil
+}
+
+func loadPasswordConfig() (PasswordConfig, error) {
+
+	memory, err := strconv.ParseUint(getEnvDefault("ARGON2_MEMORY_KIB", "65536"), 10, 32)
+	if err != nil || memory < 8*1024 {
+		return PasswordConfig{}, errors.New("ARGON2_MEMORY_KIB must be a number of KiB, at least 8192")
+	}
+
+	iterations, err := strconv.ParseUint(getEnvDefault("ARGON2_ITERATIONS", "3"), 10, 32)
+	if err != nil || iterations < 1 {
+		return PasswordConfig{}, errors.New("ARGON2_ITERATIONS must be a positive number")
+	}
+
+	parallelism, err := strconv.ParseUint(getEnvDefault("ARGON2_PARALLELISM", "4"), 10, 8)
+	if err != nil || parallelism < 1 {
+		return PasswordConfig{}, errors.New("ARGON2_PARALLELISM must be between 1 and 255")
+	}
+
+	minLength, err := strconv.Atoi(getEnvDefault("PASSWORD_MIN_LENGTH", "8"))
+	if err != nil || minLength < 1 {
+		return PasswordConfig{}, errors.New("PASSWORD_MIN_LENGTH must be a positive number")
+	}
+
+	return PasswordConfig{
+		Argon2Memory:          uint32(memory),
+		Argon2Iterations:      uint32(iterations),
+		Argon2Parallelism:     uint8(parallelism),
+		MinLength:             minLength,
+		BreachedPasswordsFile: os.Getenv("BREACHED_PASSWORDS_FILE"),
 	}, nil
+}
 
+func getEnvDefault(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
 }
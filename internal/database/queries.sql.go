@@ -7,11 +7,498 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const activateSubscription = `-- name: ActivateSubscription :one
+INSERT INTO subscriptions (id, created_at, updated_at, user_id, plan, status, current_period_start, current_period_end)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    'active',
+    $3,
+    $4
+)
+ON CONFLICT (user_id) DO UPDATE
+SET plan = EXCLUDED.plan,
+    status = 'active',
+    current_period_start = EXCLUDED.current_period_start,
+    current_period_end = EXCLUDED.current_period_end,
+    canceled_at = NULL,
+    updated_at = NOW()
+RETURNING id, created_at, updated_at, user_id, plan, status, current_period_start, current_period_end, canceled_at
+`
+
+type ActivateSubscriptionParams struct {
+	UserID             uuid.UUID
+	Plan               string
+	CurrentPeriodStart time.Time
+	CurrentPeriodEnd   sql.NullTime
+}
+
+func (q *Queries) ActivateSubscription(ctx context.Context, arg ActivateSubscriptionParams) (Subscription, error) {
+	row := q.db.QueryRowContext(ctx, activateSubscription,
+		arg.UserID,
+		arg.Plan,
+		arg.CurrentPeriodStart,
+		arg.CurrentPeriodEnd,
+	)
+	var i Subscription
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Plan,
+		&i.Status,
+		&i.CurrentPeriodStart,
+		&i.CurrentPeriodEnd,
+		&i.CanceledAt,
+	)
+	return i, err
+}
+
+const addConversationMember = `-- name: AddConversationMember :exec
+INSERT INTO conversation_members (conversation_id, user_id, joined_at)
+VALUES ($1, $2, NOW())
+`
+
+type AddConversationMemberParams struct {
+	ConversationID uuid.UUID
+	UserID         uuid.UUID
+}
+
+func (q *Queries) AddConversationMember(ctx context.Context, arg AddConversationMemberParams) error {
+	_, err := q.db.ExecContext(ctx, addConversationMember, arg.ConversationID, arg.UserID)
+	return err
+}
+
+const addListMember = `-- name: AddListMember :exec
+WITH added AS (
+    INSERT INTO list_members (list_id, user_id, added_at)
+    VALUES ($1, $2, NOW())
+    ON CONFLICT DO NOTHING
+    RETURNING list_id
+)
+UPDATE lists
+SET member_count = member_count + 1,
+    updated_at = NOW()
+WHERE id IN (SELECT list_id FROM added)
+`
+
+type AddListMemberParams struct {
+	ListID uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) AddListMember(ctx context.Context, arg AddListMemberParams) error {
+	_, err := q.db.ExecContext(ctx, addListMember, arg.ListID, arg.UserID)
+	return err
+}
+
+const addNotificationActor = `-- name: AddNotificationActor :exec
+WITH added AS (
+    INSERT INTO notification_actors (notification_id, actor_id, created_at)
+    VALUES ($1, $2, NOW())
+    ON CONFLICT DO NOTHING
+    RETURNING notification_id
+)
+UPDATE notifications
+SET actor_count = actor_count + 1
+WHERE id IN (SELECT notification_id FROM added)
+`
+
+type AddNotificationActorParams struct {
+	NotificationID uuid.UUID
+	ActorID        uuid.UUID
+}
+
+func (q *Queries) AddNotificationActor(ctx context.Context, arg AddNotificationActorParams) error {
+	_, err := q.db.ExecContext(ctx, addNotificationActor, arg.NotificationID, arg.ActorID)
+	return err
+}
+
+const blockUser = `-- name: BlockUser :exec
+INSERT INTO user_blocks (blocker_id, blocked_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT DO NOTHING
+`
+
+type BlockUserParams struct {
+	BlockerID uuid.UUID
+	BlockedID uuid.UUID
+}
+
+func (q *Queries) BlockUser(ctx context.Context, arg BlockUserParams) error {
+	_, err := q.db.ExecContext(ctx, blockUser, arg.BlockerID, arg.BlockedID)
+	return err
+}
+
+const cancelSubscription = `-- name: CancelSubscription :one
+UPDATE subscriptions
+SET status = 'canceled',
+    canceled_at = COALESCE(canceled_at, NOW()),
+    updated_at = NOW()
+WHERE user_id = $1
+AND status <> 'expired'
+RETURNING id, created_at, updated_at, user_id, plan, status, current_period_start, current_period_end, canceled_at
+`
+
+func (q *Queries) CancelSubscription(ctx context.Context, userID uuid.UUID) (Subscription, error) {
+	row := q.db.QueryRowContext(ctx, cancelSubscription, userID)
+	var i Subscription
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Plan,
+		&i.Status,
+		&i.CurrentPeriodStart,
+		&i.CurrentPeriodEnd,
+		&i.CanceledAt,
+	)
+	return i, err
+}
+
+const castPollVote = `-- name: CastPollVote :execrows
+WITH vote AS (
+    INSERT INTO poll_votes (poll_id, user_id, option_index, created_at)
+    SELECT p.id, $1, $2, NOW()
+    FROM polls p
+    WHERE p.id = $3
+    AND p.closes_at > NOW()
+    ON CONFLICT (poll_id, user_id) DO NOTHING
+    RETURNING poll_id, option_index
+)
+UPDATE poll_options o
+SET vote_count = o.vote_count + 1
+FROM vote
+WHERE o.poll_id = vote.poll_id
+AND o.option_index = vote.option_index
+`
+
+type CastPollVoteParams struct {
+	UserID      uuid.UUID
+	OptionIndex int32
+	PollID      uuid.UUID
+}
+
+// nothing is counted when the user already voted or the poll is closed
+func (q *Queries) CastPollVote(ctx context.Context, arg CastPollVoteParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, castPollVote, arg.UserID, arg.OptionIndex, arg.PollID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const claimDueWebhookDeliveries = `-- name: ClaimDueWebhookDeliveries :many
+UPDATE webhook_deliveries
+SET next_attempt_at = $1
+WHERE id IN (
+    SELECT id FROM webhook_deliveries
+    WHERE status = 'pending'
+    AND next_attempt_at <= NOW()
+    ORDER BY next_attempt_at
+    LIMIT $2
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, created_at, endpoint_id, event_id, event_type, payload, status, attempts, next_attempt_at, last_attempt_at, response_status, last_error, delivered_at
+`
+
+type ClaimDueWebhookDeliveriesParams struct {
+	LeaseUntil time.Time
+	Limit      int32
+}
+
+// next_attempt_at is pushed to lease_until so other workers leave the
+// deliveries alone while they are being sent
+func (q *Queries) ClaimDueWebhookDeliveries(ctx context.Context, arg ClaimDueWebhookDeliveriesParams) ([]WebhookDelivery, error) {
+	rows, err := q.db.QueryContext(ctx, claimDueWebhookDeliveries, arg.LeaseUntil, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookDelivery
+	for rows.Next() {
+		var i WebhookDelivery
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.EndpointID,
+			&i.EventID,
+			&i.EventType,
+			&i.Payload,
+			&i.Status,
+			&i.Attempts,
+			&i.NextAttemptAt,
+			&i.LastAttemptAt,
+			&i.ResponseStatus,
+			&i.LastError,
+			&i.DeliveredAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const claimWebhookEventRetry = `-- name: ClaimWebhookEventRetry :one
+UPDATE webhook_events
+SET status = 'pending',
+    lease_until = $1
+WHERE provider = $2
+AND event_id = $3
+AND (
+    status = 'failed'
+    OR (status = 'ignored' AND $4::boolean)
+    OR (status = 'pending' AND lease_until < NOW())
+)
+RETURNING id, provider, event_id, event_type, payload, status, attempts, last_error, received_at, processed_at, lease_until
+`
+
+type ClaimWebhookEventRetryParams struct {
+	LeaseUntil     time.Time
+	Provider       string
+	EventID        string
+	IncludeIgnored bool
+}
+
+// takes a redelivered or replayed event for another attempt: failed events,
+// ignored ones when include_ignored is set, and pending ones whose attempt
+// outlived its lease. No rows while an attempt is running or when the event
+// needs no retry
+func (q *Queries) ClaimWebhookEventRetry(ctx context.Context, arg ClaimWebhookEventRetryParams) (WebhookEvent, error) {
+	row := q.db.QueryRowContext(ctx, claimWebhookEventRetry,
+		arg.LeaseUntil,
+		arg.Provider,
+		arg.EventID,
+		arg.IncludeIgnored,
+	)
+	var i WebhookEvent
+	err := row.Scan(
+		&i.ID,
+		&i.Provider,
+		&i.EventID,
+		&i.EventType,
+		&i.Payload,
+		&i.Status,
+		&i.Attempts,
+		&i.LastError,
+		&i.ReceivedAt,
+		&i.ProcessedAt,
+		&i.LeaseUntil,
+	)
+	return i, err
+}
+
+const clearLoginThrottle = `-- name: ClearLoginThrottle :exec
+DELETE FROM login_throttles WHERE key = $1
+`
+
+func (q *Queries) ClearLoginThrottle(ctx context.Context, key string) error {
+	_, err := q.db.ExecContext(ctx, clearLoginThrottle, key)
+	return err
+}
+
+const consumeEmailVerificationToken = `-- name: ConsumeEmailVerificationToken :one
+UPDATE email_verification_tokens
+SET used_at = NOW()
+WHERE token_hash = $1
+AND used_at IS NULL
+AND expires_at > NOW()
+RETURNING token_hash, user_id, email, created_at, expires_at, used_at
+`
+
+func (q *Queries) ConsumeEmailVerificationToken(ctx context.Context, tokenHash string) (EmailVerificationToken, error) {
+	row := q.db.QueryRowContext(ctx, consumeEmailVerificationToken, tokenHash)
+	var i EmailVerificationToken
+	err := row.Scan(
+		&i.TokenHash,
+		&i.UserID,
+		&i.Email,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+		&i.UsedAt,
+	)
+	return i, err
+}
+
+const consumeMFAChallenge = `-- name: ConsumeMFAChallenge :execrows
+UPDATE mfa_challenges
+SET used_at = NOW()
+WHERE token_hash = $1
+AND used_at IS NULL
+`
+
+func (q *Queries) ConsumeMFAChallenge(ctx context.Context, tokenHash string) (int64, error) {
+	result, err := q.db.ExecContext(ctx, consumeMFAChallenge, tokenHash)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const consumeOAuthAuthorizationCode = `-- name: ConsumeOAuthAuthorizationCode :one
+UPDATE oauth_authorization_codes
+SET used_at = NOW()
+WHERE code_hash = $1
+AND used_at IS NULL
+AND expires_at > NOW()
+RETURNING code_hash, client_id, user_id, redirect_uri, scopes, code_challenge, created_at, expires_at, used_at
+`
+
+func (q *Queries) ConsumeOAuthAuthorizationCode(ctx context.Context, codeHash string) (OauthAuthorizationCode, error) {
+	row := q.db.QueryRowContext(ctx, consumeOAuthAuthorizationCode, codeHash)
+	var i OauthAuthorizationCode
+	err := row.Scan(
+		&i.CodeHash,
+		&i.ClientID,
+		&i.UserID,
+		&i.RedirectUri,
+		pq.Array(&i.Scopes),
+		&i.CodeChallenge,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+		&i.UsedAt,
+	)
+	return i, err
+}
+
+const consumePasswordResetToken = `-- name: ConsumePasswordResetToken :one
+UPDATE password_reset_tokens
+SET used_at = NOW()
+WHERE token_hash = $1
+AND used_at IS NULL
+AND expires_at > NOW()
+RETURNING token_hash, user_id, created_at, expires_at, used_at
+`
+
+func (q *Queries) ConsumePasswordResetToken(ctx context.Context, tokenHash string) (PasswordResetToken, error) {
+	row := q.db.QueryRowContext(ctx, consumePasswordResetToken, tokenHash)
+	var i PasswordResetToken
+	err := row.Scan(
+		&i.TokenHash,
+		&i.UserID,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+		&i.UsedAt,
+	)
+	return i, err
+}
+
+const countUnreadDirectMessages = `-- name: CountUnreadDirectMessages :one
+SELECT COUNT(*)
+FROM direct_messages d
+JOIN conversation_members m ON m.conversation_id = d.conversation_id
+WHERE d.conversation_id = $1
+AND m.user_id = $2
+AND d.sender_id <> m.user_id
+AND d.sender_id NOT IN (
+    SELECT blocked_id
+    FROM user_blocks
+    WHERE blocker_id = m.user_id
+)
+AND (m.last_read_at IS NULL OR d.created_at > m.last_read_at)
+`
+
+type CountUnreadDirectMessagesParams struct {
+	ConversationID uuid.UUID
+	UserID         uuid.UUID
+}
+
+// like ListDirectMessages, the messages of users the member blocked don't count
+func (q *Queries) CountUnreadDirectMessages(ctx context.Context, arg CountUnreadDirectMessagesParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countUnreadDirectMessages, arg.ConversationID, arg.UserID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countUnreadNotifications = `-- name: CountUnreadNotifications :one
+SELECT COUNT(*)
+FROM notifications
+WHERE user_id = $1
+AND read_at IS NULL
+`
+
+func (q *Queries) CountUnreadNotifications(ctx context.Context, userID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countUnreadNotifications, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createAuditEvent = `-- name: CreateAuditEvent :exec
+INSERT INTO audit_events (id, created_at, actor_id, action, target_type, target_id, ip_address, user_agent, metadata)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7
+)
+`
+
+type CreateAuditEventParams struct {
+	ActorID    uuid.NullUUID
+	Action     string
+	TargetType string
+	TargetID   string
+	IpAddress  string
+	UserAgent  string
+	Metadata   json.RawMessage
+}
+
+func (q *Queries) CreateAuditEvent(ctx context.Context, arg CreateAuditEventParams) error {
+	_, err := q.db.ExecContext(ctx, createAuditEvent,
+		arg.ActorID,
+		arg.Action,
+		arg.TargetType,
+		arg.TargetID,
+		arg.IpAddress,
+		arg.UserAgent,
+		arg.Metadata,
+	)
+	return err
+}
+
+const createBookmark = `-- name: CreateBookmark :exec
+INSERT INTO bookmarks (user_id, chirp_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT DO NOTHING
+`
+
+type CreateBookmarkParams struct {
+	UserID  uuid.UUID
+	ChirpID uuid.UUID
+}
+
+func (q *Queries) CreateBookmark(ctx context.Context, arg CreateBookmarkParams) error {
+	_, err := q.db.ExecContext(ctx, createBookmark, arg.UserID, arg.ChirpID)
+	return err
+}
+
 const createChirp = `-- name: CreateChirp :one
 INSERT INTO chirps (id, created_at, updated_at, body, user_id)
 VALUES (
@@ -21,261 +508,3521 @@ VALUES (
     $1,
     $2
 )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id
+`
+
+type CreateChirpParams struct {
+	Body   string
+	UserID uuid.UUID
+}
+
+func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+	)
+	return i, err
+}
+
+const createChirpDraft = `-- name: CreateChirpDraft :one
+INSERT INTO chirp_drafts (id, created_at, updated_at, user_id, body, poll, publish_at)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4
+)
+RETURNING id, created_at, updated_at, user_id, body, poll, publish_at, publish_attempts, last_error
+`
+
+type CreateChirpDraftParams struct {
+	UserID    uuid.UUID
+	Body      string
+	Poll      json.RawMessage
+	PublishAt sql.NullTime
+}
+
+func (q *Queries) CreateChirpDraft(ctx context.Context, arg CreateChirpDraftParams) (ChirpDraft, error) {
+	row := q.db.QueryRowContext(ctx, createChirpDraft,
+		arg.UserID,
+		arg.Body,
+		arg.Poll,
+		arg.PublishAt,
+	)
+	var i ChirpDraft
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Body,
+		&i.Poll,
+		&i.PublishAt,
+		&i.PublishAttempts,
+		&i.LastError,
+	)
+	return i, err
+}
+
+const createConversation = `-- name: CreateConversation :one
+INSERT INTO conversations (id, created_at, updated_at, creator_id, is_group, direct_key)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3
+)
+ON CONFLICT (direct_key) DO NOTHING
+RETURNING id, created_at, updated_at, creator_id, is_group, direct_key
+`
+
+type CreateConversationParams struct {
+	CreatorID uuid.NullUUID
+	IsGroup   bool
+	DirectKey sql.NullString
+}
+
+// returns no rows when the one-to-one conversation already exists
+func (q *Queries) CreateConversation(ctx context.Context, arg CreateConversationParams) (Conversation, error) {
+	row := q.db.QueryRowContext(ctx, createConversation, arg.CreatorID, arg.IsGroup, arg.DirectKey)
+	var i Conversation
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.CreatorID,
+		&i.IsGroup,
+		&i.DirectKey,
+	)
+	return i, err
+}
+
+const createDirectMessage = `-- name: CreateDirectMessage :one
+INSERT INTO direct_messages (id, created_at, conversation_id, sender_id, body)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3
+)
+RETURNING id, created_at, conversation_id, sender_id, body
+`
+
+type CreateDirectMessageParams struct {
+	ConversationID uuid.UUID
+	SenderID       uuid.UUID
+	Body           string
+}
+
+func (q *Queries) CreateDirectMessage(ctx context.Context, arg CreateDirectMessageParams) (DirectMessage, error) {
+	row := q.db.QueryRowContext(ctx, createDirectMessage, arg.ConversationID, arg.SenderID, arg.Body)
+	var i DirectMessage
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.ConversationID,
+		&i.SenderID,
+		&i.Body,
+	)
+	return i, err
+}
+
+const createEmailVerificationToken = `-- name: CreateEmailVerificationToken :one
+INSERT INTO email_verification_tokens (token_hash, user_id, email, created_at, expires_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    NOW(),
+    NOW() + INTERVAL '24 hours'
+)
+RETURNING token_hash, user_id, email, created_at, expires_at, used_at
+`
+
+type CreateEmailVerificationTokenParams struct {
+	TokenHash string
+	UserID    uuid.UUID
+	Email     string
+}
+
+func (q *Queries) CreateEmailVerificationToken(ctx context.Context, arg CreateEmailVerificationTokenParams) (EmailVerificationToken, error) {
+	row := q.db.QueryRowContext(ctx, createEmailVerificationToken, arg.TokenHash, arg.UserID, arg.Email)
+	var i EmailVerificationToken
+	err := row.Scan(
+		&i.TokenHash,
+		&i.UserID,
+		&i.Email,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+		&i.UsedAt,
+	)
+	return i, err
+}
+
+const createList = `-- name: CreateList :one
+INSERT INTO lists (id, created_at, updated_at, owner_id, name, description, is_private)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4
+)
+RETURNING id, created_at, updated_at, owner_id, name, description, is_private, member_count, subscriber_count
+`
+
+type CreateListParams struct {
+	OwnerID     uuid.UUID
+	Name        string
+	Description string
+	IsPrivate   bool
+}
+
+func (q *Queries) CreateList(ctx context.Context, arg CreateListParams) (List, error) {
+	row := q.db.QueryRowContext(ctx, createList,
+		arg.OwnerID,
+		arg.Name,
+		arg.Description,
+		arg.IsPrivate,
+	)
+	var i List
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.OwnerID,
+		&i.Name,
+		&i.Description,
+		&i.IsPrivate,
+		&i.MemberCount,
+		&i.SubscriberCount,
+	)
+	return i, err
+}
+
+const createMFAChallenge = `-- name: CreateMFAChallenge :one
+INSERT INTO mfa_challenges (token_hash, user_id, created_at, expires_at)
+VALUES (
+    $1,
+    $2,
+    NOW(),
+    NOW() + INTERVAL '5 minutes'
+)
+RETURNING token_hash, user_id, created_at, expires_at, attempts, used_at
+`
+
+type CreateMFAChallengeParams struct {
+	TokenHash string
+	UserID    uuid.UUID
+}
+
+func (q *Queries) CreateMFAChallenge(ctx context.Context, arg CreateMFAChallengeParams) (MfaChallenge, error) {
+	row := q.db.QueryRowContext(ctx, createMFAChallenge, arg.TokenHash, arg.UserID)
+	var i MfaChallenge
+	err := row.Scan(
+		&i.TokenHash,
+		&i.UserID,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+		&i.Attempts,
+		&i.UsedAt,
+	)
+	return i, err
+}
+
+const createOAuthAuthorizationCode = `-- name: CreateOAuthAuthorizationCode :exec
+INSERT INTO oauth_authorization_codes (code_hash, client_id, user_id, redirect_uri, scopes, code_challenge, created_at, expires_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    NOW(),
+    NOW() + INTERVAL '10 minutes'
+)
+`
+
+type CreateOAuthAuthorizationCodeParams struct {
+	CodeHash      string
+	ClientID      uuid.UUID
+	UserID        uuid.UUID
+	RedirectUri   string
+	Scopes        []string
+	CodeChallenge string
+}
+
+func (q *Queries) CreateOAuthAuthorizationCode(ctx context.Context, arg CreateOAuthAuthorizationCodeParams) error {
+	_, err := q.db.ExecContext(ctx, createOAuthAuthorizationCode,
+		arg.CodeHash,
+		arg.ClientID,
+		arg.UserID,
+		arg.RedirectUri,
+		pq.Array(arg.Scopes),
+		arg.CodeChallenge,
+	)
+	return err
+}
+
+const createOAuthClient = `-- name: CreateOAuthClient :one
+INSERT INTO oauth_clients (id, created_at, updated_at, owner_id, name, client_secret_hash, redirect_uris, scopes)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+RETURNING id, created_at, updated_at, owner_id, name, client_secret_hash, redirect_uris, scopes
+`
+
+type CreateOAuthClientParams struct {
+	OwnerID          uuid.UUID
+	Name             string
+	ClientSecretHash sql.NullString
+	RedirectUris     []string
+	Scopes           []string
+}
+
+func (q *Queries) CreateOAuthClient(ctx context.Context, arg CreateOAuthClientParams) (OauthClient, error) {
+	row := q.db.QueryRowContext(ctx, createOAuthClient,
+		arg.OwnerID,
+		arg.Name,
+		arg.ClientSecretHash,
+		pq.Array(arg.RedirectUris),
+		pq.Array(arg.Scopes),
+	)
+	var i OauthClient
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.OwnerID,
+		&i.Name,
+		&i.ClientSecretHash,
+		pq.Array(&i.RedirectUris),
+		pq.Array(&i.Scopes),
+	)
+	return i, err
+}
+
+const createPasswordResetToken = `-- name: CreatePasswordResetToken :one
+INSERT INTO password_reset_tokens (token_hash, user_id, created_at, expires_at)
+VALUES (
+    $1,
+    $2,
+    NOW(),
+    NOW() + INTERVAL '1 hour'
+)
+RETURNING token_hash, user_id, created_at, expires_at, used_at
+`
+
+type CreatePasswordResetTokenParams struct {
+	TokenHash string
+	UserID    uuid.UUID
+}
+
+func (q *Queries) CreatePasswordResetToken(ctx context.Context, arg CreatePasswordResetTokenParams) (PasswordResetToken, error) {
+	row := q.db.QueryRowContext(ctx, createPasswordResetToken, arg.TokenHash, arg.UserID)
+	var i PasswordResetToken
+	err := row.Scan(
+		&i.TokenHash,
+		&i.UserID,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+		&i.UsedAt,
+	)
+	return i, err
+}
+
+const createPersonalAccessToken = `-- name: CreatePersonalAccessToken :one
+INSERT INTO personal_access_tokens (id, created_at, user_id, name, token_hash, scopes, expires_at)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+RETURNING id, created_at, user_id, name, token_hash, scopes, expires_at, last_used_at, revoked_at
+`
+
+type CreatePersonalAccessTokenParams struct {
+	UserID    uuid.UUID
+	Name      string
+	TokenHash string
+	Scopes    []string
+	ExpiresAt time.Time
+}
+
+func (q *Queries) CreatePersonalAccessToken(ctx context.Context, arg CreatePersonalAccessTokenParams) (PersonalAccessToken, error) {
+	row := q.db.QueryRowContext(ctx, createPersonalAccessToken,
+		arg.UserID,
+		arg.Name,
+		arg.TokenHash,
+		pq.Array(arg.Scopes),
+		arg.ExpiresAt,
+	)
+	var i PersonalAccessToken
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UserID,
+		&i.Name,
+		&i.TokenHash,
+		pq.Array(&i.Scopes),
+		&i.ExpiresAt,
+		&i.LastUsedAt,
+		&i.RevokedAt,
+	)
+	return i, err
+}
+
+const createPoll = `-- name: CreatePoll :one
+INSERT INTO polls (id, chirp_id, created_at, closes_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    NOW(),
+    $2
+)
+RETURNING id, chirp_id, created_at, closes_at
+`
+
+type CreatePollParams struct {
+	ChirpID  uuid.UUID
+	ClosesAt time.Time
+}
+
+func (q *Queries) CreatePoll(ctx context.Context, arg CreatePollParams) (Poll, error) {
+	row := q.db.QueryRowContext(ctx, createPoll, arg.ChirpID, arg.ClosesAt)
+	var i Poll
+	err := row.Scan(
+		&i.ID,
+		&i.ChirpID,
+		&i.CreatedAt,
+		&i.ClosesAt,
+	)
+	return i, err
+}
+
+const createPollOption = `-- name: CreatePollOption :exec
+INSERT INTO poll_options (poll_id, option_index, text)
+VALUES ($1, $2, $3)
+`
+
+type CreatePollOptionParams struct {
+	PollID      uuid.UUID
+	OptionIndex int32
+	Text        string
+}
+
+func (q *Queries) CreatePollOption(ctx context.Context, arg CreatePollOptionParams) error {
+	_, err := q.db.ExecContext(ctx, createPollOption, arg.PollID, arg.OptionIndex, arg.Text)
+	return err
+}
+
+const createTOTPRecoveryCode = `-- name: CreateTOTPRecoveryCode :exec
+INSERT INTO totp_recovery_codes (id, user_id, code_hash, created_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    NOW()
+)
+`
+
+type CreateTOTPRecoveryCodeParams struct {
+	UserID   uuid.UUID
+	CodeHash string
+}
+
+func (q *Queries) CreateTOTPRecoveryCode(ctx context.Context, arg CreateTOTPRecoveryCodeParams) error {
+	_, err := q.db.ExecContext(ctx, createTOTPRecoveryCode, arg.UserID, arg.CodeHash)
+	return err
+}
+
+const createUser = `-- name: CreateUser :one
+INSERT INTO users (id, created_at, updated_at, email, hashed_password,is_chirpy_red)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    FALSE
+)
+RETURNING id,created_at,updated_at,email,is_chirpy_red
+`
+
+type CreateUserParams struct {
+	Email          string
+	HashedPassword string
+}
+
+type CreateUserRow struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Email       string
+	IsChirpyRed bool
+}
+
+func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (CreateUserRow, error) {
+	row := q.db.QueryRowContext(ctx, createUser, arg.Email, arg.HashedPassword)
+	var i CreateUserRow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.IsChirpyRed,
+	)
+	return i, err
+}
+
+const createWebhookEndpoint = `-- name: CreateWebhookEndpoint :one
+INSERT INTO webhook_endpoints (id, created_at, updated_at, owner_id, oauth_client_id, url, events, secret)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+RETURNING id, created_at, updated_at, owner_id, oauth_client_id, url, events, secret
+`
+
+type CreateWebhookEndpointParams struct {
+	OwnerID       uuid.UUID
+	OauthClientID uuid.NullUUID
+	Url           string
+	Events        []string
+	Secret        string
+}
+
+func (q *Queries) CreateWebhookEndpoint(ctx context.Context, arg CreateWebhookEndpointParams) (WebhookEndpoint, error) {
+	row := q.db.QueryRowContext(ctx, createWebhookEndpoint,
+		arg.OwnerID,
+		arg.OauthClientID,
+		arg.Url,
+		pq.Array(arg.Events),
+		arg.Secret,
+	)
+	var i WebhookEndpoint
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.OwnerID,
+		&i.OauthClientID,
+		&i.Url,
+		pq.Array(&i.Events),
+		&i.Secret,
+	)
+	return i, err
+}
+
+const createWebhookEvent = `-- name: CreateWebhookEvent :one
+INSERT INTO webhook_events (id, provider, event_id, event_type, payload, received_at, lease_until)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    $4,
+    NOW(),
+    $5
+)
+ON CONFLICT (provider, event_id) DO NOTHING
+RETURNING id, provider, event_id, event_type, payload, status, attempts, last_error, received_at, processed_at, lease_until
+`
+
+type CreateWebhookEventParams struct {
+	Provider   string
+	EventID    string
+	EventType  string
+	Payload    json.RawMessage
+	LeaseUntil time.Time
+}
+
+// returns no rows when the provider already delivered this event
+func (q *Queries) CreateWebhookEvent(ctx context.Context, arg CreateWebhookEventParams) (WebhookEvent, error) {
+	row := q.db.QueryRowContext(ctx, createWebhookEvent,
+		arg.Provider,
+		arg.EventID,
+		arg.EventType,
+		arg.Payload,
+		arg.LeaseUntil,
+	)
+	var i WebhookEvent
+	err := row.Scan(
+		&i.ID,
+		&i.Provider,
+		&i.EventID,
+		&i.EventType,
+		&i.Payload,
+		&i.Status,
+		&i.Attempts,
+		&i.LastError,
+		&i.ReceivedAt,
+		&i.ProcessedAt,
+		&i.LeaseUntil,
+	)
+	return i, err
+}
+
+const deferScheduledChirp = `-- name: DeferScheduledChirp :exec
+UPDATE chirp_drafts
+SET publish_attempts = publish_attempts + 1,
+    last_error = $1,
+    publish_at = CASE
+        WHEN publish_attempts + 1 >= $2::integer THEN NULL
+        ELSE $3::timestamptz
+    END,
+    updated_at = NOW()
+WHERE id = $4
+`
+
+type DeferScheduledChirpParams struct {
+	LastError   string
+	MaxAttempts int32
+	RetryAt     time.Time
+	ID          uuid.UUID
+}
+
+// publishing failed: the chirp is tried again at retry_at, or goes back to
+// being a draft once it has failed max_attempts times. A max_attempts of 0
+// sends it back right away
+func (q *Queries) DeferScheduledChirp(ctx context.Context, arg DeferScheduledChirpParams) error {
+	_, err := q.db.ExecContext(ctx, deferScheduledChirp,
+		arg.LastError,
+		arg.MaxAttempts,
+		arg.RetryAt,
+		arg.ID,
+	)
+	return err
+}
+
+const deleteAllUsers = `-- name: DeleteAllUsers :exec
+DELETE FROM users
+`
+
+func (q *Queries) DeleteAllUsers(ctx context.Context) error {
+	_, err := q.db.ExecContext(ctx, deleteAllUsers)
+	return err
+}
+
+const deleteBookmark = `-- name: DeleteBookmark :execrows
+DELETE FROM bookmarks
+WHERE user_id = $1
+AND chirp_id = $2
+`
+
+type DeleteBookmarkParams struct {
+	UserID  uuid.UUID
+	ChirpID uuid.UUID
+}
+
+func (q *Queries) DeleteBookmark(ctx context.Context, arg DeleteBookmarkParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteBookmark, arg.UserID, arg.ChirpID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteChirp = `-- name: DeleteChirp :exec
+DELETE FROM chirps
+WHERE id = $1 AND user_id = $2
+`
+
+type DeleteChirpParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) DeleteChirp(ctx context.Context, arg DeleteChirpParams) error {
+	_, err := q.db.ExecContext(ctx, deleteChirp, arg.ID, arg.UserID)
+	return err
+}
+
+const deleteChirpDraft = `-- name: DeleteChirpDraft :execrows
+DELETE FROM chirp_drafts
+WHERE id = $1
+AND user_id = $2
+`
+
+type DeleteChirpDraftParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) DeleteChirpDraft(ctx context.Context, arg DeleteChirpDraftParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteChirpDraft, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteExpiredWebhookSignatures = `-- name: DeleteExpiredWebhookSignatures :exec
+DELETE FROM webhook_signatures WHERE expires_at < NOW()
+`
+
+func (q *Queries) DeleteExpiredWebhookSignatures(ctx context.Context) error {
+	_, err := q.db.ExecContext(ctx, deleteExpiredWebhookSignatures)
+	return err
+}
+
+const deleteList = `-- name: DeleteList :execrows
+DELETE FROM lists
+WHERE id = $1
+AND owner_id = $2
+`
+
+type DeleteListParams struct {
+	ID      uuid.UUID
+	OwnerID uuid.UUID
+}
+
+func (q *Queries) DeleteList(ctx context.Context, arg DeleteListParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteList, arg.ID, arg.OwnerID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteOAuthClient = `-- name: DeleteOAuthClient :execrows
+DELETE FROM oauth_clients
+WHERE id = $1
+AND owner_id = $2
+`
+
+type DeleteOAuthClientParams struct {
+	ID      uuid.UUID
+	OwnerID uuid.UUID
+}
+
+func (q *Queries) DeleteOAuthClient(ctx context.Context, arg DeleteOAuthClientParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteOAuthClient, arg.ID, arg.OwnerID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteTOTPRecoveryCodes = `-- name: DeleteTOTPRecoveryCodes :exec
+DELETE FROM totp_recovery_codes WHERE user_id = $1
+`
+
+func (q *Queries) DeleteTOTPRecoveryCodes(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteTOTPRecoveryCodes, userID)
+	return err
+}
+
+const deleteUserTOTP = `-- name: DeleteUserTOTP :exec
+DELETE FROM user_totp WHERE user_id = $1
+`
+
+func (q *Queries) DeleteUserTOTP(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteUserTOTP, userID)
+	return err
+}
+
+const deleteWebhookEndpoint = `-- name: DeleteWebhookEndpoint :execrows
+DELETE FROM webhook_endpoints
+WHERE id = $1
+AND owner_id = $2
+`
+
+type DeleteWebhookEndpointParams struct {
+	ID      uuid.UUID
+	OwnerID uuid.UUID
+}
+
+func (q *Queries) DeleteWebhookEndpoint(ctx context.Context, arg DeleteWebhookEndpointParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteWebhookEndpoint, arg.ID, arg.OwnerID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const enableUserTOTP = `-- name: EnableUserTOTP :execrows
+UPDATE user_totp
+SET enabled_at = NOW(),
+    last_step = $2,
+    updated_at = NOW()
+WHERE user_id = $1
+AND enabled_at IS NULL
+`
+
+type EnableUserTOTPParams struct {
+	UserID   uuid.UUID
+	LastStep int64
+}
+
+func (q *Queries) EnableUserTOTP(ctx context.Context, arg EnableUserTOTPParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, enableUserTOTP, arg.UserID, arg.LastStep)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const endSubscription = `-- name: EndSubscription :one
+UPDATE subscriptions
+SET status = 'expired',
+    current_period_end = LEAST(current_period_end, NOW()),
+    updated_at = NOW()
+WHERE user_id = $1
+RETURNING id, created_at, updated_at, user_id, plan, status, current_period_start, current_period_end, canceled_at
+`
+
+func (q *Queries) EndSubscription(ctx context.Context, userID uuid.UUID) (Subscription, error) {
+	row := q.db.QueryRowContext(ctx, endSubscription, userID)
+	var i Subscription
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Plan,
+		&i.Status,
+		&i.CurrentPeriodStart,
+		&i.CurrentPeriodEnd,
+		&i.CanceledAt,
+	)
+	return i, err
+}
+
+const enqueueWebhookDelivery = `-- name: EnqueueWebhookDelivery :exec
+INSERT INTO webhook_deliveries (id, created_at, endpoint_id, event_id, event_type, payload, next_attempt_at)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    NOW()
+)
+`
+
+type EnqueueWebhookDeliveryParams struct {
+	EndpointID uuid.UUID
+	EventID    uuid.UUID
+	EventType  string
+	Payload    json.RawMessage
+}
+
+func (q *Queries) EnqueueWebhookDelivery(ctx context.Context, arg EnqueueWebhookDeliveryParams) error {
+	_, err := q.db.ExecContext(ctx, enqueueWebhookDelivery,
+		arg.EndpointID,
+		arg.EventID,
+		arg.EventType,
+		arg.Payload,
+	)
+	return err
+}
+
+const expireSubscriptions = `-- name: ExpireSubscriptions :many
+WITH expired AS (
+    UPDATE subscriptions
+    SET status = 'expired',
+        updated_at = NOW()
+    WHERE status <> 'expired'
+    AND current_period_end IS NOT NULL
+    AND current_period_end <= NOW()
+    RETURNING user_id
+)
+UPDATE users
+SET is_chirpy_red = FALSE,
+    updated_at = NOW()
+FROM expired
+WHERE users.id = expired.user_id
+RETURNING users.id
+`
+
+// ends every subscription whose period is over and takes Chirpy Red away.
+// Open-ended subscriptions only end on an event from Polka
+func (q *Queries) ExpireSubscriptions(ctx context.Context) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, expireSubscriptions)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const finishWebhookEvent = `-- name: FinishWebhookEvent :one
+UPDATE webhook_events
+SET status = $2,
+    last_error = $3,
+    attempts = attempts + 1,
+    processed_at = NOW()
+WHERE id = $1
+RETURNING id, provider, event_id, event_type, payload, status, attempts, last_error, received_at, processed_at, lease_until
+`
+
+type FinishWebhookEventParams struct {
+	ID        uuid.UUID
+	Status    string
+	LastError string
+}
+
+func (q *Queries) FinishWebhookEvent(ctx context.Context, arg FinishWebhookEventParams) (WebhookEvent, error) {
+	row := q.db.QueryRowContext(ctx, finishWebhookEvent, arg.ID, arg.Status, arg.LastError)
+	var i WebhookEvent
+	err := row.Scan(
+		&i.ID,
+		&i.Provider,
+		&i.EventID,
+		&i.EventType,
+		&i.Payload,
+		&i.Status,
+		&i.Attempts,
+		&i.LastError,
+		&i.ReceivedAt,
+		&i.ProcessedAt,
+		&i.LeaseUntil,
+	)
+	return i, err
+}
+
+const getActiveMFAChallenge = `-- name: GetActiveMFAChallenge :one
+SELECT token_hash, user_id, created_at, expires_at, attempts, used_at FROM mfa_challenges
+WHERE token_hash = $1
+AND used_at IS NULL
+AND expires_at > NOW()
+AND attempts < 5
+`
+
+func (q *Queries) GetActiveMFAChallenge(ctx context.Context, tokenHash string) (MfaChallenge, error) {
+	row := q.db.QueryRowContext(ctx, getActiveMFAChallenge, tokenHash)
+	var i MfaChallenge
+	err := row.Scan(
+		&i.TokenHash,
+		&i.UserID,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+		&i.Attempts,
+		&i.UsedAt,
+	)
+	return i, err
+}
+
+const getActivePersonalAccessToken = `-- name: GetActivePersonalAccessToken :one
+SELECT id, created_at, user_id, name, token_hash, scopes, expires_at, last_used_at, revoked_at FROM personal_access_tokens
+WHERE token_hash = $1
+AND revoked_at IS NULL
+AND expires_at > NOW()
+`
+
+func (q *Queries) GetActivePersonalAccessToken(ctx context.Context, tokenHash string) (PersonalAccessToken, error) {
+	row := q.db.QueryRowContext(ctx, getActivePersonalAccessToken, tokenHash)
+	var i PersonalAccessToken
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UserID,
+		&i.Name,
+		&i.TokenHash,
+		pq.Array(&i.Scopes),
+		&i.ExpiresAt,
+		&i.LastUsedAt,
+		&i.RevokedAt,
+	)
+	return i, err
+}
+
+const getAllChirps = `-- name: GetAllChirps :many
+SELECT id, created_at, updated_at, body, user_id FROM chirps ORDER BY created_at ASC
+`
+
+func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getAllChirps)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpByID = `-- name: GetChirpByID :one
+SELECT id, created_at, updated_at, body, user_id FROM chirps WHERE id = $1
+`
+
+func (q *Queries) GetChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirpByID, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+	)
+	return i, err
+}
+
+const getChirpDraft = `-- name: GetChirpDraft :one
+SELECT id, created_at, updated_at, user_id, body, poll, publish_at, publish_attempts, last_error
+FROM chirp_drafts
+WHERE id = $1
+AND user_id = $2
+`
+
+type GetChirpDraftParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) GetChirpDraft(ctx context.Context, arg GetChirpDraftParams) (ChirpDraft, error) {
+	row := q.db.QueryRowContext(ctx, getChirpDraft, arg.ID, arg.UserID)
+	var i ChirpDraft
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Body,
+		&i.Poll,
+		&i.PublishAt,
+		&i.PublishAttempts,
+		&i.LastError,
+	)
+	return i, err
+}
+
+const getChirpsByAuthor = `-- name: GetChirpsByAuthor :many
+SELECT id, created_at, updated_at, body, user_id
+FROM chirps
+WHERE user_id = $1
+ORDER BY created_at ASC
+`
+
+func (q *Queries) GetChirpsByAuthor(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByAuthor, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getConversationByDirectKey = `-- name: GetConversationByDirectKey :one
+SELECT id, created_at, updated_at, creator_id, is_group, direct_key
+FROM conversations
+WHERE direct_key = $1
+`
+
+func (q *Queries) GetConversationByDirectKey(ctx context.Context, directKey sql.NullString) (Conversation, error) {
+	row := q.db.QueryRowContext(ctx, getConversationByDirectKey, directKey)
+	var i Conversation
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.CreatorID,
+		&i.IsGroup,
+		&i.DirectKey,
+	)
+	return i, err
+}
+
+const getConversationForMember = `-- name: GetConversationForMember :one
+SELECT id, created_at, updated_at, creator_id, is_group, direct_key
+FROM conversations
+WHERE id = $1
+AND id IN (
+    SELECT conversation_id
+    FROM conversation_members
+    WHERE user_id = $2
+)
+`
+
+type GetConversationForMemberParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) GetConversationForMember(ctx context.Context, arg GetConversationForMemberParams) (Conversation, error) {
+	row := q.db.QueryRowContext(ctx, getConversationForMember, arg.ID, arg.UserID)
+	var i Conversation
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.CreatorID,
+		&i.IsGroup,
+		&i.DirectKey,
+	)
+	return i, err
+}
+
+const getDirectMessage = `-- name: GetDirectMessage :one
+SELECT id, created_at, conversation_id, sender_id, body
+FROM direct_messages
+WHERE id = $1
+AND conversation_id = $2
+`
+
+type GetDirectMessageParams struct {
+	ID             uuid.UUID
+	ConversationID uuid.UUID
+}
+
+func (q *Queries) GetDirectMessage(ctx context.Context, arg GetDirectMessageParams) (DirectMessage, error) {
+	row := q.db.QueryRowContext(ctx, getDirectMessage, arg.ID, arg.ConversationID)
+	var i DirectMessage
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.ConversationID,
+		&i.SenderID,
+		&i.Body,
+	)
+	return i, err
+}
+
+const getList = `-- name: GetList :one
+SELECT id, created_at, updated_at, owner_id, name, description, is_private, member_count, subscriber_count
+FROM lists
+WHERE id = $1
+`
+
+func (q *Queries) GetList(ctx context.Context, id uuid.UUID) (List, error) {
+	row := q.db.QueryRowContext(ctx, getList, id)
+	var i List
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.OwnerID,
+		&i.Name,
+		&i.Description,
+		&i.IsPrivate,
+		&i.MemberCount,
+		&i.SubscriberCount,
+	)
+	return i, err
+}
+
+const getOAuthClient = `-- name: GetOAuthClient :one
+SELECT id, created_at, updated_at, owner_id, name, client_secret_hash, redirect_uris, scopes FROM oauth_clients WHERE id = $1
+`
+
+func (q *Queries) GetOAuthClient(ctx context.Context, id uuid.UUID) (OauthClient, error) {
+	row := q.db.QueryRowContext(ctx, getOAuthClient, id)
+	var i OauthClient
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.OwnerID,
+		&i.Name,
+		&i.ClientSecretHash,
+		pq.Array(&i.RedirectUris),
+		pq.Array(&i.Scopes),
+	)
+	return i, err
+}
+
+const getPinnedChirp = `-- name: GetPinnedChirp :one
+SELECT c.id, c.created_at, c.updated_at, c.body, c.user_id
+FROM chirps c
+JOIN pinned_chirps p ON p.chirp_id = c.id
+WHERE p.user_id = $1
+`
+
+func (q *Queries) GetPinnedChirp(ctx context.Context, userID uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getPinnedChirp, userID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+	)
+	return i, err
+}
+
+const getPollByChirpID = `-- name: GetPollByChirpID :one
+SELECT id, chirp_id, created_at, closes_at
+FROM polls
+WHERE chirp_id = $1
+`
+
+func (q *Queries) GetPollByChirpID(ctx context.Context, chirpID uuid.UUID) (Poll, error) {
+	row := q.db.QueryRowContext(ctx, getPollByChirpID, chirpID)
+	var i Poll
+	err := row.Scan(
+		&i.ID,
+		&i.ChirpID,
+		&i.CreatedAt,
+		&i.ClosesAt,
+	)
+	return i, err
+}
+
+const getRefreshToken = `-- name: GetRefreshToken :one
+SELECT token_hash, created_at, updated_at, user_id, expires_at, revoked_at, family_id, rotated_at, device_name, user_agent, ip_address, session_started_at, last_used_at, client_id, scopes FROM refresh_tokens WHERE token_hash = $1
+`
+
+func (q *Queries) GetRefreshToken(ctx context.Context, tokenHash string) (RefreshToken, error) {
+	row := q.db.QueryRowContext(ctx, getRefreshToken, tokenHash)
+	var i RefreshToken
+	err := row.Scan(
+		&i.TokenHash,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+		&i.FamilyID,
+		&i.RotatedAt,
+		&i.DeviceName,
+		&i.UserAgent,
+		&i.IpAddress,
+		&i.SessionStartedAt,
+		&i.LastUsedAt,
+		&i.ClientID,
+		pq.Array(&i.Scopes),
+	)
+	return i, err
+}
+
+const getSubscriptionByUserID = `-- name: GetSubscriptionByUserID :one
+SELECT id, created_at, updated_at, user_id, plan, status, current_period_start, current_period_end, canceled_at FROM subscriptions WHERE user_id = $1
+`
+
+func (q *Queries) GetSubscriptionByUserID(ctx context.Context, userID uuid.UUID) (Subscription, error) {
+	row := q.db.QueryRowContext(ctx, getSubscriptionByUserID, userID)
+	var i Subscription
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Plan,
+		&i.Status,
+		&i.CurrentPeriodStart,
+		&i.CurrentPeriodEnd,
+		&i.CanceledAt,
+	)
+	return i, err
+}
+
+const getUserByEmail = `-- name: GetUserByEmail :one
+SELECT id, created_at, updated_at, email, hashed_password,is_chirpy_red, is_admin, email_verified_at
+FROM users
+WHERE email = $1
+`
+
+func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByEmail, email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.IsAdmin,
+		&i.EmailVerifiedAt,
+	)
+	return i, err
+}
+
+const getUserByID = `-- name: GetUserByID :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, is_admin, email_verified_at FROM users WHERE id = $1
+`
+
+func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.IsAdmin,
+		&i.EmailVerifiedAt,
+	)
+	return i, err
+}
+
+const getUserTOTP = `-- name: GetUserTOTP :one
+SELECT user_id, secret, created_at, updated_at, enabled_at, last_step FROM user_totp WHERE user_id = $1
+`
+
+func (q *Queries) GetUserTOTP(ctx context.Context, userID uuid.UUID) (UserTotp, error) {
+	row := q.db.QueryRowContext(ctx, getUserTOTP, userID)
+	var i UserTotp
+	err := row.Scan(
+		&i.UserID,
+		&i.Secret,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.EnabledAt,
+		&i.LastStep,
+	)
+	return i, err
+}
+
+const getWebhookEndpoint = `-- name: GetWebhookEndpoint :one
+SELECT id, created_at, updated_at, owner_id, oauth_client_id, url, events, secret FROM webhook_endpoints WHERE id = $1
+`
+
+func (q *Queries) GetWebhookEndpoint(ctx context.Context, id uuid.UUID) (WebhookEndpoint, error) {
+	row := q.db.QueryRowContext(ctx, getWebhookEndpoint, id)
+	var i WebhookEndpoint
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.OwnerID,
+		&i.OauthClientID,
+		&i.Url,
+		pq.Array(&i.Events),
+		&i.Secret,
+	)
+	return i, err
+}
+
+const getWebhookEvent = `-- name: GetWebhookEvent :one
+SELECT id, provider, event_id, event_type, payload, status, attempts, last_error, received_at, processed_at, lease_until FROM webhook_events WHERE id = $1
+`
+
+func (q *Queries) GetWebhookEvent(ctx context.Context, id uuid.UUID) (WebhookEvent, error) {
+	row := q.db.QueryRowContext(ctx, getWebhookEvent, id)
+	var i WebhookEvent
+	err := row.Scan(
+		&i.ID,
+		&i.Provider,
+		&i.EventID,
+		&i.EventType,
+		&i.Payload,
+		&i.Status,
+		&i.Attempts,
+		&i.LastError,
+		&i.ReceivedAt,
+		&i.ProcessedAt,
+		&i.LeaseUntil,
+	)
+	return i, err
+}
+
+const getWebhookEventByProviderID = `-- name: GetWebhookEventByProviderID :one
+SELECT id, provider, event_id, event_type, payload, status, attempts, last_error, received_at, processed_at, lease_until FROM webhook_events
+WHERE provider = $1
+AND event_id = $2
+`
+
+type GetWebhookEventByProviderIDParams struct {
+	Provider string
+	EventID  string
+}
+
+func (q *Queries) GetWebhookEventByProviderID(ctx context.Context, arg GetWebhookEventByProviderIDParams) (WebhookEvent, error) {
+	row := q.db.QueryRowContext(ctx, getWebhookEventByProviderID, arg.Provider, arg.EventID)
+	var i WebhookEvent
+	err := row.Scan(
+		&i.ID,
+		&i.Provider,
+		&i.EventID,
+		&i.EventType,
+		&i.Payload,
+		&i.Status,
+		&i.Attempts,
+		&i.LastError,
+		&i.ReceivedAt,
+		&i.ProcessedAt,
+		&i.LeaseUntil,
+	)
+	return i, err
+}
+
+const hasBlockBetween = `-- name: HasBlockBetween :one
+SELECT EXISTS (
+    SELECT 1
+    FROM user_blocks
+    WHERE (blocker_id = $1 AND blocked_id = ANY($2::uuid[]))
+    OR (blocked_id = $1 AND blocker_id = ANY($2::uuid[]))
+)
+`
+
+type HasBlockBetweenParams struct {
+	UserID  uuid.UUID
+	UserIds []uuid.UUID
+}
+
+// whether user_id blocked any of user_ids or was blocked by one of them
+func (q *Queries) HasBlockBetween(ctx context.Context, arg HasBlockBetweenParams) (bool, error) {
+	row := q.db.QueryRowContext(ctx, hasBlockBetween, arg.UserID, pq.Array(arg.UserIds))
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+const incrementMFAChallengeAttempts = `-- name: IncrementMFAChallengeAttempts :exec
+UPDATE mfa_challenges
+SET attempts = attempts + 1
+WHERE token_hash = $1
+`
+
+func (q *Queries) IncrementMFAChallengeAttempts(ctx context.Context, tokenHash string) error {
+	_, err := q.db.ExecContext(ctx, incrementMFAChallengeAttempts, tokenHash)
+	return err
+}
+
+const invalidatePasswordResetTokens = `-- name: InvalidatePasswordResetTokens :exec
+UPDATE password_reset_tokens
+SET used_at = NOW()
+WHERE user_id = $1
+AND used_at IS NULL
+`
+
+func (q *Queries) InvalidatePasswordResetTokens(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, invalidatePasswordResetTokens, userID)
+	return err
+}
+
+const isNotificationEnabled = `-- name: IsNotificationEnabled :one
+SELECT COALESCE((
+    SELECT enabled
+    FROM notification_preferences
+    WHERE user_id = $1
+    AND type = $2
+), TRUE)::boolean AS enabled
+`
+
+type IsNotificationEnabledParams struct {
+	UserID uuid.UUID
+	Type   string
+}
+
+func (q *Queries) IsNotificationEnabled(ctx context.Context, arg IsNotificationEnabledParams) (bool, error) {
+	row := q.db.QueryRowContext(ctx, isNotificationEnabled, arg.UserID, arg.Type)
+	var enabled bool
+	err := row.Scan(&enabled)
+	return enabled, err
+}
+
+const listActiveLoginLockouts = `-- name: ListActiveLoginLockouts :many
+SELECT key, failures, last_failure_at, locked_until FROM login_throttles
+WHERE key = ANY($1::text[])
+AND locked_until > NOW()
+`
+
+func (q *Queries) ListActiveLoginLockouts(ctx context.Context, keys []string) ([]LoginThrottle, error) {
+	rows, err := q.db.QueryContext(ctx, listActiveLoginLockouts, pq.Array(keys))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []LoginThrottle
+	for rows.Next() {
+		var i LoginThrottle
+		if err := rows.Scan(
+			&i.Key,
+			&i.Failures,
+			&i.LastFailureAt,
+			&i.LockedUntil,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listActiveSessions = `-- name: ListActiveSessions :many
+SELECT family_id, device_name, user_agent, ip_address, session_started_at, last_used_at, expires_at
+FROM refresh_tokens
+WHERE user_id = $1
+AND revoked_at IS NULL
+AND rotated_at IS NULL
+AND expires_at > NOW()
+ORDER BY last_used_at DESC
+`
+
+type ListActiveSessionsRow struct {
+	FamilyID         uuid.UUID
+	DeviceName       string
+	UserAgent        string
+	IpAddress        string
+	SessionStartedAt time.Time
+	LastUsedAt       time.Time
+	ExpiresAt        time.Time
+}
+
+func (q *Queries) ListActiveSessions(ctx context.Context, userID uuid.UUID) ([]ListActiveSessionsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listActiveSessions, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListActiveSessionsRow
+	for rows.Next() {
+		var i ListActiveSessionsRow
+		if err := rows.Scan(
+			&i.FamilyID,
+			&i.DeviceName,
+			&i.UserAgent,
+			&i.IpAddress,
+			&i.SessionStartedAt,
+			&i.LastUsedAt,
+			&i.ExpiresAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAuditEvents = `-- name: ListAuditEvents :many
+SELECT id, created_at, actor_id, action, target_type, target_id, ip_address, user_agent, metadata
+FROM audit_events
+WHERE ($1::uuid IS NULL OR actor_id = $1)
+AND ($2::text IS NULL OR action = $2)
+AND ($3::text IS NULL OR target_id = $3)
+AND ($4::timestamptz IS NULL OR created_at >= $4)
+AND ($5::timestamptz IS NULL OR created_at < $5)
+ORDER BY created_at DESC
+LIMIT $6
+`
+
+type ListAuditEventsParams struct {
+	ActorID  uuid.NullUUID
+	Action   sql.NullString
+	TargetID sql.NullString
+	Since    sql.NullTime
+	Until    sql.NullTime
+	Limit    int32
+}
+
+func (q *Queries) ListAuditEvents(ctx context.Context, arg ListAuditEventsParams) ([]AuditEvent, error) {
+	rows, err := q.db.QueryContext(ctx, listAuditEvents,
+		arg.ActorID,
+		arg.Action,
+		arg.TargetID,
+		arg.Since,
+		arg.Until,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []AuditEvent
+	for rows.Next() {
+		var i AuditEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.ActorID,
+			&i.Action,
+			&i.TargetType,
+			&i.TargetID,
+			&i.IpAddress,
+			&i.UserAgent,
+			&i.Metadata,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listBlockedUsers = `-- name: ListBlockedUsers :many
+SELECT blocker_id, blocked_id, created_at
+FROM user_blocks
+WHERE blocker_id = $1
+ORDER BY created_at DESC
+`
+
+func (q *Queries) ListBlockedUsers(ctx context.Context, blockerID uuid.UUID) ([]UserBlock, error) {
+	rows, err := q.db.QueryContext(ctx, listBlockedUsers, blockerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []UserBlock
+	for rows.Next() {
+		var i UserBlock
+		if err := rows.Scan(&i.BlockerID, &i.BlockedID, &i.CreatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listBookmarks = `-- name: ListBookmarks :many
+SELECT b.chirp_id, b.created_at,
+    c.created_at AS chirp_created_at,
+    c.updated_at AS chirp_updated_at,
+    c.body,
+    c.user_id
+FROM bookmarks b
+LEFT JOIN chirps c ON c.id = b.chirp_id
+WHERE b.user_id = $1
+AND (
+    $2::timestamptz IS NULL
+    OR (b.created_at, b.chirp_id) < ($2, $3::uuid)
+)
+ORDER BY b.created_at DESC, b.chirp_id DESC
+LIMIT $4
+`
+
+type ListBookmarksParams struct {
+	UserID          uuid.UUID
+	CursorCreatedAt sql.NullTime
+	CursorChirpID   uuid.NullUUID
+	Limit           int32
+}
+
+type ListBookmarksRow struct {
+	ChirpID        uuid.UUID
+	CreatedAt      time.Time
+	ChirpCreatedAt sql.NullTime
+	ChirpUpdatedAt sql.NullTime
+	Body           sql.NullString
+	UserID         uuid.NullUUID
+}
+
+// newest first, after the (created_at, chirp_id) cursor. The chirp columns
+// are NULL when it was deleted.
+func (q *Queries) ListBookmarks(ctx context.Context, arg ListBookmarksParams) ([]ListBookmarksRow, error) {
+	rows, err := q.db.QueryContext(ctx, listBookmarks,
+		arg.UserID,
+		arg.CursorCreatedAt,
+		arg.CursorChirpID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListBookmarksRow
+	for rows.Next() {
+		var i ListBookmarksRow
+		if err := rows.Scan(
+			&i.ChirpID,
+			&i.CreatedAt,
+			&i.ChirpCreatedAt,
+			&i.ChirpUpdatedAt,
+			&i.Body,
+			&i.UserID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChirpDrafts = `-- name: ListChirpDrafts :many
+SELECT id, created_at, updated_at, user_id, body, poll, publish_at, publish_attempts, last_error
+FROM chirp_drafts
+WHERE user_id = $1
+AND ($2::boolean IS NULL OR (publish_at IS NOT NULL) = $2)
+ORDER BY updated_at DESC
+`
+
+type ListChirpDraftsParams struct {
+	UserID    uuid.UUID
+	Scheduled sql.NullBool
+}
+
+// without scheduled both drafts and scheduled chirps are listed
+func (q *Queries) ListChirpDrafts(ctx context.Context, arg ListChirpDraftsParams) ([]ChirpDraft, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpDrafts, arg.UserID, arg.Scheduled)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ChirpDraft
+	for rows.Next() {
+		var i ChirpDraft
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.Body,
+			&i.Poll,
+			&i.PublishAt,
+			&i.PublishAttempts,
+			&i.LastError,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listConversationMembers = `-- name: ListConversationMembers :many
+SELECT conversation_id, user_id, joined_at, last_read_at
+FROM conversation_members
+WHERE conversation_id = ANY($1::uuid[])
+ORDER BY conversation_id, joined_at
+`
+
+func (q *Queries) ListConversationMembers(ctx context.Context, conversationIds []uuid.UUID) ([]ConversationMember, error) {
+	rows, err := q.db.QueryContext(ctx, listConversationMembers, pq.Array(conversationIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ConversationMember
+	for rows.Next() {
+		var i ConversationMember
+		if err := rows.Scan(
+			&i.ConversationID,
+			&i.UserID,
+			&i.JoinedAt,
+			&i.LastReadAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listConversationsForUser = `-- name: ListConversationsForUser :many
+SELECT c.id, c.created_at, c.updated_at, c.creator_id, c.is_group, c.direct_key,
+    (
+        SELECT COUNT(*)
+        FROM direct_messages d
+        WHERE d.conversation_id = c.id
+        AND d.sender_id <> m.user_id
+        AND d.sender_id NOT IN (
+            SELECT blocked_id
+            FROM user_blocks
+            WHERE blocker_id = m.user_id
+        )
+        AND (m.last_read_at IS NULL OR d.created_at > m.last_read_at)
+    ) AS unread_count
+FROM conversations c
+JOIN conversation_members m ON m.conversation_id = c.id
+WHERE m.user_id = $1
+AND ($2::timestamptz IS NULL OR c.updated_at < $2)
+ORDER BY c.updated_at DESC
+LIMIT $3
+`
+
+type ListConversationsForUserParams struct {
+	UserID uuid.UUID
+	Before sql.NullTime
+	Limit  int32
+}
+
+type ListConversationsForUserRow struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	CreatorID   uuid.NullUUID
+	IsGroup     bool
+	DirectKey   sql.NullString
+	UnreadCount int64
+}
+
+func (q *Queries) ListConversationsForUser(ctx context.Context, arg ListConversationsForUserParams) ([]ListConversationsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, listConversationsForUser, arg.UserID, arg.Before, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListConversationsForUserRow
+	for rows.Next() {
+		var i ListConversationsForUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.CreatorID,
+			&i.IsGroup,
+			&i.DirectKey,
+			&i.UnreadCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listDirectMessages = `-- name: ListDirectMessages :many
+SELECT id, created_at, conversation_id, sender_id, body
+FROM direct_messages
+WHERE conversation_id = $1
+AND sender_id NOT IN (
+    SELECT blocked_id
+    FROM user_blocks
+    WHERE blocker_id = $2
+)
+AND ($3::timestamptz IS NULL OR created_at < $3)
+ORDER BY created_at DESC
+LIMIT $4
+`
+
+type ListDirectMessagesParams struct {
+	ConversationID uuid.UUID
+	ViewerID       uuid.UUID
+	Before         sql.NullTime
+	Limit          int32
+}
+
+// newest first, without the messages of users the viewer blocked
+func (q *Queries) ListDirectMessages(ctx context.Context, arg ListDirectMessagesParams) ([]DirectMessage, error) {
+	rows, err := q.db.QueryContext(ctx, listDirectMessages,
+		arg.ConversationID,
+		arg.ViewerID,
+		arg.Before,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []DirectMessage
+	for rows.Next() {
+		var i DirectMessage
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.ConversationID,
+			&i.SenderID,
+			&i.Body,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listListMembers = `-- name: ListListMembers :many
+SELECT list_id, user_id, added_at
+FROM list_members
+WHERE list_id = $1
+ORDER BY added_at DESC
+`
+
+func (q *Queries) ListListMembers(ctx context.Context, listID uuid.UUID) ([]ListMember, error) {
+	rows, err := q.db.QueryContext(ctx, listListMembers, listID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListMember
+	for rows.Next() {
+		var i ListMember
+		if err := rows.Scan(&i.ListID, &i.UserID, &i.AddedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listListsByOwner = `-- name: ListListsByOwner :many
+SELECT id, created_at, updated_at, owner_id, name, description, is_private, member_count, subscriber_count
+FROM lists
+WHERE owner_id = $1
+AND ($2::boolean OR NOT is_private)
+ORDER BY created_at DESC
+`
+
+type ListListsByOwnerParams struct {
+	OwnerID        uuid.UUID
+	IncludePrivate bool
+}
+
+func (q *Queries) ListListsByOwner(ctx context.Context, arg ListListsByOwnerParams) ([]List, error) {
+	rows, err := q.db.QueryContext(ctx, listListsByOwner, arg.OwnerID, arg.IncludePrivate)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []List
+	for rows.Next() {
+		var i List
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.OwnerID,
+			&i.Name,
+			&i.Description,
+			&i.IsPrivate,
+			&i.MemberCount,
+			&i.SubscriberCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listNotificationActors = `-- name: ListNotificationActors :many
+SELECT notification_id, actor_id
+FROM (
+    SELECT notification_id, actor_id, created_at,
+        ROW_NUMBER() OVER (PARTITION BY notification_id ORDER BY created_at DESC) AS actor_rank
+    FROM notification_actors
+    WHERE notification_id = ANY($1::uuid[])
+) latest
+WHERE actor_rank <= 3
+ORDER BY notification_id, created_at DESC
+`
+
+type ListNotificationActorsRow struct {
+	NotificationID uuid.UUID
+	ActorID        uuid.UUID
+}
+
+// the latest three actors of each notification
+func (q *Queries) ListNotificationActors(ctx context.Context, notificationIds []uuid.UUID) ([]ListNotificationActorsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listNotificationActors, pq.Array(notificationIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListNotificationActorsRow
+	for rows.Next() {
+		var i ListNotificationActorsRow
+		if err := rows.Scan(&i.NotificationID, &i.ActorID); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listNotificationPreferences = `-- name: ListNotificationPreferences :many
+SELECT user_id, type, enabled, updated_at
+FROM notification_preferences
+WHERE user_id = $1
+ORDER BY type
+`
+
+func (q *Queries) ListNotificationPreferences(ctx context.Context, userID uuid.UUID) ([]NotificationPreference, error) {
+	rows, err := q.db.QueryContext(ctx, listNotificationPreferences, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []NotificationPreference
+	for rows.Next() {
+		var i NotificationPreference
+		if err := rows.Scan(
+			&i.UserID,
+			&i.Type,
+			&i.Enabled,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listNotifications = `-- name: ListNotifications :many
+SELECT id, created_at, updated_at, user_id, type, chirp_id, group_key, data, actor_count, read_at
+FROM notifications
+WHERE user_id = $1
+AND (NOT $2::boolean OR read_at IS NULL)
+AND ($3::timestamptz IS NULL OR updated_at < $3)
+ORDER BY updated_at DESC
+LIMIT $4
+`
+
+type ListNotificationsParams struct {
+	UserID     uuid.UUID
+	UnreadOnly bool
+	Before     sql.NullTime
+	Limit      int32
+}
+
+func (q *Queries) ListNotifications(ctx context.Context, arg ListNotificationsParams) ([]Notification, error) {
+	rows, err := q.db.QueryContext(ctx, listNotifications,
+		arg.UserID,
+		arg.UnreadOnly,
+		arg.Before,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Notification
+	for rows.Next() {
+		var i Notification
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.Type,
+			&i.ChirpID,
+			&i.GroupKey,
+			&i.Data,
+			&i.ActorCount,
+			&i.ReadAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listOAuthClientsByOwner = `-- name: ListOAuthClientsByOwner :many
+SELECT id, created_at, updated_at, owner_id, name, client_secret_hash, redirect_uris, scopes FROM oauth_clients
+WHERE owner_id = $1
+ORDER BY created_at DESC
+`
+
+func (q *Queries) ListOAuthClientsByOwner(ctx context.Context, ownerID uuid.UUID) ([]OauthClient, error) {
+	rows, err := q.db.QueryContext(ctx, listOAuthClientsByOwner, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []OauthClient
+	for rows.Next() {
+		var i OauthClient
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.OwnerID,
+			&i.Name,
+			&i.ClientSecretHash,
+			pq.Array(&i.RedirectUris),
+			pq.Array(&i.Scopes),
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPersonalAccessTokens = `-- name: ListPersonalAccessTokens :many
+SELECT id, created_at, user_id, name, token_hash, scopes, expires_at, last_used_at, revoked_at FROM personal_access_tokens
+WHERE user_id = $1
+AND revoked_at IS NULL
+AND expires_at > NOW()
+ORDER BY created_at DESC
+`
+
+func (q *Queries) ListPersonalAccessTokens(ctx context.Context, userID uuid.UUID) ([]PersonalAccessToken, error) {
+	rows, err := q.db.QueryContext(ctx, listPersonalAccessTokens, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PersonalAccessToken
+	for rows.Next() {
+		var i PersonalAccessToken
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UserID,
+			&i.Name,
+			&i.TokenHash,
+			pq.Array(&i.Scopes),
+			&i.ExpiresAt,
+			&i.LastUsedAt,
+			&i.RevokedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPollOptions = `-- name: ListPollOptions :many
+SELECT poll_id, option_index, text, vote_count
+FROM poll_options
+WHERE poll_id = ANY($1::uuid[])
+ORDER BY poll_id, option_index
+`
+
+func (q *Queries) ListPollOptions(ctx context.Context, pollIds []uuid.UUID) ([]PollOption, error) {
+	rows, err := q.db.QueryContext(ctx, listPollOptions, pq.Array(pollIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PollOption
+	for rows.Next() {
+		var i PollOption
+		if err := rows.Scan(
+			&i.PollID,
+			&i.OptionIndex,
+			&i.Text,
+			&i.VoteCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPollVotesByUser = `-- name: ListPollVotesByUser :many
+SELECT poll_id, user_id, option_index, created_at
+FROM poll_votes
+WHERE user_id = $1
+AND poll_id = ANY($2::uuid[])
+`
+
+type ListPollVotesByUserParams struct {
+	UserID  uuid.UUID
+	PollIds []uuid.UUID
+}
+
+func (q *Queries) ListPollVotesByUser(ctx context.Context, arg ListPollVotesByUserParams) ([]PollVote, error) {
+	rows, err := q.db.QueryContext(ctx, listPollVotesByUser, arg.UserID, pq.Array(arg.PollIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PollVote
+	for rows.Next() {
+		var i PollVote
+		if err := rows.Scan(
+			&i.PollID,
+			&i.UserID,
+			&i.OptionIndex,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPollsForChirps = `-- name: ListPollsForChirps :many
+SELECT id, chirp_id, created_at, closes_at
+FROM polls
+WHERE chirp_id = ANY($1::uuid[])
+`
+
+func (q *Queries) ListPollsForChirps(ctx context.Context, chirpIds []uuid.UUID) ([]Poll, error) {
+	rows, err := q.db.QueryContext(ctx, listPollsForChirps, pq.Array(chirpIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Poll
+	for rows.Next() {
+		var i Poll
+		if err := rows.Scan(
+			&i.ID,
+			&i.ChirpID,
+			&i.CreatedAt,
+			&i.ClosesAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listSubscribedLists = `-- name: ListSubscribedLists :many
+SELECT l.id, l.created_at, l.updated_at, l.owner_id, l.name, l.description, l.is_private, l.member_count, l.subscriber_count
+FROM lists l
+JOIN list_subscriptions s ON s.list_id = l.id
+WHERE s.user_id = $1
+AND (NOT l.is_private OR l.owner_id = $1)
+ORDER BY s.created_at DESC
+`
+
+// private lists drop out for subscribers who aren't the owner
+func (q *Queries) ListSubscribedLists(ctx context.Context, userID uuid.UUID) ([]List, error) {
+	rows, err := q.db.QueryContext(ctx, listSubscribedLists, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []List
+	for rows.Next() {
+		var i List
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.OwnerID,
+			&i.Name,
+			&i.Description,
+			&i.IsPrivate,
+			&i.MemberCount,
+			&i.SubscriberCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTimeline = `-- name: ListTimeline :many
+SELECT c.id, c.created_at, c.updated_at, c.body, c.user_id
+FROM chirps c
+WHERE c.user_id IN (
+    SELECT user_id
+    FROM list_members
+    WHERE list_id = $1
+)
+AND c.user_id NOT IN (
+    SELECT blocked_id
+    FROM user_blocks
+    WHERE blocker_id = $2
+)
+AND (
+    $3::timestamptz IS NULL
+    OR (c.created_at, c.id) < ($3, $4::uuid)
+)
+ORDER BY c.created_at DESC, c.id DESC
+LIMIT $5
+`
+
+type ListTimelineParams struct {
+	ListID          uuid.UUID
+	ViewerID        uuid.UUID
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	Limit           int32
+}
+
+// the chirps of the list's members newest first, after the (created_at, id)
+// cursor, without the ones of users the viewer blocked
+func (q *Queries) ListTimeline(ctx context.Context, arg ListTimelineParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listTimeline,
+		arg.ListID,
+		arg.ViewerID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWebhookDeliveries = `-- name: ListWebhookDeliveries :many
+SELECT id, created_at, endpoint_id, event_id, event_type, payload, status, attempts, next_attempt_at, last_attempt_at, response_status, last_error, delivered_at
+FROM webhook_deliveries
+WHERE endpoint_id = $1
+AND ($2::text IS NULL OR status = $2)
+ORDER BY created_at DESC
+LIMIT $3
+`
+
+type ListWebhookDeliveriesParams struct {
+	EndpointID uuid.UUID
+	Status     sql.NullString
+	Limit      int32
+}
+
+func (q *Queries) ListWebhookDeliveries(ctx context.Context, arg ListWebhookDeliveriesParams) ([]WebhookDelivery, error) {
+	rows, err := q.db.QueryContext(ctx, listWebhookDeliveries, arg.EndpointID, arg.Status, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookDelivery
+	for rows.Next() {
+		var i WebhookDelivery
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.EndpointID,
+			&i.EventID,
+			&i.EventType,
+			&i.Payload,
+			&i.Status,
+			&i.Attempts,
+			&i.NextAttemptAt,
+			&i.LastAttemptAt,
+			&i.ResponseStatus,
+			&i.LastError,
+			&i.DeliveredAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWebhookEndpointsByOwner = `-- name: ListWebhookEndpointsByOwner :many
+SELECT id, created_at, updated_at, owner_id, oauth_client_id, url, events, secret FROM webhook_endpoints
+WHERE owner_id = $1
+ORDER BY created_at DESC
+`
+
+func (q *Queries) ListWebhookEndpointsByOwner(ctx context.Context, ownerID uuid.UUID) ([]WebhookEndpoint, error) {
+	rows, err := q.db.QueryContext(ctx, listWebhookEndpointsByOwner, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookEndpoint
+	for rows.Next() {
+		var i WebhookEndpoint
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.OwnerID,
+			&i.OauthClientID,
+			&i.Url,
+			pq.Array(&i.Events),
+			&i.Secret,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWebhookEndpointsForEvent = `-- name: ListWebhookEndpointsForEvent :many
+SELECT id, created_at, updated_at, owner_id, oauth_client_id, url, events, secret FROM webhook_endpoints
+WHERE $1::text = ANY(events)
+AND (
+    (oauth_client_id IS NULL AND owner_id = $2)
+    OR oauth_client_id IN (
+        SELECT client_id FROM refresh_tokens
+        WHERE user_id = $2
+        AND client_id IS NOT NULL
+        AND revoked_at IS NULL
+        AND expires_at > NOW()
+        AND ($3::text IS NULL OR $3::text = ANY(scopes))
+    )
+)
+`
+
+type ListWebhookEndpointsForEventParams struct {
+	EventType string
+	UserID    uuid.UUID
+	Scope     sql.NullString
+}
+
+// the user's own endpoints and those of the OAuth clients they authorized,
+// when the authorization includes the scope the event requires
+func (q *Queries) ListWebhookEndpointsForEvent(ctx context.Context, arg ListWebhookEndpointsForEventParams) ([]WebhookEndpoint, error) {
+	rows, err := q.db.QueryContext(ctx, listWebhookEndpointsForEvent, arg.EventType, arg.UserID, arg.Scope)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookEndpoint
+	for rows.Next() {
+		var i WebhookEndpoint
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.OwnerID,
+			&i.OauthClientID,
+			&i.Url,
+			pq.Array(&i.Events),
+			&i.Secret,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWebhookEvents = `-- name: ListWebhookEvents :many
+SELECT id, provider, event_id, event_type, payload, status, attempts, last_error, received_at, processed_at, lease_until
+FROM webhook_events
+WHERE ($1::text IS NULL OR provider = $1)
+AND ($2::text IS NULL OR status = $2)
+AND ($3::text IS NULL OR event_type = $3)
+ORDER BY received_at DESC
+LIMIT $4
+`
+
+type ListWebhookEventsParams struct {
+	Provider  sql.NullString
+	Status    sql.NullString
+	EventType sql.NullString
+	Limit     int32
+}
+
+func (q *Queries) ListWebhookEvents(ctx context.Context, arg ListWebhookEventsParams) ([]WebhookEvent, error) {
+	rows, err := q.db.QueryContext(ctx, listWebhookEvents,
+		arg.Provider,
+		arg.Status,
+		arg.EventType,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookEvent
+	for rows.Next() {
+		var i WebhookEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.Provider,
+			&i.EventID,
+			&i.EventType,
+			&i.Payload,
+			&i.Status,
+			&i.Attempts,
+			&i.LastError,
+			&i.ReceivedAt,
+			&i.ProcessedAt,
+			&i.LeaseUntil,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markAllNotificationsRead = `-- name: MarkAllNotificationsRead :execrows
+UPDATE notifications
+SET read_at = NOW()
+WHERE user_id = $1
+AND read_at IS NULL
+`
+
+func (q *Queries) MarkAllNotificationsRead(ctx context.Context, userID uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, markAllNotificationsRead, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const markConversationRead = `-- name: MarkConversationRead :exec
+UPDATE conversation_members
+SET last_read_at = GREATEST(last_read_at, COALESCE($1, NOW()))
+WHERE conversation_id = $2
+AND user_id = $3
+`
+
+type MarkConversationReadParams struct {
+	ReadAt         sql.NullTime
+	ConversationID uuid.UUID
+	UserID         uuid.UUID
+}
+
+// read receipts only move forward. Without read_at everything sent so far
+// is read.
+func (q *Queries) MarkConversationRead(ctx context.Context, arg MarkConversationReadParams) error {
+	_, err := q.db.ExecContext(ctx, markConversationRead, arg.ReadAt, arg.ConversationID, arg.UserID)
+	return err
+}
+
+const markNotificationRead = `-- name: MarkNotificationRead :execrows
+UPDATE notifications
+SET read_at = COALESCE(read_at, NOW())
+WHERE id = $1
+AND user_id = $2
+`
+
+type MarkNotificationReadParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) MarkNotificationRead(ctx context.Context, arg MarkNotificationReadParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, markNotificationRead, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const markSubscriptionPastDue = `-- name: MarkSubscriptionPastDue :one
+UPDATE subscriptions
+SET status = 'past_due',
+    updated_at = NOW()
+WHERE user_id = $1
+AND status IN ('active', 'past_due')
+RETURNING id, created_at, updated_at, user_id, plan, status, current_period_start, current_period_end, canceled_at
+`
+
+func (q *Queries) MarkSubscriptionPastDue(ctx context.Context, userID uuid.UUID) (Subscription, error) {
+	row := q.db.QueryRowContext(ctx, markSubscriptionPastDue, userID)
+	var i Subscription
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Plan,
+		&i.Status,
+		&i.CurrentPeriodStart,
+		&i.CurrentPeriodEnd,
+		&i.CanceledAt,
+	)
+	return i, err
+}
+
+const markUserEmailVerified = `-- name: MarkUserEmailVerified :one
+UPDATE users
+SET email_verified_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1 AND email = $2
+RETURNING id, created_at, updated_at, email, is_chirpy_red, email_verified_at
+`
+
+type MarkUserEmailVerifiedParams struct {
+	ID    uuid.UUID
+	Email string
+}
+
+type MarkUserEmailVerifiedRow struct {
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	Email           string
+	IsChirpyRed     bool
+	EmailVerifiedAt sql.NullTime
+}
+
+func (q *Queries) MarkUserEmailVerified(ctx context.Context, arg MarkUserEmailVerifiedParams) (MarkUserEmailVerifiedRow, error) {
+	row := q.db.QueryRowContext(ctx, markUserEmailVerified, arg.ID, arg.Email)
+	var i MarkUserEmailVerifiedRow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.IsChirpyRed,
+		&i.EmailVerifiedAt,
+	)
+	return i, err
+}
+
+const pinChirp = `-- name: PinChirp :execrows
+INSERT INTO pinned_chirps (user_id, chirp_id, pinned_at)
+SELECT c.user_id, c.id, NOW()
+FROM chirps c
+WHERE c.id = $1
+AND c.user_id = $2
+ON CONFLICT (user_id) DO UPDATE
+SET chirp_id = EXCLUDED.chirp_id,
+    pinned_at = EXCLUDED.pinned_at
+`
+
+type PinChirpParams struct {
+	ChirpID uuid.UUID
+	UserID  uuid.UUID
+}
+
+// replaces the pinned chirp, only the author's own chirps can be pinned
+func (q *Queries) PinChirp(ctx context.Context, arg PinChirpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, pinChirp, arg.ChirpID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const recordLoginFailure = `-- name: RecordLoginFailure :one
+INSERT INTO login_throttles (key, failures, last_failure_at)
+VALUES ($1, 1, NOW())
+ON CONFLICT (key) DO UPDATE
+SET failures = CASE
+        WHEN login_throttles.last_failure_at < NOW() - INTERVAL '24 hours' THEN 1
+        ELSE login_throttles.failures + 1
+    END,
+    last_failure_at = NOW()
+RETURNING key, failures, last_failure_at, locked_until
+`
+
+// failures older than a day are forgotten
+func (q *Queries) RecordLoginFailure(ctx context.Context, key string) (LoginThrottle, error) {
+	row := q.db.QueryRowContext(ctx, recordLoginFailure, key)
+	var i LoginThrottle
+	err := row.Scan(
+		&i.Key,
+		&i.Failures,
+		&i.LastFailureAt,
+		&i.LockedUntil,
+	)
+	return i, err
+}
+
+const recordWebhookDeliveryAttempt = `-- name: RecordWebhookDeliveryAttempt :one
+UPDATE webhook_deliveries
+SET status = $2,
+    attempts = attempts + 1,
+    next_attempt_at = $3,
+    last_attempt_at = NOW(),
+    response_status = $4,
+    last_error = $5,
+    delivered_at = CASE WHEN $2 = 'succeeded' THEN NOW() ELSE delivered_at END
+WHERE id = $1
+RETURNING id, created_at, endpoint_id, event_id, event_type, payload, status, attempts, next_attempt_at, last_attempt_at, response_status, last_error, delivered_at
+`
+
+type RecordWebhookDeliveryAttemptParams struct {
+	ID             uuid.UUID
+	Status         string
+	NextAttemptAt  time.Time
+	ResponseStatus sql.NullInt32
+	LastError      string
+}
+
+func (q *Queries) RecordWebhookDeliveryAttempt(ctx context.Context, arg RecordWebhookDeliveryAttemptParams) (WebhookDelivery, error) {
+	row := q.db.QueryRowContext(ctx, recordWebhookDeliveryAttempt,
+		arg.ID,
+		arg.Status,
+		arg.NextAttemptAt,
+		arg.ResponseStatus,
+		arg.LastError,
+	)
+	var i WebhookDelivery
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.EndpointID,
+		&i.EventID,
+		&i.EventType,
+		&i.Payload,
+		&i.Status,
+		&i.Attempts,
+		&i.NextAttemptAt,
+		&i.LastAttemptAt,
+		&i.ResponseStatus,
+		&i.LastError,
+		&i.DeliveredAt,
+	)
+	return i, err
+}
+
+const rememberWebhookSignature = `-- name: RememberWebhookSignature :execrows
+INSERT INTO webhook_signatures (signature, expires_at)
+VALUES ($1, $2)
+ON CONFLICT (signature) DO NOTHING
+`
+
+type RememberWebhookSignatureParams struct {
+	Signature string
+	ExpiresAt time.Time
+}
+
+func (q *Queries) RememberWebhookSignature(ctx context.Context, arg RememberWebhookSignatureParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, rememberWebhookSignature, arg.Signature, arg.ExpiresAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const removeListMember = `-- name: RemoveListMember :execrows
+WITH removed AS (
+    DELETE FROM list_members
+    WHERE list_id = $1
+    AND user_id = $2
+    RETURNING list_id
+)
+UPDATE lists
+SET member_count = member_count - 1,
+    updated_at = NOW()
+WHERE id IN (SELECT list_id FROM removed)
+`
+
+type RemoveListMemberParams struct {
+	ListID uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) RemoveListMember(ctx context.Context, arg RemoveListMemberParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, removeListMember, arg.ListID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const retryWebhookDelivery = `-- name: RetryWebhookDelivery :one
+UPDATE webhook_deliveries
+SET status = 'pending',
+    attempts = 0,
+    next_attempt_at = NOW()
+WHERE id = $1
+AND endpoint_id = $2
+AND status = 'dead'
+RETURNING id, created_at, endpoint_id, event_id, event_type, payload, status, attempts, next_attempt_at, last_attempt_at, response_status, last_error, delivered_at
+`
+
+type RetryWebhookDeliveryParams struct {
+	ID         uuid.UUID
+	EndpointID uuid.UUID
+}
+
+func (q *Queries) RetryWebhookDelivery(ctx context.Context, arg RetryWebhookDeliveryParams) (WebhookDelivery, error) {
+	row := q.db.QueryRowContext(ctx, retryWebhookDelivery, arg.ID, arg.EndpointID)
+	var i WebhookDelivery
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.EndpointID,
+		&i.EventID,
+		&i.EventType,
+		&i.Payload,
+		&i.Status,
+		&i.Attempts,
+		&i.NextAttemptAt,
+		&i.LastAttemptAt,
+		&i.ResponseStatus,
+		&i.LastError,
+		&i.DeliveredAt,
+	)
+	return i, err
+}
+
+const revokeAllPersonalAccessTokensForUser = `-- name: RevokeAllPersonalAccessTokensForUser :execrows
+UPDATE personal_access_tokens
+SET revoked_at = NOW()
+WHERE user_id = $1
+AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeAllPersonalAccessTokensForUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeAllPersonalAccessTokensForUser, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const revokeAllRefreshTokensForUser = `-- name: RevokeAllRefreshTokensForUser :execrows
+UPDATE refresh_tokens
+SET revoked_at = NOW(),
+    updated_at = NOW()
+WHERE user_id = $1
+AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeAllRefreshTokensForUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeAllRefreshTokensForUser, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const revokePersonalAccessToken = `-- name: RevokePersonalAccessToken :execrows
+UPDATE personal_access_tokens
+SET revoked_at = NOW()
+WHERE id = $1
+AND user_id = $2
+AND revoked_at IS NULL
+`
+
+type RevokePersonalAccessTokenParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) RevokePersonalAccessToken(ctx context.Context, arg RevokePersonalAccessTokenParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokePersonalAccessToken, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const revokeRefreshToken = `-- name: RevokeRefreshToken :one
+UPDATE refresh_tokens
+SET revoked_at = NOW(),
+    updated_at = NOW()
+WHERE token_hash = $1
+RETURNING token_hash, created_at, updated_at, user_id, expires_at, revoked_at, family_id, rotated_at, device_name, user_agent, ip_address, session_started_at, last_used_at, client_id, scopes
+`
+
+func (q *Queries) RevokeRefreshToken(ctx context.Context, tokenHash string) (RefreshToken, error) {
+	row := q.db.QueryRowContext(ctx, revokeRefreshToken, tokenHash)
+	var i RefreshToken
+	err := row.Scan(
+		&i.TokenHash,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+		&i.FamilyID,
+		&i.RotatedAt,
+		&i.DeviceName,
+		&i.UserAgent,
+		&i.IpAddress,
+		&i.SessionStartedAt,
+		&i.LastUsedAt,
+		&i.ClientID,
+		pq.Array(&i.Scopes),
+	)
+	return i, err
+}
+
+const revokeRefreshTokenFamily = `-- name: RevokeRefreshTokenFamily :execrows
+UPDATE refresh_tokens
+SET revoked_at = NOW(),
+    updated_at = NOW()
+WHERE family_id = $1
+AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeRefreshTokenFamily(ctx context.Context, familyID uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeRefreshTokenFamily, familyID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const revokeUserRefreshTokenFamily = `-- name: RevokeUserRefreshTokenFamily :execrows
+UPDATE refresh_tokens
+SET revoked_at = NOW(),
+    updated_at = NOW()
+WHERE family_id = $1
+AND user_id = $2
+AND revoked_at IS NULL
+`
+
+type RevokeUserRefreshTokenFamilyParams struct {
+	FamilyID uuid.UUID
+	UserID   uuid.UUID
+}
+
+func (q *Queries) RevokeUserRefreshTokenFamily(ctx context.Context, arg RevokeUserRefreshTokenFamilyParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeUserRefreshTokenFamily, arg.FamilyID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const rotateRefreshToken = `-- name: RotateRefreshToken :execrows
+UPDATE refresh_tokens
+SET rotated_at = NOW(),
+    updated_at = NOW()
+WHERE token_hash = $1
+AND rotated_at IS NULL
+AND revoked_at IS NULL
+AND expires_at > NOW()
+`
+
+func (q *Queries) RotateRefreshToken(ctx context.Context, tokenHash string) (int64, error) {
+	result, err := q.db.ExecContext(ctx, rotateRefreshToken, tokenHash)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const setLoginLockout = `-- name: SetLoginLockout :exec
+UPDATE login_throttles
+SET locked_until = $2
+WHERE key = $1
+`
+
+type SetLoginLockoutParams struct {
+	Key         string
+	LockedUntil sql.NullTime
+}
+
+func (q *Queries) SetLoginLockout(ctx context.Context, arg SetLoginLockoutParams) error {
+	_, err := q.db.ExecContext(ctx, setLoginLockout, arg.Key, arg.LockedUntil)
+	return err
+}
+
+const setNotificationPreference = `-- name: SetNotificationPreference :exec
+INSERT INTO notification_preferences (user_id, type, enabled, updated_at)
+VALUES ($1, $2, $3, NOW())
+ON CONFLICT (user_id, type)
+DO UPDATE SET enabled = EXCLUDED.enabled,
+    updated_at = NOW()
+`
+
+type SetNotificationPreferenceParams struct {
+	UserID  uuid.UUID
+	Type    string
+	Enabled bool
+}
+
+func (q *Queries) SetNotificationPreference(ctx context.Context, arg SetNotificationPreferenceParams) error {
+	_, err := q.db.ExecContext(ctx, setNotificationPreference, arg.UserID, arg.Type, arg.Enabled)
+	return err
+}
+
+const setUserChirpyRed = `-- name: SetUserChirpyRed :one
+UPDATE users
+SET is_chirpy_red = $2,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, is_chirpy_red
+`
+
+type SetUserChirpyRedParams struct {
+	ID          uuid.UUID
+	IsChirpyRed bool
+}
+
+type SetUserChirpyRedRow struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Email       string
+	IsChirpyRed bool
+}
+
+func (q *Queries) SetUserChirpyRed(ctx context.Context, arg SetUserChirpyRedParams) (SetUserChirpyRedRow, error) {
+	row := q.db.QueryRowContext(ctx, setUserChirpyRed, arg.ID, arg.IsChirpyRed)
+	var i SetUserChirpyRedRow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.IsChirpyRed,
+	)
+	return i, err
+}
+
+const startUserTOTPEnrollment = `-- name: StartUserTOTPEnrollment :one
+INSERT INTO user_totp (user_id, secret, created_at, updated_at, enabled_at, last_step)
+VALUES (
+    $1,
+    $2,
+    NOW(),
+    NOW(),
+    NULL,
+    0
+)
+ON CONFLICT (user_id) DO UPDATE
+SET secret = EXCLUDED.secret,
+    updated_at = NOW(),
+    last_step = 0
+WHERE user_totp.enabled_at IS NULL
+RETURNING user_id, secret, created_at, updated_at, enabled_at, last_step
+`
+
+type StartUserTOTPEnrollmentParams struct {
+	UserID uuid.UUID
+	Secret string
+}
+
+func (q *Queries) StartUserTOTPEnrollment(ctx context.Context, arg StartUserTOTPEnrollmentParams) (UserTotp, error) {
+	row := q.db.QueryRowContext(ctx, startUserTOTPEnrollment, arg.UserID, arg.Secret)
+	var i UserTotp
+	err := row.Scan(
+		&i.UserID,
+		&i.Secret,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.EnabledAt,
+		&i.LastStep,
+	)
+	return i, err
+}
+
+const storeRefreshToken = `-- name: StoreRefreshToken :one
+INSERT INTO refresh_tokens (
+    token_hash, user_id, family_id, created_at, updated_at, expires_at, revoked_at,
+    device_name, user_agent, ip_address, session_started_at, last_used_at,
+    client_id, scopes
+)
+VALUES (
+    $1, -- token_hash
+    $2, -- user_id
+    $3, -- family_id
+    NOW(), -- created_at
+    NOW(), -- updated_at
+    NOW() + INTERVAL '60 days', -- expires_at
+    NULL, -- revoked_at
+    $4, -- device_name
+    $5, -- user_agent
+    $6, -- ip_address
+    $7, -- session_started_at
+    NOW(), -- last_used_at
+    $8, -- client_id
+    $9 -- scopes
+)
+RETURNING token_hash, created_at, updated_at, user_id, expires_at, revoked_at, family_id, rotated_at, device_name, user_agent, ip_address, session_started_at, last_used_at, client_id, scopes
 `
 
-type CreateChirpParams struct {
-	Body   string
-	UserID uuid.UUID
+type StoreRefreshTokenParams struct {
+	TokenHash        string
+	UserID           uuid.UUID
+	FamilyID         uuid.UUID
+	DeviceName       string
+	UserAgent        string
+	IpAddress        string
+	SessionStartedAt time.Time
+	ClientID         uuid.NullUUID
+	Scopes           []string
 }
 
-func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
-	var i Chirp
+func (q *Queries) StoreRefreshToken(ctx context.Context, arg StoreRefreshTokenParams) (RefreshToken, error) {
+	row := q.db.QueryRowContext(ctx, storeRefreshToken,
+		arg.TokenHash,
+		arg.UserID,
+		arg.FamilyID,
+		arg.DeviceName,
+		arg.UserAgent,
+		arg.IpAddress,
+		arg.SessionStartedAt,
+		arg.ClientID,
+		pq.Array(arg.Scopes),
+	)
+	var i RefreshToken
 	err := row.Scan(
-		&i.ID,
+		&i.TokenHash,
 		&i.CreatedAt,
 		&i.UpdatedAt,
-		&i.Body,
 		&i.UserID,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+		&i.FamilyID,
+		&i.RotatedAt,
+		&i.DeviceName,
+		&i.UserAgent,
+		&i.IpAddress,
+		&i.SessionStartedAt,
+		&i.LastUsedAt,
+		&i.ClientID,
+		pq.Array(&i.Scopes),
 	)
 	return i, err
 }
 
-const createUser = `-- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email, hashed_password,is_chirpy_red)
-VALUES (
-    gen_random_uuid(),
-    NOW(),
-    NOW(),
-    $1,
-    $2,
-    FALSE
+const subscribeToList = `-- name: SubscribeToList :exec
+WITH added AS (
+    INSERT INTO list_subscriptions (list_id, user_id, created_at)
+    VALUES ($1, $2, NOW())
+    ON CONFLICT DO NOTHING
+    RETURNING list_id
 )
-RETURNING id,created_at,updated_at,email,is_chirpy_red
+UPDATE lists
+SET subscriber_count = subscriber_count + 1
+WHERE id IN (SELECT list_id FROM added)
 `
 
-type CreateUserParams struct {
-	Email          string
-	HashedPassword string
+type SubscribeToListParams struct {
+	ListID uuid.UUID
+	UserID uuid.UUID
 }
 
-type CreateUserRow struct {
-	ID          uuid.UUID
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	Email       string
-	IsChirpyRed bool
+func (q *Queries) SubscribeToList(ctx context.Context, arg SubscribeToListParams) error {
+	_, err := q.db.ExecContext(ctx, subscribeToList, arg.ListID, arg.UserID)
+	return err
 }
 
-func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (CreateUserRow, error) {
-	row := q.db.QueryRowContext(ctx, createUser, arg.Email, arg.HashedPassword)
-	var i CreateUserRow
+const takeChirpDraft = `-- name: TakeChirpDraft :one
+DELETE FROM chirp_drafts
+WHERE id = $1
+AND user_id = $2
+RETURNING id, created_at, updated_at, user_id, body, poll, publish_at, publish_attempts, last_error
+`
+
+type TakeChirpDraftParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+// removes the draft being published, in the transaction that creates its chirp
+func (q *Queries) TakeChirpDraft(ctx context.Context, arg TakeChirpDraftParams) (ChirpDraft, error) {
+	row := q.db.QueryRowContext(ctx, takeChirpDraft, arg.ID, arg.UserID)
+	var i ChirpDraft
 	err := row.Scan(
 		&i.ID,
 		&i.CreatedAt,
 		&i.UpdatedAt,
-		&i.Email,
-		&i.IsChirpyRed,
+		&i.UserID,
+		&i.Body,
+		&i.Poll,
+		&i.PublishAt,
+		&i.PublishAttempts,
+		&i.LastError,
 	)
 	return i, err
 }
 
-const deleteAllUsers = `-- name: DeleteAllUsers :exec
-DELETE FROM users
+const takeDueScheduledChirp = `-- name: TakeDueScheduledChirp :one
+DELETE FROM chirp_drafts
+WHERE id = (
+    SELECT d.id
+    FROM chirp_drafts d
+    JOIN users u ON u.id = d.user_id
+    WHERE d.publish_at <= NOW()
+    AND u.email_verified_at IS NOT NULL
+    ORDER BY d.publish_at
+    LIMIT 1
+    FOR UPDATE OF d SKIP LOCKED
+)
+RETURNING id, created_at, updated_at, user_id, body, poll, publish_at, publish_attempts, last_error
 `
 
-func (q *Queries) DeleteAllUsers(ctx context.Context) error {
-	_, err := q.db.ExecContext(ctx, deleteAllUsers)
-	return err
+// the scheduled chirp due the longest, skipping the ones other instances are
+// publishing and those of authors whose email isn't verified
+func (q *Queries) TakeDueScheduledChirp(ctx context.Context) (ChirpDraft, error) {
+	row := q.db.QueryRowContext(ctx, takeDueScheduledChirp)
+	var i ChirpDraft
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Body,
+		&i.Poll,
+		&i.PublishAt,
+		&i.PublishAttempts,
+		&i.LastError,
+	)
+	return i, err
 }
 
-const deleteChirp = `-- name: DeleteChirp :exec
-DELETE FROM chirps
-WHERE id = $1 AND user_id = $2
+const touchConversation = `-- name: TouchConversation :exec
+UPDATE conversations
+SET updated_at = $2
+WHERE id = $1
 `
 
-type DeleteChirpParams struct {
-	ID     uuid.UUID
-	UserID uuid.UUID
+type TouchConversationParams struct {
+	ID        uuid.UUID
+	UpdatedAt time.Time
 }
 
-func (q *Queries) DeleteChirp(ctx context.Context, arg DeleteChirpParams) error {
-	_, err := q.db.ExecContext(ctx, deleteChirp, arg.ID, arg.UserID)
+func (q *Queries) TouchConversation(ctx context.Context, arg TouchConversationParams) error {
+	_, err := q.db.ExecContext(ctx, touchConversation, arg.ID, arg.UpdatedAt)
 	return err
 }
 
-const getAllChirps = `-- name: GetAllChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps ORDER BY created_at ASC
+const touchPersonalAccessToken = `-- name: TouchPersonalAccessToken :exec
+UPDATE personal_access_tokens
+SET last_used_at = NOW()
+WHERE id = $1
 `
 
-func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getAllChirps)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	var items []Chirp
-	for rows.Next() {
-		var i Chirp
-		if err := rows.Scan(
-			&i.ID,
-			&i.CreatedAt,
-			&i.UpdatedAt,
-			&i.Body,
-			&i.UserID,
-		); err != nil {
-			return nil, err
-		}
-		items = append(items, i)
-	}
-	if err := rows.Close(); err != nil {
-		return nil, err
-	}
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
-	return items, nil
+func (q *Queries) TouchPersonalAccessToken(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, touchPersonalAccessToken, id)
+	return err
 }
 
-const getChirpByID = `-- name: GetChirpByID :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps WHERE id = $1
+const unblockUser = `-- name: UnblockUser :execrows
+DELETE FROM user_blocks
+WHERE blocker_id = $1
+AND blocked_id = $2
 `
 
-func (q *Queries) GetChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, getChirpByID, id)
-	var i Chirp
-	err := row.Scan(
-		&i.ID,
-		&i.CreatedAt,
-		&i.UpdatedAt,
-		&i.Body,
-		&i.UserID,
-	)
-	return i, err
+type UnblockUserParams struct {
+	BlockerID uuid.UUID
+	BlockedID uuid.UUID
 }
 
-const getChirpsByAuthor = `-- name: GetChirpsByAuthor :many
-SELECT id, created_at, updated_at, body, user_id
-FROM chirps
+func (q *Queries) UnblockUser(ctx context.Context, arg UnblockUserParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, unblockUser, arg.BlockerID, arg.BlockedID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const unpinChirp = `-- name: UnpinChirp :execrows
+DELETE FROM pinned_chirps
 WHERE user_id = $1
-ORDER BY created_at ASC
+AND chirp_id = $2
 `
 
-func (q *Queries) GetChirpsByAuthor(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirpsByAuthor, userID)
+type UnpinChirpParams struct {
+	UserID  uuid.UUID
+	ChirpID uuid.UUID
+}
+
+func (q *Queries) UnpinChirp(ctx context.Context, arg UnpinChirpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, unpinChirp, arg.UserID, arg.ChirpID)
 	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	var items []Chirp
-	for rows.Next() {
-		var i Chirp
-		if err := rows.Scan(
-			&i.ID,
-			&i.CreatedAt,
-			&i.UpdatedAt,
-			&i.Body,
-			&i.UserID,
-		); err != nil {
-			return nil, err
-		}
-		items = append(items, i)
-	}
-	if err := rows.Close(); err != nil {
-		return nil, err
+		return 0, err
 	}
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
-	return items, nil
+	return result.RowsAffected()
 }
 
-const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, created_at, updated_at, email, hashed_password,is_chirpy_red
-FROM users
-WHERE email = $1
+const unsubscribeFromList = `-- name: UnsubscribeFromList :execrows
+WITH removed AS (
+    DELETE FROM list_subscriptions
+    WHERE list_id = $1
+    AND user_id = $2
+    RETURNING list_id
+)
+UPDATE lists
+SET subscriber_count = subscriber_count - 1
+WHERE id IN (SELECT list_id FROM removed)
 `
 
-func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
-	row := q.db.QueryRowContext(ctx, getUserByEmail, email)
-	var i User
-	err := row.Scan(
-		&i.ID,
-		&i.CreatedAt,
-		&i.UpdatedAt,
-		&i.Email,
-		&i.HashedPassword,
-		&i.IsChirpyRed,
-	)
-	return i, err
+type UnsubscribeFromListParams struct {
+	ListID uuid.UUID
+	UserID uuid.UUID
 }
 
-const getUserFromRefreshToken = `-- name: GetUserFromRefreshToken :one
-SELECT user_id
-FROM refresh_tokens
-WHERE token = $1
-AND expires_at > NOW()
-AND revoked_at IS NULL
-`
-
-func (q *Queries) GetUserFromRefreshToken(ctx context.Context, token string) (uuid.UUID, error) {
-	row := q.db.QueryRowContext(ctx, getUserFromRefreshToken, token)
-	var user_id uuid.UUID
-	err := row.Scan(&user_id)
-	return user_id, err
+func (q *Queries) UnsubscribeFromList(ctx context.Context, arg UnsubscribeFromListParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, unsubscribeFromList, arg.ListID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
 
-const revokeRefreshToken = `-- name: RevokeRefreshToken :one
-UPDATE refresh_tokens
-SET revoked_at = NOW(),
+const updateChirpDraft = `-- name: UpdateChirpDraft :one
+UPDATE chirp_drafts
+SET body = $3,
+    poll = $4,
+    publish_at = $5,
+    publish_attempts = 0,
+    last_error = '',
     updated_at = NOW()
-WHERE token = $1
-RETURNING token, created_at, updated_at, user_id, expires_at, revoked_at
+WHERE id = $1
+AND user_id = $2
+RETURNING id, created_at, updated_at, user_id, body, poll, publish_at, publish_attempts, last_error
 `
 
-func (q *Queries) RevokeRefreshToken(ctx context.Context, token string) (RefreshToken, error) {
-	row := q.db.QueryRowContext(ctx, revokeRefreshToken, token)
-	var i RefreshToken
+type UpdateChirpDraftParams struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Body      string
+	Poll      json.RawMessage
+	PublishAt sql.NullTime
+}
+
+func (q *Queries) UpdateChirpDraft(ctx context.Context, arg UpdateChirpDraftParams) (ChirpDraft, error) {
+	row := q.db.QueryRowContext(ctx, updateChirpDraft,
+		arg.ID,
+		arg.UserID,
+		arg.Body,
+		arg.Poll,
+		arg.PublishAt,
+	)
+	var i ChirpDraft
 	err := row.Scan(
-		&i.Token,
+		&i.ID,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.UserID,
-		&i.ExpiresAt,
-		&i.RevokedAt,
+		&i.Body,
+		&i.Poll,
+		&i.PublishAt,
+		&i.PublishAttempts,
+		&i.LastError,
 	)
 	return i, err
 }
 
-const storeRefreshToken = `-- name: StoreRefreshToken :one
-INSERT INTO refresh_tokens (token, user_id, created_at, updated_at, expires_at, revoked_at)
-VALUES (
-    $1, -- token
-    $2, -- user_id
-    NOW(), -- created_at
-    NOW(), -- updated_at
-    NOW() + INTERVAL '60 days', -- expires_at
-    NULL -- revoked_at
-)
-RETURNING token, created_at, updated_at, user_id, expires_at, revoked_at
+const updateList = `-- name: UpdateList :one
+UPDATE lists
+SET name = $3,
+    description = $4,
+    is_private = $5,
+    updated_at = NOW()
+WHERE id = $1
+AND owner_id = $2
+RETURNING id, created_at, updated_at, owner_id, name, description, is_private, member_count, subscriber_count
 `
 
-type StoreRefreshTokenParams struct {
-	Token  string
-	UserID uuid.UUID
+type UpdateListParams struct {
+	ID          uuid.UUID
+	OwnerID     uuid.UUID
+	Name        string
+	Description string
+	IsPrivate   bool
 }
 
-func (q *Queries) StoreRefreshToken(ctx context.Context, arg StoreRefreshTokenParams) (RefreshToken, error) {
-	row := q.db.QueryRowContext(ctx, storeRefreshToken, arg.Token, arg.UserID)
-	var i RefreshToken
+func (q *Queries) UpdateList(ctx context.Context, arg UpdateListParams) (List, error) {
+	row := q.db.QueryRowContext(ctx, updateList,
+		arg.ID,
+		arg.OwnerID,
+		arg.Name,
+		arg.Description,
+		arg.IsPrivate,
+	)
+	var i List
 	err := row.Scan(
-		&i.Token,
+		&i.ID,
 		&i.CreatedAt,
 		&i.UpdatedAt,
-		&i.UserID,
-		&i.ExpiresAt,
-		&i.RevokedAt,
+		&i.OwnerID,
+		&i.Name,
+		&i.Description,
+		&i.IsPrivate,
+		&i.MemberCount,
+		&i.SubscriberCount,
 	)
 	return i, err
 }
@@ -284,6 +4031,7 @@ const updateUser = `-- name: UpdateUser :one
 UPDATE users
 SET email = $2,
     hashed_password = $3,
+    email_verified_at = CASE WHEN email = $2 THEN email_verified_at ELSE NULL END,
     updated_at = NOW()
 WHERE id = $1
 RETURNING id, created_at, updated_at, email,is_chirpy_red
@@ -316,31 +4064,112 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (UpdateU
 	return i, err
 }
 
-const upgradeUserToChirpyRed = `-- name: UpgradeUserToChirpyRed :one
+const updateUserPassword = `-- name: UpdateUserPassword :exec
 UPDATE users
-SET is_chirpy_red = TRUE,
+SET hashed_password = $2,
     updated_at = NOW()
 WHERE id = $1
-RETURNING id, created_at, updated_at, email, is_chirpy_red
 `
 
-type UpgradeUserToChirpyRedRow struct {
-	ID          uuid.UUID
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	Email       string
-	IsChirpyRed bool
+type UpdateUserPasswordParams struct {
+	ID             uuid.UUID
+	HashedPassword string
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserPassword, arg.ID, arg.HashedPassword)
+	return err
+}
+
+const upsertNotification = `-- name: UpsertNotification :one
+INSERT INTO notifications (id, created_at, updated_at, user_id, type, chirp_id, group_key, data)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+ON CONFLICT (user_id, group_key) WHERE read_at IS NULL
+DO UPDATE SET updated_at = NOW(),
+    data = EXCLUDED.data
+RETURNING id, created_at, updated_at, user_id, type, chirp_id, group_key, data, actor_count, read_at
+`
+
+type UpsertNotificationParams struct {
+	UserID   uuid.UUID
+	Type     string
+	ChirpID  uuid.NullUUID
+	GroupKey sql.NullString
+	Data     json.RawMessage
 }
 
-func (q *Queries) UpgradeUserToChirpyRed(ctx context.Context, id uuid.UUID) (UpgradeUserToChirpyRedRow, error) {
-	row := q.db.QueryRowContext(ctx, upgradeUserToChirpyRed, id)
-	var i UpgradeUserToChirpyRedRow
+// folds into the user's unread notification with the same group_key, if any
+func (q *Queries) UpsertNotification(ctx context.Context, arg UpsertNotificationParams) (Notification, error) {
+	row := q.db.QueryRowContext(ctx, upsertNotification,
+		arg.UserID,
+		arg.Type,
+		arg.ChirpID,
+		arg.GroupKey,
+		arg.Data,
+	)
+	var i Notification
 	err := row.Scan(
 		&i.ID,
 		&i.CreatedAt,
 		&i.UpdatedAt,
-		&i.Email,
-		&i.IsChirpyRed,
+		&i.UserID,
+		&i.Type,
+		&i.ChirpID,
+		&i.GroupKey,
+		&i.Data,
+		&i.ActorCount,
+		&i.ReadAt,
 	)
 	return i, err
 }
+
+const useTOTPRecoveryCode = `-- name: UseTOTPRecoveryCode :execrows
+UPDATE totp_recovery_codes
+SET used_at = NOW()
+WHERE user_id = $1
+AND code_hash = $2
+AND used_at IS NULL
+`
+
+type UseTOTPRecoveryCodeParams struct {
+	UserID   uuid.UUID
+	CodeHash string
+}
+
+func (q *Queries) UseTOTPRecoveryCode(ctx context.Context, arg UseTOTPRecoveryCodeParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, useTOTPRecoveryCode, arg.UserID, arg.CodeHash)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const useUserTOTPStep = `-- name: UseUserTOTPStep :execrows
+UPDATE user_totp
+SET last_step = $2,
+    updated_at = NOW()
+WHERE user_id = $1
+AND last_step < $2
+`
+
+type UseUserTOTPStepParams struct {
+	UserID   uuid.UUID
+	LastStep int64
+}
+
+func (q *Queries) UseUserTOTPStep(ctx context.Context, arg UseUserTOTPStepParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, useUserTOTPStep, arg.UserID, arg.LastStep)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,175 @@
+// Package events is an in-process publish/subscribe bus for the real-time
+// endpoints. It keeps the latest events so a client that reconnects can resume
+// where it left off.
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidEventID = errors.New("invalid event id")
+
+// Event is something that happened to ResourceID, for UserID: its owner, or
+// the user it is addressed to. Data is the JSON sent to clients.
+type Event struct {
+	ID         ID
+	Type       string
+	UserID     uuid.UUID
+	ResourceID uuid.UUID
+	Data       json.RawMessage
+	CreatedAt  time.Time
+}
+
+// ID orders events. Epoch changes every time the process starts, so an ID from
+// a previous run is never mistaken for one of this run.
+type ID struct {
+	Epoch int64
+	Seq   uint64
+}
+
+func (id ID) String() string {
+	return fmt.Sprintf("%d-%d", id.Epoch, id.Seq)
+}
+
+// ParseID parses the String form of an ID, as sent back in Last-Event-ID.
+func ParseID(s string) (ID, error) {
+	epoch, seq, found := strings.Cut(s, "-")
+	if !found {
+		return ID{}, ErrInvalidEventID
+	}
+	e, err := strconv.ParseInt(epoch, 10, 64)
+	if err != nil {
+		return ID{}, ErrInvalidEventID
+	}
+	n, err := strconv.ParseUint(seq, 10, 64)
+	if err != nil {
+		return ID{}, ErrInvalidEventID
+	}
+	return ID{Epoch: e, Seq: n}, nil
+}
+
+// Filter selects the events a subscription receives.
+type Filter func(Event) bool
+
+// Bus fans published events out to subscriptions and keeps the last
+// historySize of them for resuming.
+type Bus struct {
+	mu            sync.Mutex
+	epoch         int64
+	seq           uint64
+	history       []Event
+	historySize   int
+	subscriptions map[*Subscription]struct{}
+}
+
+func NewBus(historySize int) *Bus {
+	return &Bus{
+		epoch:         time.Now().UnixNano(),
+		historySize:   historySize,
+		subscriptions: map[*Subscription]struct{}{},
+	}
+}
+
+// Publish sends an event to every subscription whose filter accepts it.
+func (b *Bus) Publish(eventType string, userID, resourceID uuid.UUID, data any) (Event, error) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	event := Event{
+		ID:         ID{Epoch: b.epoch, Seq: b.seq},
+		Type:       eventType,
+		UserID:     userID,
+		ResourceID: resourceID,
+		Data:       payload,
+		CreatedAt:  time.Now().UTC(),
+	}
+
+	b.history = append(b.history, event)
+	if len(b.history) > b.historySize {
+		b.history = b.history[len(b.history)-b.historySize:]
+	}
+
+	for sub := range b.subscriptions {
+		if !sub.filter(event) {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+			// the subscriber can't keep up, it has to reconnect and resume
+			b.remove(sub)
+		}
+	}
+	return event, nil
+}
+
+// Subscribe starts receiving the events filter accepts, buffering up to
+// buffer of them. When last is set the events after it that are still kept
+// are returned to be sent first; complete is false when some of them were
+// already dropped or last is from a previous run, and the client has to load
+// the current state again.
+func (b *Bus) Subscribe(filter Filter, buffer int, last *ID) (sub *Subscription, missed []Event, complete bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sub = &Subscription{bus: b, filter: filter, ch: make(chan Event, buffer)}
+	b.subscriptions[sub] = struct{}{}
+
+	if last == nil {
+		return sub, nil, true
+	}
+	if last.Epoch != b.epoch || last.Seq > b.seq {
+		return sub, nil, false
+	}
+
+	complete = last.Seq == b.seq
+	for _, event := range b.history {
+		if event.ID.Seq == last.Seq+1 {
+			complete = true
+		}
+		if event.ID.Seq > last.Seq && filter(event) {
+			missed = append(missed, event)
+		}
+	}
+	return sub, missed, complete
+}
+
+// remove must be called with mu held.
+func (b *Bus) remove(sub *Subscription) {
+	if _, ok := b.subscriptions[sub]; ok {
+		delete(b.subscriptions, sub)
+		close(sub.ch)
+	}
+}
+
+// Subscription receives events on C until it is closed, by Close or by the
+// bus when its buffer is full.
+type Subscription struct {
+	bus    *Bus
+	filter Filter
+	ch     chan Event
+}
+
+func (s *Subscription) C() <-chan Event {
+	return s.ch
+}
+
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	s.bus.remove(s)
+}
@@ -0,0 +1,112 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func acceptAll(Event) bool { return true }
+
+func TestBusFiltersSubscriptions(t *testing.T) {
+	bus := NewBus(10)
+	alice, bob := uuid.New(), uuid.New()
+
+	sub, _, _ := bus.Subscribe(func(e Event) bool { return e.UserID == alice }, 10, nil)
+	defer sub.Close()
+
+	if _, err := bus.Publish("chirp.created", bob, uuid.New(), map[string]string{"body": "hi"}); err != nil {
+		t.Fatalf("Publish() unexpected error: %v", err)
+	}
+	if _, err := bus.Publish("chirp.created", alice, uuid.New(), map[string]string{"body": "hello"}); err != nil {
+		t.Fatalf("Publish() unexpected error: %v", err)
+	}
+
+	got := <-sub.C()
+	if got.UserID != alice || string(got.Data) != `{"body":"hello"}` {
+		t.Errorf("received %+v, want alice's event", got)
+	}
+	select {
+	case extra := <-sub.C():
+		t.Errorf("received unexpected event %+v", extra)
+	default:
+	}
+}
+
+func TestBusResume(t *testing.T) {
+	bus := NewBus(3)
+	userID := uuid.New()
+
+	var ids []ID
+	for i := 0; i < 5; i++ {
+		event, err := bus.Publish("chirp.created", userID, uuid.New(), i)
+		if err != nil {
+			t.Fatalf("Publish() unexpected error: %v", err)
+		}
+		ids = append(ids, event.ID)
+	}
+
+	tests := []struct {
+		name         string
+		last         ID
+		wantMissed   int
+		wantComplete bool
+	}{
+		{"up to date", ids[4], 0, true},
+		{"within history", ids[2], 2, true},
+		{"oldest kept is next", ids[1], 3, true},
+		{"dropped from history", ids[0], 3, false},
+		{"previous run", ID{Epoch: ids[0].Epoch - 1, Seq: 4}, 0, false},
+		{"from the future", ID{Epoch: ids[0].Epoch, Seq: 99}, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			last := tt.last
+			sub, missed, complete := bus.Subscribe(acceptAll, 10, &last)
+			defer sub.Close()
+			if len(missed) != tt.wantMissed || complete != tt.wantComplete {
+				t.Errorf("Subscribe() missed %d events, complete %v, want %d, %v", len(missed), complete, tt.wantMissed, tt.wantComplete)
+			}
+			for i := 1; i < len(missed); i++ {
+				if missed[i].ID.Seq <= missed[i-1].ID.Seq {
+					t.Errorf("missed events out of order: %v", missed)
+				}
+			}
+		})
+	}
+}
+
+func TestBusClosesSlowSubscriptions(t *testing.T) {
+	bus := NewBus(10)
+	sub, _, _ := bus.Subscribe(acceptAll, 1, nil)
+
+	for i := 0; i < 3; i++ {
+		if _, err := bus.Publish("chirp.created", uuid.New(), uuid.New(), i); err != nil {
+			t.Fatalf("Publish() unexpected error: %v", err)
+		}
+	}
+
+	if _, ok := <-sub.C(); !ok {
+		t.Fatalf("buffered event was lost")
+	}
+	if _, ok := <-sub.C(); ok {
+		t.Errorf("subscription still open after its buffer overflowed")
+	}
+	// closing again is harmless
+	sub.Close()
+}
+
+func TestParseID(t *testing.T) {
+	id := ID{Epoch: 1700000000000000000, Seq: 42}
+	got, err := ParseID(id.String())
+	if err != nil || got != id {
+		t.Errorf("ParseID(%q) = %v, %v, want %v", id.String(), got, err, id)
+	}
+
+	for _, s := range []string{"", "42", "a-1", "1-b", "1--1"} {
+		if _, err := ParseID(s); err == nil {
+			t.Errorf("ParseID(%q) error = nil, want error", s)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"net/mail"
+	"strings"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers transactional emails such as verification links.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+var ErrInvalidAddress = errors.New("invalid email address")
+
+// NormalizeAddress validates a bare RFC 5322 address (no display name) and
+// returns it with surrounding whitespace removed and the domain lowercased.
+func NormalizeAddress(address string) (string, error) {
+	address = strings.TrimSpace(address)
+	if address == "" {
+		return "", ErrInvalidAddress
+	}
+
+	parsed, err := mail.ParseAddress(address)
+	if err != nil || parsed.Name != "" || parsed.Address != address {
+		return "", ErrInvalidAddress
+	}
+
+	at := strings.LastIndex(parsed.Address, "@")
+	local, domain := parsed.Address[:at], parsed.Address[at+1:]
+	if len(local) > 64 || len(parsed.Address) > 254 || !strings.Contains(domain, ".") {
+		return "", ErrInvalidAddress
+	}
+
+	return local + "@" + strings.ToLower(domain), nil
+}
@@ -0,0 +1,97 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeAddress(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "valid address",
+			address: "user@example.com",
+			want:    "user@example.com",
+		},
+		{
+			name:    "surrounding whitespace and uppercase domain",
+			address: "  User.Name+tag@Example.COM ",
+			want:    "User.Name+tag@example.com",
+		},
+		{
+			name:    "empty",
+			address: "",
+			wantErr: true,
+		},
+		{
+			name:    "missing at sign",
+			address: "user.example.com",
+			wantErr: true,
+		},
+		{
+			name:    "display name",
+			address: "User <user@example.com>",
+			wantErr: true,
+		},
+		{
+			name:    "domain without dot",
+			address: "user@localhost",
+			wantErr: true,
+		},
+		{
+			name:    "two at signs",
+			address: "user@@example.com",
+			wantErr: true,
+		},
+		{
+			name:    "local part too long",
+			address: strings.Repeat("a", 65) + "@example.com",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeAddress(tt.address)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("NormalizeAddress(%q) = %q, want error", tt.address, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("NormalizeAddress(%q) unexpected error: %v", tt.address, err)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("NormalizeAddress(%q) = %q, want %q", tt.address, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogMailerSend(t *testing.T) {
+	var buf bytes.Buffer
+	m := NewLogMailer(&buf)
+
+	err := m.Send(context.Background(), Message{
+		To:      "user@example.com",
+		Subject: "Verify your email",
+		Body:    "token: abc123",
+	})
+	if err != nil {
+		t.Fatalf("Send() unexpected error: %v", err)
+	}
+
+	for _, want := range []string{"To: user@example.com", "Subject: Verify your email", "token: abc123"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Send() output missing %q, got %q", want, buf.String())
+		}
+	}
+}
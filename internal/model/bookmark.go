@@ -0,0 +1,22 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Bookmark is a chirp the user saved. Chirp is null and Deleted true when the
+// chirp was deleted after it was bookmarked.
+type Bookmark struct {
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	Chirp     *Chirp    `json:"chirp"`
+	Deleted   bool      `json:"deleted"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// BookmarkPage is a page of bookmarks. NextCursor is empty on the last page.
+type BookmarkPage struct {
+	Bookmarks  []Bookmark `json:"bookmarks"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+}
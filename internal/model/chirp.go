@@ -11,4 +11,13 @@ type Chirp struct {
 	UpdatedAt time.Time `json:"updated_at"`
 	Body      string    `json:"body"`
 	UserID    uuid.UUID `json:"user_id"`
+	Poll      *Poll     `json:"poll,omitempty"`
+	Pinned    bool      `json:"pinned,omitempty"`
+}
+
+// ChirpPage is a page of a cursor paginated timeline. NextCursor is empty on
+// the last page.
+type ChirpPage struct {
+	Chirps     []Chirp `json:"chirps"`
+	NextCursor string  `json:"next_cursor,omitempty"`
 }
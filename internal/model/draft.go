@@ -0,0 +1,27 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ChirpDraft is a chirp that isn't published yet. Status is "draft", or
+// "scheduled" when PublishAt is set. LastError is why publishing it last
+// failed.
+type ChirpDraft struct {
+	ID        uuid.UUID  `json:"id"`
+	Body      string     `json:"body"`
+	Poll      *DraftPoll `json:"poll"`
+	Status    string     `json:"status"`
+	PublishAt *time.Time `json:"publish_at"`
+	LastError string     `json:"last_error,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// DraftPoll is the poll created when the draft is published.
+type DraftPoll struct {
+	Options  []string  `json:"options"`
+	ClosesAt time.Time `json:"closes_at"`
+}
@@ -0,0 +1,26 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// List is a user-curated list of accounts. Private lists are only visible to
+// their owner.
+type List struct {
+	ID              uuid.UUID `json:"id"`
+	OwnerID         uuid.UUID `json:"owner_id"`
+	Name            string    `json:"name"`
+	Description     string    `json:"description"`
+	Private         bool      `json:"private"`
+	MemberCount     int32     `json:"member_count"`
+	SubscriberCount int32     `json:"subscriber_count"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+type ListMember struct {
+	UserID  uuid.UUID `json:"user_id"`
+	AddedAt time.Time `json:"added_at"`
+}
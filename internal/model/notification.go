@@ -0,0 +1,25 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Notification is a group of notifications of the same type about the same
+// chirp, like everyone who mentioned the user in it. Actors are the latest
+// three of the ActorCount users behind it, which is enough to show "X and 3
+// others".
+type Notification struct {
+	ID         uuid.UUID       `json:"id"`
+	Type       string          `json:"type"`
+	ChirpID    *uuid.UUID      `json:"chirp_id"`
+	Actors     []uuid.UUID     `json:"actors"`
+	ActorCount int32           `json:"actor_count"`
+	Data       json.RawMessage `json:"data"`
+	Read       bool            `json:"read"`
+	ReadAt     *time.Time      `json:"read_at"`
+	CreatedAt  time.Time       `json:"created_at"`
+	UpdatedAt  time.Time       `json:"updated_at"`
+}
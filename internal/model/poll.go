@@ -0,0 +1,24 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Poll is the poll attached to a chirp. The votes of each option are only
+// set once the viewer voted or the poll closed, and always for its author.
+type Poll struct {
+	ID          uuid.UUID    `json:"id"`
+	ClosesAt    time.Time    `json:"closes_at"`
+	Closed      bool         `json:"closed"`
+	TotalVotes  int32        `json:"total_votes"`
+	VotedOption *int32       `json:"voted_option"`
+	Options     []PollOption `json:"options"`
+}
+
+type PollOption struct {
+	Index int32  `json:"index"`
+	Text  string `json:"text"`
+	Votes *int32 `json:"votes,omitempty"`
+}
@@ -0,0 +1,17 @@
+package model
+
+import (
+	"time"
+)
+
+// Subscription status is active, past_due, canceled or expired. Chirpy Red
+// lasts until CurrentPeriodEnd unless it is expired, or until Polka ends it
+// when CurrentPeriodEnd is nil.
+type Subscription struct {
+	Plan               string     `json:"plan"`
+	Status             string     `json:"status"`
+	CurrentPeriodStart time.Time  `json:"current_period_start"`
+	CurrentPeriodEnd   *time.Time `json:"current_period_end"`
+	CanceledAt         *time.Time `json:"canceled_at"`
+	CreatedAt          time.Time  `json:"created_at"`
+}
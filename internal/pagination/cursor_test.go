@@ -0,0 +1,47 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	cursor := Cursor{
+		Time: time.Date(2026, 3, 1, 12, 30, 0, 123456000, time.UTC),
+		ID:   uuid.New(),
+	}
+
+	got, err := Decode(cursor.Encode())
+	if err != nil {
+		t.Fatalf("Decode() unexpected error: %v", err)
+	}
+	if !got.Time.Equal(cursor.Time) || got.ID != cursor.ID {
+		t.Errorf("Decode() = %+v, want %+v", got, cursor)
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"empty", ""},
+		{"not base64", "not a cursor!"},
+		{"no separator", encode("2026-03-01T12:30:00Z")},
+		{"bad time", encode("yesterday|" + uuid.NewString())},
+		{"bad id", encode("2026-03-01T12:30:00Z|42")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Decode(tt.cursor); err != ErrInvalidCursor {
+				t.Errorf("Decode(%q) error = %v, want ErrInvalidCursor", tt.cursor, err)
+			}
+		})
+	}
+}
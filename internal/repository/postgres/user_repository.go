@@ -66,7 +66,10 @@ func (r *userRepository) UpdateUser(ctx context.Context, userID uuid.UUID, email
 }
 
 func (r *userRepository) UpgradeToChirpyRed(ctx context.Context, userID uuid.UUID) error {
-	_, err := r.queries.UpgradeUserToChirpyRed(ctx, userID)
+	_, err := r.queries.SetUserChirpyRed(ctx, database.SetUserChirpyRedParams{
+		ID:          userID,
+		IsChirpyRed: true,
+	})
 	if err != nil {
 		return err
 	}
@@ -41,48 +41,1158 @@ DELETE FROM chirps
 WHERE id = $1 AND user_id = $2;
 
 -- name: GetUserByEmail :one
-SELECT id, created_at, updated_at, email, hashed_password,is_chirpy_red
+SELECT id, created_at, updated_at, email, hashed_password,is_chirpy_red, is_admin, email_verified_at
 FROM users
 WHERE email = $1;
 
+-- name: GetUserByID :one
+SELECT * FROM users WHERE id = $1;
+
 
 -- name: StoreRefreshToken :one
-INSERT INTO refresh_tokens (token, user_id, created_at, updated_at, expires_at, revoked_at)
+INSERT INTO refresh_tokens (
+    token_hash, user_id, family_id, created_at, updated_at, expires_at, revoked_at,
+    device_name, user_agent, ip_address, session_started_at, last_used_at,
+    client_id, scopes
+)
 VALUES (
-    $1, -- token
+    $1, -- token_hash
     $2, -- user_id
+    $3, -- family_id
     NOW(), -- created_at
     NOW(), -- updated_at
     NOW() + INTERVAL '60 days', -- expires_at
-    NULL -- revoked_at
+    NULL, -- revoked_at
+    $4, -- device_name
+    $5, -- user_agent
+    $6, -- ip_address
+    $7, -- session_started_at
+    NOW(), -- last_used_at
+    $8, -- client_id
+    $9 -- scopes
 )
 RETURNING *;
 
--- name: GetUserFromRefreshToken :one
-SELECT user_id
-FROM refresh_tokens
-WHERE token = $1
-AND expires_at > NOW()
-AND revoked_at IS NULL;
+-- name: GetRefreshToken :one
+SELECT * FROM refresh_tokens WHERE token_hash = $1;
+
+-- name: RotateRefreshToken :execrows
+UPDATE refresh_tokens
+SET rotated_at = NOW(),
+    updated_at = NOW()
+WHERE token_hash = $1
+AND rotated_at IS NULL
+AND revoked_at IS NULL
+AND expires_at > NOW();
 
 -- name: RevokeRefreshToken :one
 UPDATE refresh_tokens
 SET revoked_at = NOW(),
     updated_at = NOW()
-WHERE token = $1
+WHERE token_hash = $1
 RETURNING *;
 
+-- name: RevokeRefreshTokenFamily :execrows
+UPDATE refresh_tokens
+SET revoked_at = NOW(),
+    updated_at = NOW()
+WHERE family_id = $1
+AND revoked_at IS NULL;
+
 -- name: UpdateUser :one
 UPDATE users
 SET email = $2,
     hashed_password = $3,
+    email_verified_at = CASE WHEN email = $2 THEN email_verified_at ELSE NULL END,
     updated_at = NOW()
 WHERE id = $1
 RETURNING id, created_at, updated_at, email,is_chirpy_red;
 
--- name: UpgradeUserToChirpyRed :one
+-- name: SetUserChirpyRed :one
 UPDATE users
-SET is_chirpy_red = TRUE,
+SET is_chirpy_red = $2,
     updated_at = NOW()
 WHERE id = $1
 RETURNING id, created_at, updated_at, email, is_chirpy_red;
+
+-- name: CreateAuditEvent :exec
+INSERT INTO audit_events (id, created_at, actor_id, action, target_type, target_id, ip_address, user_agent, metadata)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7
+);
+
+-- name: ListAuditEvents :many
+SELECT *
+FROM audit_events
+WHERE (sqlc.narg('actor_id')::uuid IS NULL OR actor_id = sqlc.narg('actor_id'))
+AND (sqlc.narg('action')::text IS NULL OR action = sqlc.narg('action'))
+AND (sqlc.narg('target_id')::text IS NULL OR target_id = sqlc.narg('target_id'))
+AND (sqlc.narg('since')::timestamptz IS NULL OR created_at >= sqlc.narg('since'))
+AND (sqlc.narg('until')::timestamptz IS NULL OR created_at < sqlc.narg('until'))
+ORDER BY created_at DESC
+LIMIT sqlc.arg('limit');
+
+-- name: CreateEmailVerificationToken :one
+INSERT INTO email_verification_tokens (token_hash, user_id, email, created_at, expires_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    NOW(),
+    NOW() + INTERVAL '24 hours'
+)
+RETURNING *;
+
+-- name: ConsumeEmailVerificationToken :one
+UPDATE email_verification_tokens
+SET used_at = NOW()
+WHERE token_hash = $1
+AND used_at IS NULL
+AND expires_at > NOW()
+RETURNING *;
+
+-- name: MarkUserEmailVerified :one
+UPDATE users
+SET email_verified_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1 AND email = $2
+RETURNING id, created_at, updated_at, email, is_chirpy_red, email_verified_at;
+
+-- name: CreatePasswordResetToken :one
+INSERT INTO password_reset_tokens (token_hash, user_id, created_at, expires_at)
+VALUES (
+    $1,
+    $2,
+    NOW(),
+    NOW() + INTERVAL '1 hour'
+)
+RETURNING *;
+
+-- name: ConsumePasswordResetToken :one
+UPDATE password_reset_tokens
+SET used_at = NOW()
+WHERE token_hash = $1
+AND used_at IS NULL
+AND expires_at > NOW()
+RETURNING *;
+
+-- name: InvalidatePasswordResetTokens :exec
+UPDATE password_reset_tokens
+SET used_at = NOW()
+WHERE user_id = $1
+AND used_at IS NULL;
+
+-- name: UpdateUserPassword :exec
+UPDATE users
+SET hashed_password = $2,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: RevokeAllRefreshTokensForUser :execrows
+UPDATE refresh_tokens
+SET revoked_at = NOW(),
+    updated_at = NOW()
+WHERE user_id = $1
+AND revoked_at IS NULL;
+
+-- name: StartUserTOTPEnrollment :one
+INSERT INTO user_totp (user_id, secret, created_at, updated_at, enabled_at, last_step)
+VALUES (
+    $1,
+    $2,
+    NOW(),
+    NOW(),
+    NULL,
+    0
+)
+ON CONFLICT (user_id) DO UPDATE
+SET secret = EXCLUDED.secret,
+    updated_at = NOW(),
+    last_step = 0
+WHERE user_totp.enabled_at IS NULL
+RETURNING *;
+
+-- name: GetUserTOTP :one
+SELECT * FROM user_totp WHERE user_id = $1;
+
+-- name: EnableUserTOTP :execrows
+UPDATE user_totp
+SET enabled_at = NOW(),
+    last_step = $2,
+    updated_at = NOW()
+WHERE user_id = $1
+AND enabled_at IS NULL;
+
+-- name: UseUserTOTPStep :execrows
+UPDATE user_totp
+SET last_step = $2,
+    updated_at = NOW()
+WHERE user_id = $1
+AND last_step < $2;
+
+-- name: DeleteUserTOTP :exec
+DELETE FROM user_totp WHERE user_id = $1;
+
+-- name: CreateTOTPRecoveryCode :exec
+INSERT INTO totp_recovery_codes (id, user_id, code_hash, created_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    NOW()
+);
+
+-- name: UseTOTPRecoveryCode :execrows
+UPDATE totp_recovery_codes
+SET used_at = NOW()
+WHERE user_id = $1
+AND code_hash = $2
+AND used_at IS NULL;
+
+-- name: DeleteTOTPRecoveryCodes :exec
+DELETE FROM totp_recovery_codes WHERE user_id = $1;
+
+-- name: CreateMFAChallenge :one
+INSERT INTO mfa_challenges (token_hash, user_id, created_at, expires_at)
+VALUES (
+    $1,
+    $2,
+    NOW(),
+    NOW() + INTERVAL '5 minutes'
+)
+RETURNING *;
+
+-- name: GetActiveMFAChallenge :one
+SELECT * FROM mfa_challenges
+WHERE token_hash = $1
+AND used_at IS NULL
+AND expires_at > NOW()
+AND attempts < 5;
+
+-- name: IncrementMFAChallengeAttempts :exec
+UPDATE mfa_challenges
+SET attempts = attempts + 1
+WHERE token_hash = $1;
+
+-- name: ConsumeMFAChallenge :execrows
+UPDATE mfa_challenges
+SET used_at = NOW()
+WHERE token_hash = $1
+AND used_at IS NULL;
+
+-- name: ListActiveSessions :many
+SELECT family_id, device_name, user_agent, ip_address, session_started_at, last_used_at, expires_at
+FROM refresh_tokens
+WHERE user_id = $1
+AND revoked_at IS NULL
+AND rotated_at IS NULL
+AND expires_at > NOW()
+ORDER BY last_used_at DESC;
+
+-- name: RevokeUserRefreshTokenFamily :execrows
+UPDATE refresh_tokens
+SET revoked_at = NOW(),
+    updated_at = NOW()
+WHERE family_id = $1
+AND user_id = $2
+AND revoked_at IS NULL;
+
+-- name: CreatePersonalAccessToken :one
+INSERT INTO personal_access_tokens (id, created_at, user_id, name, token_hash, scopes, expires_at)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+RETURNING *;
+
+-- name: GetActivePersonalAccessToken :one
+SELECT * FROM personal_access_tokens
+WHERE token_hash = $1
+AND revoked_at IS NULL
+AND expires_at > NOW();
+
+-- name: TouchPersonalAccessToken :exec
+UPDATE personal_access_tokens
+SET last_used_at = NOW()
+WHERE id = $1;
+
+-- name: ListPersonalAccessTokens :many
+SELECT * FROM personal_access_tokens
+WHERE user_id = $1
+AND revoked_at IS NULL
+AND expires_at > NOW()
+ORDER BY created_at DESC;
+
+-- name: RevokePersonalAccessToken :execrows
+UPDATE personal_access_tokens
+SET revoked_at = NOW()
+WHERE id = $1
+AND user_id = $2
+AND revoked_at IS NULL;
+
+-- name: RevokeAllPersonalAccessTokensForUser :execrows
+UPDATE personal_access_tokens
+SET revoked_at = NOW()
+WHERE user_id = $1
+AND revoked_at IS NULL;
+
+-- name: CreateOAuthClient :one
+INSERT INTO oauth_clients (id, created_at, updated_at, owner_id, name, client_secret_hash, redirect_uris, scopes)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+RETURNING *;
+
+-- name: GetOAuthClient :one
+SELECT * FROM oauth_clients WHERE id = $1;
+
+-- name: ListOAuthClientsByOwner :many
+SELECT * FROM oauth_clients
+WHERE owner_id = $1
+ORDER BY created_at DESC;
+
+-- name: DeleteOAuthClient :execrows
+DELETE FROM oauth_clients
+WHERE id = $1
+AND owner_id = $2;
+
+-- name: CreateOAuthAuthorizationCode :exec
+INSERT INTO oauth_authorization_codes (code_hash, client_id, user_id, redirect_uri, scopes, code_challenge, created_at, expires_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    NOW(),
+    NOW() + INTERVAL '10 minutes'
+);
+
+-- name: ConsumeOAuthAuthorizationCode :one
+UPDATE oauth_authorization_codes
+SET used_at = NOW()
+WHERE code_hash = $1
+AND used_at IS NULL
+AND expires_at > NOW()
+RETURNING *;
+
+-- name: ListActiveLoginLockouts :many
+SELECT * FROM login_throttles
+WHERE key = ANY(sqlc.arg(keys)::text[])
+AND locked_until > NOW();
+
+-- name: RecordLoginFailure :one
+-- failures older than a day are forgotten
+INSERT INTO login_throttles (key, failures, last_failure_at)
+VALUES ($1, 1, NOW())
+ON CONFLICT (key) DO UPDATE
+SET failures = CASE
+        WHEN login_throttles.last_failure_at < NOW() - INTERVAL '24 hours' THEN 1
+        ELSE login_throttles.failures + 1
+    END,
+    last_failure_at = NOW()
+RETURNING *;
+
+-- name: SetLoginLockout :exec
+UPDATE login_throttles
+SET locked_until = $2
+WHERE key = $1;
+
+-- name: ClearLoginThrottle :exec
+DELETE FROM login_throttles WHERE key = $1;
+
+-- name: RememberWebhookSignature :execrows
+INSERT INTO webhook_signatures (signature, expires_at)
+VALUES ($1, $2)
+ON CONFLICT (signature) DO NOTHING;
+
+-- name: DeleteExpiredWebhookSignatures :exec
+DELETE FROM webhook_signatures WHERE expires_at < NOW();
+
+-- name: CreateWebhookEvent :one
+-- returns no rows when the provider already delivered this event
+INSERT INTO webhook_events (id, provider, event_id, event_type, payload, received_at, lease_until)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    $4,
+    NOW(),
+    $5
+)
+ON CONFLICT (provider, event_id) DO NOTHING
+RETURNING *;
+
+-- name: ClaimWebhookEventRetry :one
+-- takes a redelivered or replayed event for another attempt: failed events,
+-- ignored ones when include_ignored is set, and pending ones whose attempt
+-- outlived its lease. No rows while an attempt is running or when the event
+-- needs no retry
+UPDATE webhook_events
+SET status = 'pending',
+    lease_until = sqlc.arg(lease_until)
+WHERE provider = sqlc.arg(provider)
+AND event_id = sqlc.arg(event_id)
+AND (
+    status = 'failed'
+    OR (status = 'ignored' AND sqlc.arg(include_ignored)::boolean)
+    OR (status = 'pending' AND lease_until < NOW())
+)
+RETURNING *;
+
+-- name: GetWebhookEvent :one
+SELECT * FROM webhook_events WHERE id = $1;
+
+-- name: GetWebhookEventByProviderID :one
+SELECT * FROM webhook_events
+WHERE provider = $1
+AND event_id = $2;
+
+-- name: ListWebhookEvents :many
+SELECT *
+FROM webhook_events
+WHERE (sqlc.narg('provider')::text IS NULL OR provider = sqlc.narg('provider'))
+AND (sqlc.narg('status')::text IS NULL OR status = sqlc.narg('status'))
+AND (sqlc.narg('event_type')::text IS NULL OR event_type = sqlc.narg('event_type'))
+ORDER BY received_at DESC
+LIMIT sqlc.arg('limit');
+
+-- name: FinishWebhookEvent :one
+UPDATE webhook_events
+SET status = $2,
+    last_error = $3,
+    attempts = attempts + 1,
+    processed_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: GetSubscriptionByUserID :one
+SELECT * FROM subscriptions WHERE user_id = $1;
+
+-- name: ActivateSubscription :one
+INSERT INTO subscriptions (id, created_at, updated_at, user_id, plan, status, current_period_start, current_period_end)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    'active',
+    $3,
+    $4
+)
+ON CONFLICT (user_id) DO UPDATE
+SET plan = EXCLUDED.plan,
+    status = 'active',
+    current_period_start = EXCLUDED.current_period_start,
+    current_period_end = EXCLUDED.current_period_end,
+    canceled_at = NULL,
+    updated_at = NOW()
+RETURNING *;
+
+-- name: MarkSubscriptionPastDue :one
+UPDATE subscriptions
+SET status = 'past_due',
+    updated_at = NOW()
+WHERE user_id = $1
+AND status IN ('active', 'past_due')
+RETURNING *;
+
+-- name: CancelSubscription :one
+UPDATE subscriptions
+SET status = 'canceled',
+    canceled_at = COALESCE(canceled_at, NOW()),
+    updated_at = NOW()
+WHERE user_id = $1
+AND status <> 'expired'
+RETURNING *;
+
+-- name: EndSubscription :one
+UPDATE subscriptions
+SET status = 'expired',
+    current_period_end = LEAST(current_period_end, NOW()),
+    updated_at = NOW()
+WHERE user_id = $1
+RETURNING *;
+
+-- name: ExpireSubscriptions :many
+-- ends every subscription whose period is over and takes Chirpy Red away.
+-- Open-ended subscriptions only end on an event from Polka
+WITH expired AS (
+    UPDATE subscriptions
+    SET status = 'expired',
+        updated_at = NOW()
+    WHERE status <> 'expired'
+    AND current_period_end IS NOT NULL
+    AND current_period_end <= NOW()
+    RETURNING user_id
+)
+UPDATE users
+SET is_chirpy_red = FALSE,
+    updated_at = NOW()
+FROM expired
+WHERE users.id = expired.user_id
+RETURNING users.id;
+
+-- name: CreateWebhookEndpoint :one
+INSERT INTO webhook_endpoints (id, created_at, updated_at, owner_id, oauth_client_id, url, events, secret)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+RETURNING *;
+
+-- name: GetWebhookEndpoint :one
+SELECT * FROM webhook_endpoints WHERE id = $1;
+
+-- name: ListWebhookEndpointsByOwner :many
+SELECT * FROM webhook_endpoints
+WHERE owner_id = $1
+ORDER BY created_at DESC;
+
+-- name: DeleteWebhookEndpoint :execrows
+DELETE FROM webhook_endpoints
+WHERE id = $1
+AND owner_id = $2;
+
+-- name: ListWebhookEndpointsForEvent :many
+-- the user's own endpoints and those of the OAuth clients they authorized,
+-- when the authorization includes the scope the event requires
+SELECT * FROM webhook_endpoints
+WHERE sqlc.arg(event_type)::text = ANY(events)
+AND (
+    (oauth_client_id IS NULL AND owner_id = sqlc.arg(user_id))
+    OR oauth_client_id IN (
+        SELECT client_id FROM refresh_tokens
+        WHERE user_id = sqlc.arg(user_id)
+        AND client_id IS NOT NULL
+        AND revoked_at IS NULL
+        AND expires_at > NOW()
+        AND (sqlc.narg(scope)::text IS NULL OR sqlc.narg(scope)::text = ANY(scopes))
+    )
+);
+
+-- name: EnqueueWebhookDelivery :exec
+INSERT INTO webhook_deliveries (id, created_at, endpoint_id, event_id, event_type, payload, next_attempt_at)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    NOW()
+);
+
+-- name: ClaimDueWebhookDeliveries :many
+-- next_attempt_at is pushed to lease_until so other workers leave the
+-- deliveries alone while they are being sent
+UPDATE webhook_deliveries
+SET next_attempt_at = sqlc.arg(lease_until)
+WHERE id IN (
+    SELECT id FROM webhook_deliveries
+    WHERE status = 'pending'
+    AND next_attempt_at <= NOW()
+    ORDER BY next_attempt_at
+    LIMIT sqlc.arg('limit')
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: RecordWebhookDeliveryAttempt :one
+UPDATE webhook_deliveries
+SET status = $2,
+    attempts = attempts + 1,
+    next_attempt_at = $3,
+    last_attempt_at = NOW(),
+    response_status = $4,
+    last_error = $5,
+    delivered_at = CASE WHEN $2 = 'succeeded' THEN NOW() ELSE delivered_at END
+WHERE id = $1
+RETURNING *;
+
+-- name: ListWebhookDeliveries :many
+SELECT *
+FROM webhook_deliveries
+WHERE endpoint_id = sqlc.arg(endpoint_id)
+AND (sqlc.narg('status')::text IS NULL OR status = sqlc.narg('status'))
+ORDER BY created_at DESC
+LIMIT sqlc.arg('limit');
+
+-- name: RetryWebhookDelivery :one
+UPDATE webhook_deliveries
+SET status = 'pending',
+    attempts = 0,
+    next_attempt_at = NOW()
+WHERE id = $1
+AND endpoint_id = $2
+AND status = 'dead'
+RETURNING *;
+
+-- name: UpsertNotification :one
+-- folds into the user's unread notification with the same group_key, if any
+INSERT INTO notifications (id, created_at, updated_at, user_id, type, chirp_id, group_key, data)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+ON CONFLICT (user_id, group_key) WHERE read_at IS NULL
+DO UPDATE SET updated_at = NOW(),
+    data = EXCLUDED.data
+RETURNING *;
+
+-- name: AddNotificationActor :exec
+WITH added AS (
+    INSERT INTO notification_actors (notification_id, actor_id, created_at)
+    VALUES ($1, $2, NOW())
+    ON CONFLICT DO NOTHING
+    RETURNING notification_id
+)
+UPDATE notifications
+SET actor_count = actor_count + 1
+WHERE id IN (SELECT notification_id FROM added);
+
+-- name: ListNotifications :many
+SELECT *
+FROM notifications
+WHERE user_id = sqlc.arg(user_id)
+AND (NOT sqlc.arg(unread_only)::boolean OR read_at IS NULL)
+AND (sqlc.narg('before')::timestamptz IS NULL OR updated_at < sqlc.narg('before'))
+ORDER BY updated_at DESC
+LIMIT sqlc.arg('limit');
+
+-- name: ListNotificationActors :many
+-- the latest three actors of each notification
+SELECT notification_id, actor_id
+FROM (
+    SELECT notification_id, actor_id, created_at,
+        ROW_NUMBER() OVER (PARTITION BY notification_id ORDER BY created_at DESC) AS actor_rank
+    FROM notification_actors
+    WHERE notification_id = ANY(sqlc.arg(notification_ids)::uuid[])
+) latest
+WHERE actor_rank <= 3
+ORDER BY notification_id, created_at DESC;
+
+-- name: CountUnreadNotifications :one
+SELECT COUNT(*)
+FROM notifications
+WHERE user_id = $1
+AND read_at IS NULL;
+
+-- name: MarkNotificationRead :execrows
+UPDATE notifications
+SET read_at = COALESCE(read_at, NOW())
+WHERE id = $1
+AND user_id = $2;
+
+-- name: MarkAllNotificationsRead :execrows
+UPDATE notifications
+SET read_at = NOW()
+WHERE user_id = $1
+AND read_at IS NULL;
+
+-- name: ListNotificationPreferences :many
+SELECT *
+FROM notification_preferences
+WHERE user_id = $1
+ORDER BY type;
+
+-- name: SetNotificationPreference :exec
+INSERT INTO notification_preferences (user_id, type, enabled, updated_at)
+VALUES ($1, $2, $3, NOW())
+ON CONFLICT (user_id, type)
+DO UPDATE SET enabled = EXCLUDED.enabled,
+    updated_at = NOW();
+
+-- name: IsNotificationEnabled :one
+SELECT COALESCE((
+    SELECT enabled
+    FROM notification_preferences
+    WHERE user_id = $1
+    AND type = $2
+), TRUE)::boolean AS enabled;
+
+-- name: BlockUser :exec
+INSERT INTO user_blocks (blocker_id, blocked_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: UnblockUser :execrows
+DELETE FROM user_blocks
+WHERE blocker_id = $1
+AND blocked_id = $2;
+
+-- name: ListBlockedUsers :many
+SELECT *
+FROM user_blocks
+WHERE blocker_id = $1
+ORDER BY created_at DESC;
+
+-- name: HasBlockBetween :one
+-- whether user_id blocked any of user_ids or was blocked by one of them
+SELECT EXISTS (
+    SELECT 1
+    FROM user_blocks
+    WHERE (blocker_id = sqlc.arg(user_id) AND blocked_id = ANY(sqlc.arg(user_ids)::uuid[]))
+    OR (blocked_id = sqlc.arg(user_id) AND blocker_id = ANY(sqlc.arg(user_ids)::uuid[]))
+);
+
+-- name: CreateConversation :one
+-- returns no rows when the one-to-one conversation already exists
+INSERT INTO conversations (id, created_at, updated_at, creator_id, is_group, direct_key)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3
+)
+ON CONFLICT (direct_key) DO NOTHING
+RETURNING *;
+
+-- name: GetConversationByDirectKey :one
+SELECT *
+FROM conversations
+WHERE direct_key = $1;
+
+-- name: GetConversationForMember :one
+SELECT *
+FROM conversations
+WHERE id = $1
+AND id IN (
+    SELECT conversation_id
+    FROM conversation_members
+    WHERE user_id = $2
+);
+
+-- name: ListConversationsForUser :many
+SELECT c.id, c.created_at, c.updated_at, c.creator_id, c.is_group, c.direct_key,
+    (
+        SELECT COUNT(*)
+        FROM direct_messages d
+        WHERE d.conversation_id = c.id
+        AND d.sender_id <> m.user_id
+        AND d.sender_id NOT IN (
+            SELECT blocked_id
+            FROM user_blocks
+            WHERE blocker_id = m.user_id
+        )
+        AND (m.last_read_at IS NULL OR d.created_at > m.last_read_at)
+    ) AS unread_count
+FROM conversations c
+JOIN conversation_members m ON m.conversation_id = c.id
+WHERE m.user_id = sqlc.arg(user_id)
+AND (sqlc.narg('before')::timestamptz IS NULL OR c.updated_at < sqlc.narg('before'))
+ORDER BY c.updated_at DESC
+LIMIT sqlc.arg('limit');
+
+-- name: CountUnreadDirectMessages :one
+-- like ListDirectMessages, the messages of users the member blocked don't count
+SELECT COUNT(*)
+FROM direct_messages d
+JOIN conversation_members m ON m.conversation_id = d.conversation_id
+WHERE d.conversation_id = $1
+AND m.user_id = $2
+AND d.sender_id <> m.user_id
+AND d.sender_id NOT IN (
+    SELECT blocked_id
+    FROM user_blocks
+    WHERE blocker_id = m.user_id
+)
+AND (m.last_read_at IS NULL OR d.created_at > m.last_read_at);
+
+-- name: AddConversationMember :exec
+INSERT INTO conversation_members (conversation_id, user_id, joined_at)
+VALUES ($1, $2, NOW());
+
+-- name: ListConversationMembers :many
+SELECT *
+FROM conversation_members
+WHERE conversation_id = ANY(sqlc.arg(conversation_ids)::uuid[])
+ORDER BY conversation_id, joined_at;
+
+-- name: MarkConversationRead :exec
+-- read receipts only move forward. Without read_at everything sent so far
+-- is read.
+UPDATE conversation_members
+SET last_read_at = GREATEST(last_read_at, COALESCE(sqlc.narg(read_at), NOW()))
+WHERE conversation_id = sqlc.arg(conversation_id)
+AND user_id = sqlc.arg(user_id);
+
+-- name: CreateDirectMessage :one
+INSERT INTO direct_messages (id, created_at, conversation_id, sender_id, body)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3
+)
+RETURNING *;
+
+-- name: TouchConversation :exec
+UPDATE conversations
+SET updated_at = $2
+WHERE id = $1;
+
+-- name: GetDirectMessage :one
+SELECT *
+FROM direct_messages
+WHERE id = $1
+AND conversation_id = $2;
+
+-- name: ListDirectMessages :many
+-- newest first, without the messages of users the viewer blocked
+SELECT *
+FROM direct_messages
+WHERE conversation_id = sqlc.arg(conversation_id)
+AND sender_id NOT IN (
+    SELECT blocked_id
+    FROM user_blocks
+    WHERE blocker_id = sqlc.arg(viewer_id)
+)
+AND (sqlc.narg('before')::timestamptz IS NULL OR created_at < sqlc.narg('before'))
+ORDER BY created_at DESC
+LIMIT sqlc.arg('limit');
+
+-- name: CreateBookmark :exec
+INSERT INTO bookmarks (user_id, chirp_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteBookmark :execrows
+DELETE FROM bookmarks
+WHERE user_id = $1
+AND chirp_id = $2;
+
+-- name: ListBookmarks :many
+-- newest first, after the (created_at, chirp_id) cursor. The chirp columns
+-- are NULL when it was deleted.
+SELECT b.chirp_id, b.created_at,
+    c.created_at AS chirp_created_at,
+    c.updated_at AS chirp_updated_at,
+    c.body,
+    c.user_id
+FROM bookmarks b
+LEFT JOIN chirps c ON c.id = b.chirp_id
+WHERE b.user_id = sqlc.arg(user_id)
+AND (
+    sqlc.narg('cursor_created_at')::timestamptz IS NULL
+    OR (b.created_at, b.chirp_id) < (sqlc.narg('cursor_created_at'), sqlc.narg('cursor_chirp_id')::uuid)
+)
+ORDER BY b.created_at DESC, b.chirp_id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: CreateList :one
+INSERT INTO lists (id, created_at, updated_at, owner_id, name, description, is_private)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4
+)
+RETURNING *;
+
+-- name: GetList :one
+SELECT *
+FROM lists
+WHERE id = $1;
+
+-- name: UpdateList :one
+UPDATE lists
+SET name = $3,
+    description = $4,
+    is_private = $5,
+    updated_at = NOW()
+WHERE id = $1
+AND owner_id = $2
+RETURNING *;
+
+-- name: DeleteList :execrows
+DELETE FROM lists
+WHERE id = $1
+AND owner_id = $2;
+
+-- name: ListListsByOwner :many
+SELECT *
+FROM lists
+WHERE owner_id = sqlc.arg(owner_id)
+AND (sqlc.arg(include_private)::boolean OR NOT is_private)
+ORDER BY created_at DESC;
+
+-- name: ListSubscribedLists :many
+-- private lists drop out for subscribers who aren't the owner
+SELECT l.id, l.created_at, l.updated_at, l.owner_id, l.name, l.description, l.is_private, l.member_count, l.subscriber_count
+FROM lists l
+JOIN list_subscriptions s ON s.list_id = l.id
+WHERE s.user_id = $1
+AND (NOT l.is_private OR l.owner_id = $1)
+ORDER BY s.created_at DESC;
+
+-- name: AddListMember :exec
+WITH added AS (
+    INSERT INTO list_members (list_id, user_id, added_at)
+    VALUES ($1, $2, NOW())
+    ON CONFLICT DO NOTHING
+    RETURNING list_id
+)
+UPDATE lists
+SET member_count = member_count + 1,
+    updated_at = NOW()
+WHERE id IN (SELECT list_id FROM added);
+
+-- name: RemoveListMember :execrows
+WITH removed AS (
+    DELETE FROM list_members
+    WHERE list_id = $1
+    AND user_id = $2
+    RETURNING list_id
+)
+UPDATE lists
+SET member_count = member_count - 1,
+    updated_at = NOW()
+WHERE id IN (SELECT list_id FROM removed);
+
+-- name: ListListMembers :many
+SELECT *
+FROM list_members
+WHERE list_id = $1
+ORDER BY added_at DESC;
+
+-- name: SubscribeToList :exec
+WITH added AS (
+    INSERT INTO list_subscriptions (list_id, user_id, created_at)
+    VALUES ($1, $2, NOW())
+    ON CONFLICT DO NOTHING
+    RETURNING list_id
+)
+UPDATE lists
+SET subscriber_count = subscriber_count + 1
+WHERE id IN (SELECT list_id FROM added);
+
+-- name: UnsubscribeFromList :execrows
+WITH removed AS (
+    DELETE FROM list_subscriptions
+    WHERE list_id = $1
+    AND user_id = $2
+    RETURNING list_id
+)
+UPDATE lists
+SET subscriber_count = subscriber_count - 1
+WHERE id IN (SELECT list_id FROM removed);
+
+-- name: ListTimeline :many
+-- the chirps of the list's members newest first, after the (created_at, id)
+-- cursor, without the ones of users the viewer blocked
+SELECT c.id, c.created_at, c.updated_at, c.body, c.user_id
+FROM chirps c
+WHERE c.user_id IN (
+    SELECT user_id
+    FROM list_members
+    WHERE list_id = sqlc.arg(list_id)
+)
+AND c.user_id NOT IN (
+    SELECT blocked_id
+    FROM user_blocks
+    WHERE blocker_id = sqlc.arg(viewer_id)
+)
+AND (
+    sqlc.narg('cursor_created_at')::timestamptz IS NULL
+    OR (c.created_at, c.id) < (sqlc.narg('cursor_created_at'), sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY c.created_at DESC, c.id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: CreatePoll :one
+INSERT INTO polls (id, chirp_id, created_at, closes_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    NOW(),
+    $2
+)
+RETURNING *;
+
+-- name: CreatePollOption :exec
+INSERT INTO poll_options (poll_id, option_index, text)
+VALUES ($1, $2, $3);
+
+-- name: GetPollByChirpID :one
+SELECT *
+FROM polls
+WHERE chirp_id = $1;
+
+-- name: ListPollsForChirps :many
+SELECT *
+FROM polls
+WHERE chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[]);
+
+-- name: ListPollOptions :many
+SELECT *
+FROM poll_options
+WHERE poll_id = ANY(sqlc.arg(poll_ids)::uuid[])
+ORDER BY poll_id, option_index;
+
+-- name: ListPollVotesByUser :many
+SELECT *
+FROM poll_votes
+WHERE user_id = sqlc.arg(user_id)
+AND poll_id = ANY(sqlc.arg(poll_ids)::uuid[]);
+
+-- name: CastPollVote :execrows
+-- nothing is counted when the user already voted or the poll is closed
+WITH vote AS (
+    INSERT INTO poll_votes (poll_id, user_id, option_index, created_at)
+    SELECT p.id, sqlc.arg(user_id), sqlc.arg(option_index), NOW()
+    FROM polls p
+    WHERE p.id = sqlc.arg(poll_id)
+    AND p.closes_at > NOW()
+    ON CONFLICT (poll_id, user_id) DO NOTHING
+    RETURNING poll_id, option_index
+)
+UPDATE poll_options o
+SET vote_count = o.vote_count + 1
+FROM vote
+WHERE o.poll_id = vote.poll_id
+AND o.option_index = vote.option_index;
+
+-- name: CreateChirpDraft :one
+INSERT INTO chirp_drafts (id, created_at, updated_at, user_id, body, poll, publish_at)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4
+)
+RETURNING *;
+
+-- name: GetChirpDraft :one
+SELECT *
+FROM chirp_drafts
+WHERE id = $1
+AND user_id = $2;
+
+-- name: ListChirpDrafts :many
+-- without scheduled both drafts and scheduled chirps are listed
+SELECT *
+FROM chirp_drafts
+WHERE user_id = sqlc.arg(user_id)
+AND (sqlc.narg('scheduled')::boolean IS NULL OR (publish_at IS NOT NULL) = sqlc.narg('scheduled'))
+ORDER BY updated_at DESC;
+
+-- name: UpdateChirpDraft :one
+UPDATE chirp_drafts
+SET body = $3,
+    poll = $4,
+    publish_at = $5,
+    publish_attempts = 0,
+    last_error = '',
+    updated_at = NOW()
+WHERE id = $1
+AND user_id = $2
+RETURNING *;
+
+-- name: DeleteChirpDraft :execrows
+DELETE FROM chirp_drafts
+WHERE id = $1
+AND user_id = $2;
+
+-- name: TakeChirpDraft :one
+-- removes the draft being published, in the transaction that creates its chirp
+DELETE FROM chirp_drafts
+WHERE id = $1
+AND user_id = $2
+RETURNING *;
+
+-- name: TakeDueScheduledChirp :one
+-- the scheduled chirp due the longest, skipping the ones other instances are
+-- publishing and those of authors whose email isn't verified
+DELETE FROM chirp_drafts
+WHERE id = (
+    SELECT d.id
+    FROM chirp_drafts d
+    JOIN users u ON u.id = d.user_id
+    WHERE d.publish_at <= NOW()
+    AND u.email_verified_at IS NOT NULL
+    ORDER BY d.publish_at
+    LIMIT 1
+    FOR UPDATE OF d SKIP LOCKED
+)
+RETURNING *;
+
+-- name: DeferScheduledChirp :exec
+-- publishing failed: the chirp is tried again at retry_at, or goes back to
+-- being a draft once it has failed max_attempts times. A max_attempts of 0
+-- sends it back right away
+UPDATE chirp_drafts
+SET publish_attempts = publish_attempts + 1,
+    last_error = sqlc.arg(last_error),
+    publish_at = CASE
+        WHEN publish_attempts + 1 >= sqlc.arg(max_attempts)::integer THEN NULL
+        ELSE sqlc.arg(retry_at)::timestamptz
+    END,
+    updated_at = NOW()
+WHERE id = sqlc.arg(id);
+
+-- name: PinChirp :execrows
+-- replaces the pinned chirp, only the author's own chirps can be pinned
+INSERT INTO pinned_chirps (user_id, chirp_id, pinned_at)
+SELECT c.user_id, c.id, NOW()
+FROM chirps c
+WHERE c.id = sqlc.arg(chirp_id)
+AND c.user_id = sqlc.arg(user_id)
+ON CONFLICT (user_id) DO UPDATE
+SET chirp_id = EXCLUDED.chirp_id,
+    pinned_at = EXCLUDED.pinned_at;
+
+-- name: UnpinChirp :execrows
+DELETE FROM pinned_chirps
+WHERE user_id = $1
+AND chirp_id = $2;
+
+-- name: GetPinnedChirp :one
+SELECT c.id, c.created_at, c.updated_at, c.body, c.user_id
+FROM chirps c
+JOIN pinned_chirps p ON p.chirp_id = c.id
+WHERE p.user_id = $1;
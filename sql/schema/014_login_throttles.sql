@@ -0,0 +1,11 @@
+-- +goose Up
+-- consecutive failed logins per key, "account:<email>" or "ip:<address>"
+CREATE TABLE login_throttles (
+    key TEXT PRIMARY KEY,
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    locked_until TIMESTAMP WITH TIME ZONE
+);
+
+-- +goose Down
+DROP TABLE login_throttles;
@@ -0,0 +1,10 @@
+-- +goose Up
+-- signatures of accepted webhook deliveries, kept until their timestamp falls
+-- out of the tolerance window so a captured delivery can't be replayed
+CREATE TABLE webhook_signatures (
+    signature TEXT PRIMARY KEY,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+-- +goose Down
+DROP TABLE webhook_signatures;
@@ -0,0 +1,22 @@
+-- +goose Up
+-- every incoming webhook, keyed by the provider's event id so a redelivered
+-- event is recognised. status is pending, processed, ignored or failed
+CREATE TABLE webhook_events (
+    id UUID PRIMARY KEY,
+    provider TEXT NOT NULL,
+    event_id TEXT NOT NULL,
+    event_type TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    received_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    processed_at TIMESTAMP WITH TIME ZONE,
+    UNIQUE (provider, event_id)
+);
+
+CREATE INDEX webhook_events_received_at_idx ON webhook_events (received_at DESC);
+CREATE INDEX webhook_events_status_idx ON webhook_events (status, received_at DESC);
+
+-- +goose Down
+DROP TABLE webhook_events;
@@ -0,0 +1,30 @@
+-- +goose Up
+-- one subscription per user. status is active, past_due (payment failed),
+-- canceled (runs until the end of the period) or expired. users.is_chirpy_red
+-- stays as the flag the rest of the app reads and follows the subscription.
+-- current_period_end is NULL until Polka sends one, and only an explicit end
+-- ever expires a subscription
+CREATE TABLE subscriptions (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    user_id UUID NOT NULL UNIQUE REFERENCES users(id) ON DELETE CASCADE,
+    plan TEXT NOT NULL,
+    status TEXT NOT NULL,
+    current_period_start TIMESTAMP WITH TIME ZONE NOT NULL,
+    current_period_end TIMESTAMP WITH TIME ZONE,
+    canceled_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX subscriptions_period_end_idx ON subscriptions (current_period_end)
+WHERE status <> 'expired';
+
+-- users upgraded before subscriptions existed keep Chirpy Red until Polka
+-- says otherwise
+INSERT INTO subscriptions (id, user_id, plan, status, current_period_start, current_period_end)
+SELECT gen_random_uuid(), id, 'chirpy_red', 'active', NOW(), NULL
+FROM users
+WHERE is_chirpy_red;
+
+-- +goose Down
+DROP TABLE subscriptions;
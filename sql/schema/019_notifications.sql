@@ -0,0 +1,44 @@
+-- +goose Up
+-- one row per group of notifications: the unread notifications with the same
+-- group_key (same type and target, like everyone mentioning you in a chirp)
+-- are folded into one row with one notification_actors row per actor. Rows
+-- without group_key are never folded.
+CREATE TABLE notifications (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type TEXT NOT NULL,
+    chirp_id UUID REFERENCES chirps(id) ON DELETE CASCADE,
+    group_key TEXT,
+    data JSONB NOT NULL DEFAULT '{}',
+    actor_count INTEGER NOT NULL DEFAULT 0,
+    read_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX notifications_user_id_idx ON notifications (user_id, updated_at DESC);
+CREATE INDEX notifications_unread_idx ON notifications (user_id)
+WHERE read_at IS NULL;
+CREATE UNIQUE INDEX notifications_unread_group_idx ON notifications (user_id, group_key)
+WHERE read_at IS NULL;
+
+CREATE TABLE notification_actors (
+    notification_id UUID NOT NULL REFERENCES notifications(id) ON DELETE CASCADE,
+    actor_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (notification_id, actor_id)
+);
+
+-- types without a row are enabled
+CREATE TABLE notification_preferences (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type TEXT NOT NULL,
+    enabled BOOLEAN NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, type)
+);
+
+-- +goose Down
+DROP TABLE notification_preferences;
+DROP TABLE notification_actors;
+DROP TABLE notifications;
@@ -0,0 +1,39 @@
+-- +goose Up
+-- direct_key is "<smaller user id>:<bigger user id>" for one-to-one
+-- conversations, so each pair of users has only one, and NULL for groups.
+-- updated_at moves with every message.
+CREATE TABLE conversations (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    creator_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    is_group BOOLEAN NOT NULL,
+    direct_key TEXT UNIQUE
+);
+
+-- last_read_at is the read receipt: every message up to it has been read
+CREATE TABLE conversation_members (
+    conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    joined_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    last_read_at TIMESTAMP WITH TIME ZONE,
+    PRIMARY KEY (conversation_id, user_id)
+);
+
+CREATE INDEX conversation_members_user_id_idx ON conversation_members (user_id);
+
+-- kept apart from chirps so they can never end up in a public listing
+CREATE TABLE direct_messages (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    sender_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    body TEXT NOT NULL
+);
+
+CREATE INDEX direct_messages_conversation_id_idx ON direct_messages (conversation_id, created_at DESC);
+
+-- +goose Down
+DROP TABLE direct_messages;
+DROP TABLE conversation_members;
+DROP TABLE conversations;
@@ -0,0 +1,21 @@
+-- +goose Up
+-- chirps that aren't published yet. Drafts have no publish_at, scheduled
+-- chirps are published by the scheduler once publish_at passes and the row
+-- becomes a chirp, so they never show up with the published ones. poll is the
+-- poll to create with the chirp, JSON null without one.
+CREATE TABLE chirp_drafts (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    body TEXT NOT NULL,
+    poll JSONB NOT NULL DEFAULT 'null',
+    publish_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX chirp_drafts_user_id_idx ON chirp_drafts (user_id, updated_at DESC);
+CREATE INDEX chirp_drafts_publish_at_idx ON chirp_drafts (publish_at)
+WHERE publish_at IS NOT NULL;
+
+-- +goose Down
+DROP TABLE chirp_drafts;
@@ -0,0 +1,10 @@
+-- +goose Up
+-- one pinned chirp per user, unpinned with the chirp when it's deleted
+CREATE TABLE pinned_chirps (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    chirp_id UUID NOT NULL UNIQUE REFERENCES chirps(id) ON DELETE CASCADE,
+    pinned_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- +goose Down
+DROP TABLE pinned_chirps;
@@ -0,0 +1,7 @@
+-- +goose Up
+-- a pending event is being processed until lease_until, after that the
+-- attempt is presumed dead and a redelivery may take it over
+ALTER TABLE webhook_events ADD COLUMN lease_until TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW();
+
+-- +goose Down
+ALTER TABLE webhook_events DROP COLUMN lease_until;
@@ -0,0 +1,11 @@
+-- +goose Up
+-- a scheduled chirp that fails to publish is tried again later, and goes back
+-- to being a draft after too many failures. last_error tells the author why
+ALTER TABLE chirp_drafts
+ADD COLUMN publish_attempts INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN last_error TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE chirp_drafts
+DROP COLUMN last_error,
+DROP COLUMN publish_attempts;